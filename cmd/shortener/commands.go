@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+	"github.com/gsk148/urlShorteningService/internal/app/config"
+	"github.com/gsk148/urlShorteningService/internal/app/logger"
+	"github.com/gsk148/urlShorteningService/internal/app/migrate"
+	"github.com/gsk148/urlShorteningService/internal/app/policy"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+	"github.com/gsk148/urlShorteningService/internal/app/transfer"
+	"github.com/gsk148/urlShorteningService/internal/app/urlnorm"
+)
+
+// command is subcommand of shortener, args follow its name. Service configuration
+// is taken from environment, CONFIG file and flags after "--"
+type command func(ctx context.Context, args []string) error
+
+var commands = map[string]command{
+	"export":  runExport,
+	"import":  runImport,
+	"storage": runStorage,
+}
+
+// runCommand runs subcommand named by the first argument and reports whether it exists
+func runCommand(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return false, nil
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	return true, cmd(ctx, args[1:])
+}
+
+// openStorage loads service configuration of args and opens its storage
+func openStorage(args []string) (*config.Config, storage.Storage, error) {
+	cfg, err := config.Parse(args, os.LookupEnv)
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := storage.NewStorage(*cfg, *logger.NewLogger())
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, store, nil
+}
+
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", transfer.FormatJSONL, "output format: jsonl, csv or file")
+	userID := fs.String("user", "", "export links of user only")
+	output := fs.String("o", "", "output file, stdout by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := transfer.CheckFormat(*format); err != nil {
+		return err
+	}
+	_, store, err := openStorage(fs.Args())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	count, err := transfer.Export(ctx, store, w, *format, *userID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d links\n", count)
+	return nil
+}
+
+func runImport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", transfer.FormatJSONL, "input format: jsonl, csv or file")
+	owner := fs.String("user", "", "owner of imported links, user_id of records by default")
+	input := fs.String("i", "", "input file, stdin by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := transfer.CheckFormat(*format); err != nil {
+		return err
+	}
+	cfg, store, err := openStorage(fs.Args())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	destPolicy, err := policy.FromConfig(cfg, *logger.NewLogger())
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if *input != "" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	opts := transfer.ImportOptions{
+		Owner:      *owner,
+		URLOptions: urlnorm.Options{Schemes: cfg.AllowedSchemes, MaxLength: cfg.MaxURLLength},
+		Policy:     destPolicy,
+	}
+	results := json.NewEncoder(os.Stdout)
+	summary, err := transfer.Import(ctx, store, r, *format, opts, func(res api.ImportResult) {
+		results.Encode(res)
+	})
+	fmt.Fprintf(os.Stderr, "created %d, renamed %d, exists %d, failed %d\n",
+		summary.Created, summary.Renamed, summary.Exists, summary.Failed)
+	if err != nil {
+		return err
+	}
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d records failed", summary.Failed)
+	}
+	return nil
+}
+
+// runStorage runs storage maintenance subcommands
+func runStorage(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "copy" {
+		return errors.New("usage: shortener storage copy -from <storage> -to <storage> [-dry-run] [-resume] [-verify]")
+	}
+	return runStorageCopy(ctx, args[1:])
+}
+
+func runStorageCopy(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("storage copy", flag.ContinueOnError)
+	from := fs.String("from", "", "source storage: memory, file:<path>, bolt:<path>, sqlite:<path> or db:<dsn>")
+	to := fs.String("to", "", "destination storage: memory, file:<path>, bolt:<path>, sqlite:<path> or db:<dsn>")
+	dryRun := fs.Bool("dry-run", false, "report what would be copied without writing")
+	resume := fs.Bool("resume", false, "continue interrupted copy skipping copied links")
+	verify := fs.Bool("verify", false, "only compare every source link with destination")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || *to == "" {
+		return errors.New("-from and -to are required")
+	}
+	if *from == *to {
+		return errors.New("-from and -to must differ")
+	}
+
+	log := logger.NewLogger()
+	src, err := storage.Open(*from, *log)
+	if err != nil {
+		return fmt.Errorf("open source: %w", err)
+	}
+	defer src.Close()
+	dst, err := storage.Open(*to, *log)
+	if err != nil {
+		return fmt.Errorf("open destination: %w", err)
+	}
+	defer dst.Close()
+
+	opts := migrate.Options{
+		DryRun: *dryRun,
+		Resume: *resume,
+		Verify: *verify,
+		Progress: func(s migrate.Stats) {
+			log.Infow("copy progress", "read", s.Read, "copied", s.Copied, "skipped", s.Skipped, "conflicts", s.Conflicts)
+		},
+	}
+	problems := json.NewEncoder(os.Stdout)
+	stats, err := migrate.Copy(ctx, src, dst, opts, func(p migrate.Problem) {
+		problems.Encode(p)
+	})
+	fmt.Fprintf(os.Stderr, "read %d, copied %d, skipped %d, conflicts %d, verified %d, missing %d\n",
+		stats.Read, stats.Copied, stats.Skipped, stats.Conflicts, stats.Verified, stats.Missing)
+	if err != nil {
+		return err
+	}
+	if stats.Conflicts > 0 || stats.Missing > 0 {
+		return fmt.Errorf("%d links were not copied or differ", stats.Conflicts+stats.Missing)
+	}
+	return nil
+}
@@ -3,23 +3,37 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"expvar"
+	"flag"
 	"fmt"
-	"go.uber.org/zap"
 	"log"
-	"net"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
+	"github.com/gsk148/urlShorteningService/internal/app/admin"
+	"github.com/gsk148/urlShorteningService/internal/app/cert"
+	"github.com/gsk148/urlShorteningService/internal/app/clientip"
 	"github.com/gsk148/urlShorteningService/internal/app/config"
+	"github.com/gsk148/urlShorteningService/internal/app/deletion"
+	"github.com/gsk148/urlShorteningService/internal/app/domains"
+	"github.com/gsk148/urlShorteningService/internal/app/grpchandlers"
 	"github.com/gsk148/urlShorteningService/internal/app/handlers"
+	"github.com/gsk148/urlShorteningService/internal/app/health"
 	"github.com/gsk148/urlShorteningService/internal/app/logger"
+	"github.com/gsk148/urlShorteningService/internal/app/policy"
 	pb "github.com/gsk148/urlShorteningService/internal/app/proto"
+	"github.com/gsk148/urlShorteningService/internal/app/ratelimit"
+	"github.com/gsk148/urlShorteningService/internal/app/reload"
+	"github.com/gsk148/urlShorteningService/internal/app/server"
 	"github.com/gsk148/urlShorteningService/internal/app/storage"
+	"github.com/gsk148/urlShorteningService/internal/app/tracing"
+	"github.com/gsk148/urlShorteningService/internal/app/urlnorm"
 )
 
 var (
@@ -28,87 +42,169 @@ var (
 	buildCommit  = "N/A"
 )
 
-func runRESTSrv(cfg *config.Config, myLog *zap.SugaredLogger, store storage.Storage) (*http.Server, error) {
-	handler := &handlers.Handler{
-		BaseURL:       cfg.BaseURL,
-		TrustedSubnet: cfg.TrustedSubnet,
-		Store:         store,
-		Logger:        *myLog,
-	}
-
-	router := handler.InitRoutes()
-
-	srv := &http.Server{
-		Addr:    cfg.ServerAddr,
-		Handler: router,
+func main() {
+	if ok, err := runCommand(os.Args[1:]); ok {
+		if err != nil && !errors.Is(err, flag.ErrHelp) {
+			log.Fatal(err)
+		}
+		return
 	}
-
-	if cfg.EnableHTTPS {
-		return srv, http.ListenAndServeTLS(cfg.ServerAddr, "internal/app/cert/server.crt", "internal/app/cert/server.key", router)
+	if err := run(); err != nil {
+		log.Fatal(err)
 	}
-	return srv, http.ListenAndServe(cfg.ServerAddr, router)
 }
 
-type ShortenerService struct {
-	pb.UnimplementedShortenerServiceServer
-	strg storage.Storage
-	log  zap.SugaredLogger
-}
-
-func runGRPCServer(store storage.Storage) {
-	listen, err := net.Listen("tcp", ":3200")
+// run starts service and blocks until it is stopped. Errors are returned instead
+// of exiting so deferred cleanup runs before the process exits
+func run() error {
+	cfg, err := config.Load()
 	if err != nil {
-		log.Fatal(err)
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
 	}
-
-	s := grpc.NewServer()
-	pb.RegisterShortenerServiceServer(
-		s, &ShortenerService{
-			strg:                                store,
-			UnimplementedShortenerServiceServer: pb.UnimplementedShortenerServiceServer{},
-		})
-	if err = s.Serve(listen); err != nil {
-		log.Fatal(err)
+	if cfg.PrintConfig {
+		return cfg.Print(os.Stdout)
 	}
-}
 
-func main() {
 	fmt.Println("Build version:", buildVersion)
 	fmt.Println("Build date:", buildDate)
 	fmt.Println("Build commit:", buildCommit)
 
-	cfg := config.Load()
-
 	myLog := logger.NewLogger()
+	if err = logger.SetLevel(cfg.LogLevel); err != nil {
+		return err
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg, buildVersion)
+	if err != nil {
+		return err
+	}
+
 	store, err := storage.NewStorage(*cfg, *myLog)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	if cache, ok := storage.Find[*storage.CachedStorage](store); ok {
+		expvar.Publish("storage_cache", expvar.Func(func() any { return cache.Stats() }))
+	}
+	if db, ok := storage.Find[*storage.DBStorage](store); ok {
+		expvar.Publish("db_pool", expvar.Func(func() any { return db.PoolStats() }))
+		expvar.Publish("db_replicas", expvar.Func(func() any { return db.ReplicaStats() }))
 	}
+	deletions := deletion.NewQueue(store, *myLog, deletion.DefaultQueueSize)
 
-	srv, err := runRESTSrv(cfg, myLog, store)
+	checker := health.NewChecker(health.DefaultTimeout, pb.ShortenerService_ServiceDesc.ServiceName)
+	checker.Add("storage", health.StorageCheck(store, cfg.StorageType))
+	checker.Add("deletion_queue", health.DeletionCheck(deletions))
 
+	clientIPs, err := clientip.NewResolver(cfg.TrustedProxies, cfg.ForwardedHeader)
 	if err != nil {
-		log.Fatalf("Failed to create HTTP server: %v", err)
+		return err
+	}
+	limiter := ratelimit.New(cfg, ratelimit.NewMemoryStore(), *myLog)
+	urlOpts := urlnorm.Options{Schemes: cfg.AllowedSchemes, MaxLength: cfg.MaxURLLength}
+	destPolicy, err := policy.FromConfig(cfg, *myLog)
+	if err != nil {
+		return err
 	}
 
-	sigint := make(chan os.Signal, 1)
-	signal.Notify(sigint, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	auditTrail, err := admin.NewTrail(cfg.AuditLogFile, *myLog)
+	if err != nil {
+		return err
+	}
+	defer auditTrail.Close()
+	adminService := admin.New(store, auditTrail, cfg)
+	shortDomains, err := domains.New(cfg.BaseURL, cfg.Domains, cfg.DefaultDomain)
+	if err != nil {
+		return err
+	}
+	myLog.Infow("serving short domains", "domains", shortDomains.Names())
+
+	handler := &handlers.Handler{
+		BaseURL:       cfg.BaseURL,
+		Domains:       shortDomains,
+		TrustedSubnet: cfg.TrustedSubnet,
+		Store:         store,
+		Deletions:     deletions,
+		Health:        checker,
+		Limiter:       limiter,
+		ClientIP:      clientIPs,
+		Admin:         adminService,
+		URLOptions:    urlOpts,
+		Policy:        destPolicy,
+		Logger:        *myLog,
+	}
+	grpcService := grpchandlers.NewShortenerService(store, *myLog, cfg.TrustedSubnet, urlOpts, destPolicy, shortDomains)
+
+	reloader := reload.New(cfg, os.Args[1:], os.LookupEnv, *myLog,
+		handler,
+		grpcService,
+		clientIPs,
+		adminService,
+		limiter,
+		reload.TargetFunc(func(c *config.Config) {
+			if err := logger.SetLevel(c.LogLevel); err != nil {
+				myLog.Errorw("failed to change log level", "error", err)
+			}
+		}),
+	)
+	workers := []server.Worker{
+		reloader.Run,
+		func(ctx context.Context) {
+			checker.Run(ctx, health.DefaultInterval)
+		},
+		func(ctx context.Context) {
+			destPolicy.Run(ctx, cfg.ReloadInterval)
+		},
+	}
 
-	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("HTTP server ListenAndServe error: %v", err)
+	var tlsCfg *tls.Config
+	if cfg.EnableHTTPS {
+		certManager, err := cert.NewManager(cfg, *myLog)
+		if err != nil {
+			return err
+		}
+		tlsCfg, err = cert.NewTLSConfig(cfg, certManager)
+		if err != nil {
+			return err
 		}
-	}()
+		workers = append(workers, func(ctx context.Context) {
+			certManager.Run(ctx, cfg.ReloadInterval)
+		})
+	}
+
+	grpcServer := server.NewGRPCServer(tlsCfg, grpc.ChainUnaryInterceptor(
+		clientIPs.UnaryServerInterceptor(),
+		limiter.UnaryServerInterceptor(),
+	))
+	pb.RegisterShortenerServiceServer(grpcServer, grpcService)
+	pb.RegisterAdminServiceServer(grpcServer, grpchandlers.NewAdminService(adminService, shortDomains))
+	healthpb.RegisterHealthServer(grpcServer, checker.GRPC())
+
+	app := &server.App{
+		Config:    cfg,
+		Log:       *myLog,
+		Store:     store,
+		Deletions: deletions,
+		Health:    checker,
+		REST:      handler.InitRoutes(),
+		GRPC:      grpcServer,
+		TLS:       tlsCfg,
+		Workers:   workers,
+	}
 
-	sig := <-sigint
-	log.Printf("Received signal: %v", sig)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	defer stop()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	runErr := app.Run(ctx)
 
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Printf("HTTP server Shutdown error: %v", err)
+	tracingCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		myLog.Errorw("tracing shutdown error", "error", err)
 	}
 
-	runGRPCServer(store)
+	return runErr
 }
@@ -0,0 +1,198 @@
+// Package admin implements operator actions shared by REST and gRPC admin APIs.
+// Callers must come from trusted subnet and present admin token, every action
+// is recorded in audit trail
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+	"github.com/gsk148/urlShorteningService/internal/app/clientip"
+	"github.com/gsk148/urlShorteningService/internal/app/config"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+	"github.com/gsk148/urlShorteningService/internal/app/transfer"
+)
+
+var (
+	// ErrDisabled returned by Authorize when admin token is not configured
+	ErrDisabled = errors.New("admin API is disabled")
+	// ErrUntrusted returned by Authorize for clients outside of trusted subnet
+	ErrUntrusted = errors.New("client is not in trusted subnet")
+	// ErrBadToken returned by Authorize for missing or wrong token
+	ErrBadToken = errors.New("invalid admin token")
+	// ErrInvalidArgument returned for empty targets of actions
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+// Audit actions
+const (
+	ActionGetLink        = "get_link"
+	ActionUpdateLink     = "update_link"
+	ActionDeleteByDomain = "delete_by_domain"
+	ActionListUserLinks  = "list_user_links"
+	ActionExport         = "export"
+	ActionImport         = "import"
+)
+
+// Service performs operator actions
+type Service struct {
+	store storage.Storage
+	trail *Trail
+
+	mu      sync.RWMutex
+	token   string
+	subnets string
+}
+
+// New return Service object
+func New(store storage.Storage, trail *Trail, cfg *config.Config) *Service {
+	s := &Service{store: store, trail: trail}
+	s.ApplyConfig(cfg)
+	return s
+}
+
+// ApplyConfig swaps admin token and trusted subnets
+func (s *Service) ApplyConfig(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = cfg.AdminToken
+	s.subnets = cfg.TrustedSubnet
+}
+
+// Authorize checks client address and token presented by caller
+func (s *Service) Authorize(ip net.IP, token string) error {
+	s.mu.RLock()
+	want, subnets := s.token, s.subnets
+	s.mu.RUnlock()
+
+	if want == "" {
+		return ErrDisabled
+	}
+	trusted, err := clientip.ParseNets(subnets)
+	if err != nil || !trusted.Contains(ip) {
+		return ErrUntrusted
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(want)) != 1 {
+		return ErrBadToken
+	}
+	return nil
+}
+
+// BearerToken extracts token of "Bearer <token>" authorization value
+func BearerToken(authorization string) string {
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// Trail returns audit trail of service
+func (s *Service) Trail() *Trail {
+	return s.trail
+}
+
+func (s *Service) record(actor, action, target string, params map[string]string, err error) {
+	e := api.AuditEntry{
+		Time:   time.Now().UTC(),
+		Actor:  actor,
+		Action: action,
+		Target: target,
+		Params: params,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	s.trail.Record(e)
+}
+
+// GetLink returns any link with its owner
+func (s *Service) GetLink(ctx context.Context, actor, shortURL string) (api.ShortenedData, error) {
+	data, err := s.store.Get(ctx, shortURL)
+	s.record(actor, ActionGetLink, shortURL, nil, err)
+	return data, err
+}
+
+// UpdateLink disables, enables or reassigns any link
+func (s *Service) UpdateLink(ctx context.Context, actor, shortURL string, upd api.AdminUpdate) (api.ShortenedData, error) {
+	params := map[string]string{}
+	if upd.Active != nil {
+		params["active"] = fmt.Sprint(*upd.Active)
+	}
+	if upd.UserID != nil {
+		params["user_id"] = *upd.UserID
+	}
+
+	var (
+		data api.ShortenedData
+		err  error
+	)
+	switch {
+	case upd.Active == nil && upd.UserID == nil:
+		err = fmt.Errorf("%w: nothing to update", ErrInvalidArgument)
+	case upd.UserID != nil && *upd.UserID == "":
+		err = fmt.Errorf("%w: empty user id", ErrInvalidArgument)
+	default:
+		data, err = s.store.AdminUpdate(ctx, shortURL, upd)
+	}
+	s.record(actor, ActionUpdateLink, shortURL, params, err)
+	return data, err
+}
+
+// DeleteByDomain marks deleted every link to domain or its subdomains
+func (s *Service) DeleteByDomain(ctx context.Context, actor, domain string) (int, error) {
+	var (
+		deleted int
+		err     error
+	)
+	if strings.Trim(domain, ". ") == "" {
+		err = fmt.Errorf("%w: empty domain", ErrInvalidArgument)
+	} else {
+		deleted, err = s.store.DeleteByDomain(ctx, domain)
+	}
+	s.record(actor, ActionDeleteByDomain, domain, map[string]string{"deleted": fmt.Sprint(deleted)}, err)
+	return deleted, err
+}
+
+// ListUserLinks returns page of links owned by user
+func (s *Service) ListUserLinks(ctx context.Context, actor, userID string, q api.URLListQuery) (api.URLListPage, error) {
+	page, err := s.store.ListByUserID(ctx, userID, q)
+	s.record(actor, ActionListUserLinks, userID, nil, err)
+	return page, err
+}
+
+// Export streams links of user, or all links when userID is empty, to w
+func (s *Service) Export(ctx context.Context, actor string, w io.Writer, format, userID string) (int, error) {
+	count, err := transfer.Export(ctx, s.store, w, format, userID)
+	if errors.Is(err, transfer.ErrUnknownFormat) {
+		err = fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	s.record(actor, ActionExport, userID, map[string]string{"format": format, "exported": fmt.Sprint(count)}, err)
+	return count, err
+}
+
+// Import stores links of r, records keep their owners
+func (s *Service) Import(ctx context.Context, actor string, r io.Reader, format string, opts transfer.ImportOptions,
+	report func(api.ImportResult)) (api.ImportSummary, error) {
+	opts.Owner = ""
+	summary, err := transfer.Import(ctx, s.store, r, format, opts, report)
+	if errors.Is(err, transfer.ErrUnknownFormat) {
+		err = fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	s.record(actor, ActionImport, "", map[string]string{
+		"format":  format,
+		"created": fmt.Sprint(summary.Created),
+		"renamed": fmt.Sprint(summary.Renamed),
+		"exists":  fmt.Sprint(summary.Exists),
+		"failed":  fmt.Sprint(summary.Failed),
+	}, err)
+	return summary, err
+}
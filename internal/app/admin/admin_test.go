@@ -0,0 +1,90 @@
+package admin
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+	"github.com/gsk148/urlShorteningService/internal/app/config"
+	"github.com/gsk148/urlShorteningService/internal/app/logger"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+)
+
+const testToken = "0123456789abcdef"
+
+func TestAuthorize(t *testing.T) {
+	cfg := config.Default()
+	s := New(storage.NewInMemoryStorage(), nil, cfg)
+	assert.ErrorIs(t, s.Authorize(net.ParseIP("127.0.0.1"), testToken), ErrDisabled)
+
+	cfg.AdminToken = testToken
+	cfg.TrustedSubnet = "127.0.0.0/8, fd00::/8"
+	s.ApplyConfig(cfg)
+	assert.NoError(t, s.Authorize(net.ParseIP("127.0.0.1"), testToken))
+	assert.NoError(t, s.Authorize(net.ParseIP("fd00::5"), testToken))
+	assert.ErrorIs(t, s.Authorize(net.ParseIP("203.0.113.1"), testToken), ErrUntrusted)
+	assert.ErrorIs(t, s.Authorize(net.ParseIP("127.0.0.1"), "wrong"), ErrBadToken)
+
+	assert.Equal(t, testToken, BearerToken("Bearer "+testToken))
+	assert.Empty(t, BearerToken("Basic "+testToken))
+}
+
+func TestActionsAreAudited(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	trail, err := NewTrail(path, *logger.NewLogger())
+	require.NoError(t, err)
+	store := storage.NewInMemoryStorage()
+	s := New(store, trail, config.Default())
+
+	_, err = store.Store(ctx, api.ShortenedData{UserID: "u1", ShortURL: "a", OriginalURL: "https://spam.example.com/x"})
+	require.NoError(t, err)
+	_, err = store.Store(ctx, api.ShortenedData{UserID: "u1", ShortURL: "b", OriginalURL: "https://good.org/"})
+	require.NoError(t, err)
+
+	owner, active := "u2", false
+	updated, err := s.UpdateLink(ctx, "127.0.0.1", "b", api.AdminUpdate{UserID: &owner, Active: &active})
+	require.NoError(t, err)
+	assert.Equal(t, "u2", updated.UserID)
+	assert.True(t, updated.Disabled)
+
+	_, err = s.UpdateLink(ctx, "127.0.0.1", "b", api.AdminUpdate{})
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+
+	deleted, err := s.DeleteByDomain(ctx, "127.0.0.1", "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	link, err := s.GetLink(ctx, "127.0.0.1", "a")
+	require.NoError(t, err)
+	assert.True(t, link.IsDeleted)
+
+	recent := trail.Recent(10)
+	require.Len(t, recent, 4)
+	assert.Equal(t, ActionGetLink, recent[0].Action)
+	assert.Equal(t, "1", recent[1].Params["deleted"])
+	assert.NotEmpty(t, recent[2].Error)
+	assert.Equal(t, "u2", recent[3].Params["user_id"])
+	assert.Len(t, trail.Recent(2), 2)
+
+	require.NoError(t, trail.Close())
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"action":"delete_by_domain"`)
+}
+
+func TestTrailKeepsLatest(t *testing.T) {
+	trail, err := NewTrail("", *logger.NewLogger())
+	require.NoError(t, err)
+	for i := 0; i < recentSize+5; i++ {
+		trail.Record(api.AuditEntry{Target: string(rune('a' + i%26))})
+	}
+	recent := trail.Recent(0)
+	assert.Len(t, recent, recentSize)
+	assert.Equal(t, string(rune('a'+(recentSize+4)%26)), recent[0].Target)
+}
@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+)
+
+// recentSize is number of audit entries kept in memory for the audit endpoint
+const recentSize = 1000
+
+// Trail records operator actions to log, optional JSON lines file and keeps recent ones in memory
+type Trail struct {
+	mu     sync.Mutex
+	file   *os.File
+	log    zap.SugaredLogger
+	recent []api.AuditEntry
+	next   int
+	full   bool
+}
+
+// NewTrail return Trail object, entries are appended to path when it is set
+func NewTrail(path string, log zap.SugaredLogger) (*Trail, error) {
+	t := &Trail{log: log, recent: make([]api.AuditEntry, recentSize)}
+	if path == "" {
+		return t, nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	t.file = file
+	return t, nil
+}
+
+// Record saves entry, failures of audit file are logged and do not fail the action
+func (t *Trail) Record(e api.AuditEntry) {
+	t.log.Infow("admin action",
+		"actor", e.Actor,
+		"action", e.Action,
+		"target", e.Target,
+		"params", e.Params,
+		"error", e.Error)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.recent[t.next] = e
+	t.next = (t.next + 1) % len(t.recent)
+	if t.next == 0 {
+		t.full = true
+	}
+	if t.file == nil {
+		return
+	}
+	line, err := json.Marshal(e)
+	if err == nil {
+		_, err = t.file.Write(append(line, '\n'))
+	}
+	if err != nil {
+		t.log.Errorw("failed to write audit entry", "error", err)
+	}
+}
+
+// Recent returns up to limit latest entries, newest first
+func (t *Trail) Recent(limit int) []api.AuditEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	size := t.next
+	if t.full {
+		size = len(t.recent)
+	}
+	if limit <= 0 || limit > size {
+		limit = size
+	}
+	entries := make([]api.AuditEntry, 0, limit)
+	for i := 1; i <= limit; i++ {
+		entries = append(entries, t.recent[(t.next-i+len(t.recent))%len(t.recent)])
+	}
+	return entries
+}
+
+// Close closes audit file
+func (t *Trail) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file == nil {
+		return nil
+	}
+	err := t.file.Close()
+	t.file = nil
+	return err
+}
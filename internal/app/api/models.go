@@ -1,8 +1,18 @@
 package api
 
+import (
+	"strings"
+	"time"
+)
+
 // ShortenRequest model for /api/shorten request
 type ShortenRequest struct {
 	URL string `json:"url"`
+	// Domain is short domain of new link, default domain when empty
+	Domain       string `json:"domain,omitempty"`
+	RedirectType string `json:"redirect_type,omitempty"`
+	PassQuery    bool   `json:"pass_query,omitempty"`
+	UTM          *UTM   `json:"utm,omitempty"`
 }
 
 // ShortenResponse model for /api/shorten response
@@ -14,6 +24,10 @@ type ShortenResponse struct {
 type BatchShortenRequestItem struct {
 	CorrelationID string `json:"correlation_id"`
 	OriginalURL   string `json:"original_url"`
+	Domain        string `json:"domain,omitempty"`
+	RedirectType  string `json:"redirect_type,omitempty"`
+	PassQuery     bool   `json:"pass_query,omitempty"`
+	UTM           *UTM   `json:"utm,omitempty"`
 }
 
 // BatchShortenResponseItem model for batch response
@@ -24,8 +38,44 @@ type BatchShortenResponseItem struct {
 
 // Statistic model for statistic response
 type Statistic struct {
-	URLs  int `json:"urls"`
-	Users int `json:"users"`
+	URLs      int   `json:"urls"`
+	Users     int   `json:"users"`
+	Active    int   `json:"active"`
+	Deleted   int   `json:"deleted"`
+	Redirects int64 `json:"redirects"`
+	// CreatedPerDay is ordered by day, days without links are skipped
+	CreatedPerDay []DayCount    `json:"created_per_day"`
+	TopDomains    []DomainCount `json:"top_domains"`
+	TopLinks      []LinkClicks  `json:"top_links"`
+}
+
+// DayCount model for number of links created at UTC day
+type DayCount struct {
+	Day   string `json:"day"`
+	Count int    `json:"count"`
+}
+
+// DomainCount model for number of links to destination host
+type DomainCount struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
+}
+
+// LinkClicks model for redirects of single link, ShortURL is LinkKey of link
+type LinkClicks struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+	Clicks      int64  `json:"clicks"`
+}
+
+// StatisticQuery model for statistic window. Links created in [From, To) are counted,
+// zero bounds are open. Clicks are counted per link, so redirects of counted links
+// made outside of window are included
+type StatisticQuery struct {
+	From time.Time
+	To   time.Time
+	// Top limits top domains and links
+	Top int
 }
 
 // URLInfo model for url info
@@ -34,15 +84,331 @@ type URLInfo struct {
 	UserID        string `json:"userID,omitempty"`
 	CorrelationID string `json:"correlation_id,omitempty"`
 	OriginalURL   string `json:"original_url,omitempty"`
+	Domain        string `json:"domain,omitempty"`
 	ShortURL      string `json:"short_url,omitempty"`
 	IsDeleted     bool   `json:"is_deleted,omitempty"`
 }
 
-// ShortenedData model for url info
+// ShortenedData model for url info, Domain is empty for primary domain of base_url.
+// RedirectType, PassQuery and UTM describe redirect to destination, see Target
 type ShortenedData struct {
-	UserID      string `json:"userID"`
-	UUID        string `json:"uuid"`
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
-	IsDeleted   bool   `json:"is_deleted"`
+	UserID      string     `json:"userID"`
+	UUID        string     `json:"uuid"`
+	Domain      string     `json:"domain,omitempty"`
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	IsDeleted   bool       `json:"is_deleted"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Disabled    bool       `json:"disabled,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Clicks      int64      `json:"clicks,omitempty"`
+	// RedirectType is one of Redirect* constants, empty is RedirectTemporary
+	RedirectType string `json:"redirect_type,omitempty"`
+	PassQuery    bool   `json:"pass_query,omitempty"`
+	UTM          *UTM   `json:"utm,omitempty"`
+}
+
+// LinkKey returns key identifying link in storage: short url on primary domain,
+// domain/short on other domains. Short urls never contain slash
+func LinkKey(domain string, shortURL string) string {
+	if domain == "" {
+		return shortURL
+	}
+	return domain + "/" + shortURL
+}
+
+// SplitLinkKey returns domain and short url of key made by LinkKey
+func SplitLinkKey(key string) (domain string, shortURL string) {
+	if i := strings.LastIndexByte(key, '/'); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
+// Key returns storage key of link
+func (d ShortenedData) Key() string {
+	return LinkKey(d.Domain, d.ShortURL)
+}
+
+// Expired reports whether link expiration time has passed at now
+func (d ShortenedData) Expired(now time.Time) bool {
+	return d.ExpiresAt != nil && !now.Before(*d.ExpiresAt)
+}
+
+// URLUpdate model for partial link update, nil fields are kept
+type URLUpdate struct {
+	OriginalURL *string   `json:"original_url,omitempty"`
+	Title       *string   `json:"title,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	Tags        *[]string `json:"tags,omitempty"`
+	Active      *bool     `json:"active,omitempty"`
+	// ExpiresAt sets expiration time, zero time removes it
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Apply changes data according to update and reports whether destination changed
+func (u URLUpdate) Apply(data *ShortenedData) (retargeted bool) {
+	if u.OriginalURL != nil && *u.OriginalURL != data.OriginalURL {
+		data.OriginalURL = *u.OriginalURL
+		retargeted = true
+	}
+	if u.Title != nil {
+		data.Title = *u.Title
+	}
+	if u.Description != nil {
+		data.Description = *u.Description
+	}
+	if u.Tags != nil {
+		data.Tags = append([]string(nil), (*u.Tags)...)
+	}
+	if u.Active != nil {
+		data.Disabled = !*u.Active
+	}
+	if u.ExpiresAt != nil {
+		data.ExpiresAt = nil
+		if !u.ExpiresAt.IsZero() {
+			expiresAt := u.ExpiresAt.UTC()
+			data.ExpiresAt = &expiresAt
+		}
+	}
+	return retargeted
+}
+
+// URLHistoryEntry model for previous destination of short url
+type URLHistoryEntry struct {
+	ShortURL    string    `json:"short_url"`
+	UserID      string    `json:"-"`
+	OriginalURL string    `json:"original_url"`
+	ReplacedBy  string    `json:"replaced_by"`
+	ChangedAt   time.Time `json:"changed_at"`
+}
+
+// UserURLV1 model for link returned to its owner, shared by REST and gRPC.
+// Fields are only added here, incompatible changes go to the next version
+type UserURLV1 struct {
+	UUID        string     `json:"uuid"`
+	Domain      string     `json:"domain,omitempty"`
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Active      bool       `json:"active"`
+	IsDeleted   bool       `json:"is_deleted"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Clicks      int64      `json:"clicks"`
+	// RedirectType is empty for default temporary redirect
+	RedirectType string `json:"redirect_type,omitempty"`
+	PassQuery    bool   `json:"pass_query,omitempty"`
+	UTM          *UTM   `json:"utm,omitempty"`
+}
+
+// NewUserURLV1 return UserURLV1 object, short url is prefixed with baseURL when it is set
+func NewUserURLV1(data ShortenedData, baseURL string) UserURLV1 {
+	shortURL := data.ShortURL
+	if baseURL != "" {
+		shortURL = baseURL + "/" + data.ShortURL
+	}
+	return UserURLV1{
+		UUID:         data.UUID,
+		Domain:       data.Domain,
+		ShortURL:     shortURL,
+		OriginalURL:  data.OriginalURL,
+		Title:        data.Title,
+		Description:  data.Description,
+		Tags:         data.Tags,
+		Active:       !data.Disabled,
+		IsDeleted:    data.IsDeleted,
+		CreatedAt:    data.CreatedAt,
+		ExpiresAt:    data.ExpiresAt,
+		Clicks:       data.Clicks,
+		RedirectType: data.RedirectType,
+		PassQuery:    data.PassQuery,
+		UTM:          data.UTM,
+	}
+}
+
+// NewUserURLsV1 converts links with NewUserURLV1 prefixing them with baseURL of their domain
+func NewUserURLsV1(items []ShortenedData, baseURL func(domain string) string) []UserURLV1 {
+	res := make([]UserURLV1, 0, len(items))
+	for _, v := range items {
+		res = append(res, NewUserURLV1(v, baseURL(v.Domain)))
+	}
+	return res
+}
+
+// Sort orders of user links listing
+const (
+	SortCreated = "created"
+	SortClicks  = "clicks"
+)
+
+// URLListQuery model for filters and page of user links listing, nil filters match any link
+type URLListQuery struct {
+	Deleted *bool
+	Expired *bool
+	Tag     string
+	// Domain matches destination host and its subdomains
+	Domain string
+	// Search is case-insensitive substring of short url, destination, title or description
+	Search string
+	// Sort is SortCreated or SortClicks, newest or most clicked first unless Asc is set
+	Sort   string
+	Asc    bool
+	Limit  int
+	Cursor string
+}
+
+// URLListPage model for page of user links
+type URLListPage struct {
+	Items []ShortenedData
+	// Total is number of links matching filters on all pages
+	Total      int
+	NextCursor string
+}
+
+// AdminUpdate model for operator changes of any link, nil fields are kept
+type AdminUpdate struct {
+	Active *bool   `json:"active,omitempty"`
+	UserID *string `json:"user_id,omitempty"`
+}
+
+// Apply changes data according to update
+func (u AdminUpdate) Apply(data *ShortenedData) {
+	if u.Active != nil {
+		data.Disabled = !*u.Active
+	}
+	if u.UserID != nil {
+		data.UserID = *u.UserID
+	}
+}
+
+// AuditEntry model for operator action recorded in audit trail
+type AuditEntry struct {
+	Time   time.Time         `json:"time"`
+	Actor  string            `json:"actor"`
+	Action string            `json:"action"`
+	Target string            `json:"target"`
+	Params map[string]string `json:"params,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// LinkRecord model for exported and imported link, missing fields of imported
+// record take defaults
+type LinkRecord struct {
+	Domain       string     `json:"domain,omitempty"`
+	ShortURL     string     `json:"short_url,omitempty"`
+	OriginalURL  string     `json:"original_url"`
+	UserID       string     `json:"user_id,omitempty"`
+	Title        string     `json:"title,omitempty"`
+	Description  string     `json:"description,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
+	Disabled     bool       `json:"disabled,omitempty"`
+	IsDeleted    bool       `json:"is_deleted,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	Clicks       int64      `json:"clicks,omitempty"`
+	RedirectType string     `json:"redirect_type,omitempty"`
+	PassQuery    bool       `json:"pass_query,omitempty"`
+	UTM          *UTM       `json:"utm,omitempty"`
+}
+
+// NewLinkRecord return LinkRecord object
+func NewLinkRecord(data ShortenedData) LinkRecord {
+	rec := LinkRecord{
+		Domain:       data.Domain,
+		ShortURL:     data.ShortURL,
+		OriginalURL:  data.OriginalURL,
+		UserID:       data.UserID,
+		Title:        data.Title,
+		Description:  data.Description,
+		Tags:         data.Tags,
+		Disabled:     data.Disabled,
+		IsDeleted:    data.IsDeleted,
+		ExpiresAt:    data.ExpiresAt,
+		Clicks:       data.Clicks,
+		RedirectType: data.RedirectType,
+		PassQuery:    data.PassQuery,
+		UTM:          data.UTM,
+	}
+	if !data.CreatedAt.IsZero() {
+		createdAt := data.CreatedAt
+		rec.CreatedAt = &createdAt
+	}
+	return rec
+}
+
+// Data converts record to stored link
+func (r LinkRecord) Data() ShortenedData {
+	data := ShortenedData{
+		Domain:       r.Domain,
+		ShortURL:     r.ShortURL,
+		OriginalURL:  r.OriginalURL,
+		UserID:       r.UserID,
+		Title:        r.Title,
+		Description:  r.Description,
+		Tags:         r.Tags,
+		Disabled:     r.Disabled,
+		IsDeleted:    r.IsDeleted,
+		ExpiresAt:    r.ExpiresAt,
+		Clicks:       r.Clicks,
+		RedirectType: r.RedirectType,
+		PassQuery:    r.PassQuery,
+		UTM:          r.UTM,
+	}
+	if r.CreatedAt != nil {
+		data.CreatedAt = r.CreatedAt.UTC()
+	}
+	return data
+}
+
+// Import statuses of ImportResult
+const (
+	ImportCreated = "created"
+	ImportRenamed = "renamed"
+	ImportExists  = "exists"
+	ImportFailed  = "failed"
+)
+
+// ImportResult model for result of single imported line
+type ImportResult struct {
+	Line        int    `json:"line"`
+	Status      string `json:"status"`
+	ShortURL    string `json:"short_url,omitempty"`
+	OriginalURL string `json:"original_url,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ImportSummary model for counts of import statuses
+type ImportSummary struct {
+	Created int `json:"created"`
+	Renamed int `json:"renamed"`
+	Exists  int `json:"exists"`
+	Failed  int `json:"failed"`
+}
+
+// Add counts result
+func (s *ImportSummary) Add(r ImportResult) {
+	switch r.Status {
+	case ImportCreated:
+		s.Created++
+	case ImportRenamed:
+		s.Renamed++
+	case ImportExists:
+		s.Exists++
+	default:
+		s.Failed++
+	}
+}
+
+// ImportResponse model for response of import endpoints, Error is set when input
+// could not be read to the end
+type ImportResponse struct {
+	Summary ImportSummary  `json:"summary"`
+	Results []ImportResult `json:"results"`
+	Error   string         `json:"error,omitempty"`
 }
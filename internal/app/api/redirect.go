@@ -0,0 +1,154 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Redirect types of link, empty type is RedirectTemporary. Permanent types are
+// cached by browsers and proxies for PermanentRedirectMaxAge, so change, deactivation
+// or deletion of link reaches clients which opened it before only after that time
+const (
+	RedirectMovedPermanently = "301"
+	RedirectFound            = "302"
+	RedirectTemporary        = "307"
+	RedirectPermanent        = "308"
+	// RedirectPage answers with HTML page which opens destination by meta refresh
+	RedirectPage = "page"
+)
+
+// PermanentRedirectMaxAge limits how long clients keep 301 and 308 redirects
+const PermanentRedirectMaxAge = time.Hour
+
+var redirectStatuses = map[string]int{
+	"":                       http.StatusTemporaryRedirect,
+	RedirectMovedPermanently: http.StatusMovedPermanently,
+	RedirectFound:            http.StatusFound,
+	RedirectTemporary:        http.StatusTemporaryRedirect,
+	RedirectPermanent:        http.StatusPermanentRedirect,
+	RedirectPage:             http.StatusOK,
+}
+
+// CheckRedirectType returns error for unknown redirect type
+func CheckRedirectType(t string) error {
+	if _, ok := redirectStatuses[t]; !ok {
+		return fmt.Errorf("redirect_type %q: must be one of 301, 302, 307, 308, page", t)
+	}
+	return nil
+}
+
+// RedirectStatus returns response code of redirect type, pages are answered with 200
+func RedirectStatus(t string) int {
+	if status, ok := redirectStatuses[t]; ok {
+		return status
+	}
+	return http.StatusTemporaryRedirect
+}
+
+// RedirectCacheControl returns Cache-Control header of redirect type. Permanent
+// redirects expire after PermanentRedirectMaxAge, others are not stored so every
+// click reaches the service
+func RedirectCacheControl(t string) string {
+	switch t {
+	case RedirectMovedPermanently, RedirectPermanent:
+		return fmt.Sprintf("max-age=%d", int(PermanentRedirectMaxAge.Seconds()))
+	default:
+		return "no-store"
+	}
+}
+
+// SetRedirect checks redirect settings given at shorten time and copies them to link
+func (d *ShortenedData) SetRedirect(redirectType string, passQuery bool, utm *UTM) error {
+	if err := CheckRedirectType(redirectType); err != nil {
+		return err
+	}
+	d.RedirectType, d.PassQuery, d.UTM = redirectType, passQuery, utm
+	return nil
+}
+
+// UTM model for utm_* parameters appended to destination, empty ones are skipped
+type UTM struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+// Values returns utm_* query parameters
+func (u *UTM) Values() url.Values {
+	values := url.Values{}
+	if u == nil {
+		return values
+	}
+	for name, v := range map[string]string{
+		"utm_source":   u.Source,
+		"utm_medium":   u.Medium,
+		"utm_campaign": u.Campaign,
+		"utm_term":     u.Term,
+		"utm_content":  u.Content,
+	} {
+		if v != "" {
+			values.Set(name, v)
+		}
+	}
+	return values
+}
+
+// ParseUTM returns UTM of utm_* parameters of values or nil when there are none
+func ParseUTM(values url.Values) *UTM {
+	u := UTM{
+		Source:   values.Get("utm_source"),
+		Medium:   values.Get("utm_medium"),
+		Campaign: values.Get("utm_campaign"),
+		Term:     values.Get("utm_term"),
+		Content:  values.Get("utm_content"),
+	}
+	if u == (UTM{}) {
+		return nil
+	}
+	return &u
+}
+
+// Target returns destination of redirect. Parameters of query the short url was
+// opened with are passed when PassQuery is set, then UTM parameters are added.
+// Parameters already present in destination are never overridden, the destination
+// itself is kept byte for byte
+func (d ShortenedData) Target(query url.Values) string {
+	dest, fragment, hasFragment := strings.Cut(d.OriginalURL, "#")
+	_, rawQuery, _ := strings.Cut(dest, "?")
+	present, _ := url.ParseQuery(rawQuery)
+
+	extra := url.Values{}
+	if d.PassQuery {
+		for name, values := range query {
+			if !present.Has(name) {
+				extra[name] = values
+			}
+		}
+	}
+	for name, values := range d.UTM.Values() {
+		if !present.Has(name) && !extra.Has(name) {
+			extra[name] = values
+		}
+	}
+	if len(extra) == 0 {
+		return d.OriginalURL
+	}
+
+	sep := "&"
+	switch {
+	case !strings.Contains(dest, "?"):
+		sep = "?"
+	case strings.HasSuffix(dest, "?") || strings.HasSuffix(dest, "&"):
+		sep = ""
+	}
+	target := dest + sep + extra.Encode()
+	if hasFragment {
+		target += "#" + fragment
+	}
+	return target
+}
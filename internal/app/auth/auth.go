@@ -56,6 +56,26 @@ func GetUserToken(w http.ResponseWriter, r *http.Request) (string, error) {
 
 }
 
+// UserIDFromRequest returns userID of valid token cookie without issuing a new one
+func UserIDFromRequest(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return "", false
+	}
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(cookie.Value, claims,
+		func(t *jwt.Token) (interface{}, error) {
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+			}
+			return []byte(SecretKey), nil
+		})
+	if err != nil || !token.Valid || claims.UserID == "" {
+		return "", false
+	}
+	return claims.UserID, true
+}
+
 func generateCookie() (*http.Cookie, error) {
 	token, err := generateJWTString()
 	if err != nil {
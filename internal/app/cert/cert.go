@@ -0,0 +1,246 @@
+// Package cert provides TLS configuration for REST and gRPC servers
+// with certificate hot reload and self-signed certificate generation
+package cert
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/gsk148/urlShorteningService/internal/app/config"
+)
+
+// SelfSignedValidity is lifetime of generated certificates
+const SelfSignedValidity = 365 * 24 * time.Hour
+
+// Manager holds current server certificate and reloads it when files change
+type Manager struct {
+	certFile string
+	keyFile  string
+	log      zap.SugaredLogger
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewManager return Manager object.
+// Without certificate files an in-memory self-signed certificate is used.
+// With selfSigned set missing files are generated and saved first.
+func NewManager(cfg *config.Config, log zap.SugaredLogger) (*Manager, error) {
+	m := &Manager{
+		certFile: cfg.TLSCertFile,
+		keyFile:  cfg.TLSKeyFile,
+		log:      log,
+	}
+	hosts := certHosts(cfg)
+
+	if m.certFile == "" {
+		log.Warn("no TLS certificate configured, using in-memory self-signed certificate")
+		certPEM, keyPEM, err := GenerateSelfSigned(hosts)
+		if err != nil {
+			return nil, err
+		}
+		c, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, err
+		}
+		m.cert = &c
+		return m, nil
+	}
+
+	if cfg.TLSSelfSigned && !exists(m.certFile) && !exists(m.keyFile) {
+		log.Warnw("TLS certificate files not found, generating self-signed", "cert", m.certFile, "key", m.keyFile)
+		if err := writeSelfSigned(m.certFile, m.keyFile, hosts); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetCertificate returns current certificate, used as tls.Config.GetCertificate
+func (m *Manager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cert, nil
+}
+
+// Run checks certificate files every interval and reloads them when changed until ctx is done
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	if m.certFile == "" || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !m.changed() {
+				continue
+			}
+			if err := m.load(); err != nil {
+				m.log.Errorw("TLS certificate reload failed, keeping previous", "error", err)
+				continue
+			}
+			m.log.Infow("TLS certificate reloaded", "cert", m.certFile)
+		}
+	}
+}
+
+func (m *Manager) changed() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return !latestModTime(m.certFile, m.keyFile).Equal(m.modTime)
+}
+
+func (m *Manager) load() error {
+	modTime := latestModTime(m.certFile, m.keyFile)
+	c, err := tls.LoadX509KeyPair(m.certFile, m.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS key pair: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cert = &c
+	m.modTime = modTime
+	return nil
+}
+
+// NewTLSConfig builds server TLS settings: minimal version, cipher suites and,
+// when TLSClientCAFile is set, mandatory client certificate verification
+func NewTLSConfig(cfg *config.Config, m *Manager) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		MinVersion:     config.TLSVersions[cfg.TLSMinVersion],
+		CipherSuites:   cfg.CipherSuites(),
+		GetCertificate: m.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		caPEM, err := os.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("client CA file contains no certificates")
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsCfg, nil
+}
+
+// GenerateSelfSigned returns PEM encoded ECDSA certificate and key valid for hosts
+func GenerateSelfSigned(hosts []string) (certPEM []byte, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"URL shortener development"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(SelfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+func writeSelfSigned(certFile, keyFile string, hosts []string) error {
+	certPEM, keyPEM, err := GenerateSelfSigned(hosts)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(certFile, certPEM, 0644)
+}
+
+// certHosts collects names the service is reachable by
+func certHosts(cfg *config.Config) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if host, _, err := net.SplitHostPort(cfg.ServerAddr); err == nil && host != "" {
+		hosts = append(hosts, host)
+	}
+	for _, base := range append([]string{cfg.BaseURL}, cfg.Domains...) {
+		if u, err := url.Parse(base); err == nil && u.Hostname() != "" {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+
+	seen := map[string]bool{}
+	result := hosts[:0]
+	for _, h := range hosts {
+		if !seen[h] {
+			seen[h] = true
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func latestModTime(paths ...string) time.Time {
+	var latest time.Time
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
@@ -0,0 +1,90 @@
+package cert
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gsk148/urlShorteningService/internal/app/config"
+	"github.com/gsk148/urlShorteningService/internal/app/logger"
+)
+
+func leaf(t *testing.T, m *Manager) *x509.Certificate {
+	c, err := m.GetCertificate(nil)
+	require.NoError(t, err)
+	parsed, err := x509.ParseCertificate(c.Certificate[0])
+	require.NoError(t, err)
+	return parsed
+}
+
+func TestInMemorySelfSigned(t *testing.T) {
+	cfg := config.Default()
+	cfg.BaseURL = "https://short.example"
+
+	m, err := NewManager(cfg, *logger.NewLogger())
+	require.NoError(t, err)
+	assert.Contains(t, leaf(t, m).DNSNames, "short.example")
+	assert.Contains(t, leaf(t, m).DNSNames, "localhost")
+}
+
+func TestGeneratedFilesAndReload(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.TLSCertFile = filepath.Join(dir, "server.crt")
+	cfg.TLSKeyFile = filepath.Join(dir, "server.key")
+	cfg.TLSSelfSigned = true
+
+	m, err := NewManager(cfg, *logger.NewLogger())
+	require.NoError(t, err)
+	first := leaf(t, m).SerialNumber
+	assert.FileExists(t, cfg.TLSCertFile)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx, 10*time.Millisecond)
+
+	certPEM, keyPEM, err := GenerateSelfSigned([]string{"localhost"})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(cfg.TLSKeyFile, keyPEM, 0600))
+	require.NoError(t, os.WriteFile(cfg.TLSCertFile, certPEM, 0644))
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(cfg.TLSCertFile, later, later))
+
+	assert.Eventually(t, func() bool {
+		return leaf(t, m).SerialNumber.Cmp(first) != 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMissingFilesWithoutSelfSigned(t *testing.T) {
+	cfg := config.Default()
+	cfg.TLSCertFile = filepath.Join(t.TempDir(), "server.crt")
+	cfg.TLSKeyFile = filepath.Join(t.TempDir(), "server.key")
+
+	_, err := NewManager(cfg, *logger.NewLogger())
+	assert.Error(t, err)
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	caPEM, _, err := GenerateSelfSigned([]string{"client"})
+	require.NoError(t, err)
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, caPEM, 0644))
+
+	cfg := config.Default()
+	cfg.TLSMinVersion = "1.3"
+	cfg.TLSClientCAFile = caFile
+
+	m, err := NewManager(cfg, *logger.NewLogger())
+	require.NoError(t, err)
+	tlsCfg, err := NewTLSConfig(cfg, m)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), tlsCfg.MinVersion)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, tlsCfg.ClientAuth)
+}
@@ -0,0 +1,246 @@
+// Package clientip resolves address of the client behind trusted reverse proxies.
+// Forwarding headers are followed from the closest hop only while hops are trusted
+// proxies, so clients cannot choose their address by sending the headers themselves
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/gsk148/urlShorteningService/internal/app/config"
+)
+
+// Nets is list of networks, IPv4 and IPv6 may be mixed
+type Nets []*net.IPNet
+
+// ParseNets parses comma separated CIDRs or single addresses
+func ParseNets(specs ...string) (Nets, error) {
+	var nets Nets
+	for _, spec := range specs {
+		for _, s := range strings.Split(spec, ",") {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			if !strings.Contains(s, "/") {
+				ip := net.ParseIP(s)
+				if ip == nil {
+					return nil, fmt.Errorf("invalid address %q", s)
+				}
+				bits := 8 * net.IPv6len
+				if ip4 := ip.To4(); ip4 != nil {
+					ip, bits = ip4, 8*net.IPv4len
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+			_, n, err := net.ParseCIDR(s)
+			if err != nil {
+				return nil, err
+			}
+			nets = append(nets, n)
+		}
+	}
+	return nets, nil
+}
+
+// Contains reports whether ip belongs to one of networks
+func (n Nets) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range n {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolver finds client address using list of trusted proxies and the only forwarding
+// header they manage. Other forwarding headers are ignored, the proxy passes them
+// from client unchanged
+type Resolver struct {
+	mu      sync.RWMutex
+	proxies Nets
+	header  string
+}
+
+// NewResolver return Resolver object, without proxies forwarding headers are ignored.
+// header is one of Forwarded, X-Forwarded-For or X-Real-IP
+func NewResolver(proxies []string, header string) (*Resolver, error) {
+	nets, err := ParseNets(proxies...)
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{proxies: nets, header: header}, nil
+}
+
+// ApplyConfig swaps trusted proxies and forwarding header, config is validated before reload
+func (r *Resolver) ApplyConfig(cfg *config.Config) {
+	nets, err := ParseNets(cfg.TrustedProxies...)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.proxies = nets
+	r.header = cfg.ForwardedHeader
+}
+
+func (r *Resolver) trusted() (Nets, string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.proxies, r.header
+}
+
+// Resolve returns client address for connection from remoteAddr. hops are addresses
+// from forwarding headers, client first. They are walked from the closest one while
+// the sender is trusted proxy
+func (r *Resolver) Resolve(remoteAddr string, hops []string) net.IP {
+	client := parseAddr(remoteAddr)
+	if client == nil {
+		return nil
+	}
+	proxies, _ := r.trusted()
+	for i := len(hops) - 1; i >= 0 && proxies.Contains(client); i-- {
+		hop := parseAddr(hops[i])
+		if hop == nil {
+			// obfuscated or malformed hop, the last trusted proxy is the best we know
+			break
+		}
+		client = hop
+	}
+	return client
+}
+
+// FromHTTP resolves client address of request using configured forwarding header
+func (r *Resolver) FromHTTP(req *http.Request) net.IP {
+	_, header := r.trusted()
+	return r.Resolve(req.RemoteAddr, headerHops(header, req.Header.Values))
+}
+
+// FromGRPC resolves client address of call using peer and forwarding metadata
+func (r *Resolver) FromGRPC(ctx context.Context) net.IP {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	_, header := r.trusted()
+	return r.Resolve(p.Addr.String(), headerHops(header, md.Get))
+}
+
+// Middleware stores resolved client address in request context
+func (r *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if ip := r.FromHTTP(req); ip != nil {
+			req = req.WithContext(NewContext(req.Context(), ip))
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// UnaryServerInterceptor stores resolved client address in call context
+func (r *Resolver) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if ip := r.FromGRPC(ctx); ip != nil {
+			ctx = NewContext(ctx, ip)
+		}
+		return handler(ctx, req)
+	}
+}
+
+type ctxKey struct{}
+
+// NewContext returns context carrying client address
+func NewContext(ctx context.Context, ip net.IP) context.Context {
+	return context.WithValue(ctx, ctxKey{}, ip)
+}
+
+// FromContext returns client address stored by Middleware or UnaryServerInterceptor
+func FromContext(ctx context.Context) (net.IP, bool) {
+	ip, ok := ctx.Value(ctxKey{}).(net.IP)
+	return ip, ok
+}
+
+// FromRequest returns resolved client address of request, connection address
+// is used when resolver middleware is not installed
+func FromRequest(req *http.Request) net.IP {
+	if ip, ok := FromContext(req.Context()); ok {
+		return ip
+	}
+	return parseAddr(req.RemoteAddr)
+}
+
+// FromCall returns resolved client address of gRPC call, peer address is used
+// when resolver interceptor is not installed
+func FromCall(ctx context.Context) net.IP {
+	if ip, ok := FromContext(ctx); ok {
+		return ip
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return parseAddr(p.Addr.String())
+	}
+	return nil
+}
+
+// headerHops returns forwarded addresses of header, client first
+func headerHops(header string, values func(name string) []string) []string {
+	switch strings.ToLower(header) {
+	case "forwarded":
+		return forwardedFor(values("Forwarded"))
+	case "x-forwarded-for":
+		var hops []string
+		for _, v := range values("X-Forwarded-For") {
+			for _, hop := range strings.Split(v, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+		return hops
+	case "x-real-ip":
+		if realIP := values("X-Real-IP"); len(realIP) > 0 {
+			return realIP[len(realIP)-1:]
+		}
+	}
+	return nil
+}
+
+// forwardedFor extracts for= parameters of RFC 7239 Forwarded header
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			hop := ""
+			for _, pair := range strings.Split(element, ";") {
+				name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(name, "for") {
+					hop = strings.Trim(value, `"`)
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// parseAddr parses address with optional port, IPv6 may be in brackets
+func parseAddr(addr string) net.IP {
+	addr = strings.TrimSpace(addr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	if i := strings.IndexByte(addr, '%'); i >= 0 {
+		addr = addr[:i]
+	}
+	return net.ParseIP(addr)
+}
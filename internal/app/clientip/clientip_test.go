@@ -0,0 +1,137 @@
+package clientip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestParseNets(t *testing.T) {
+	nets, err := ParseNets("10.0.0.0/8, 2001:db8::/32", "192.0.2.7,::1")
+	require.NoError(t, err)
+	assert.True(t, nets.Contains(net.ParseIP("10.1.2.3")))
+	assert.True(t, nets.Contains(net.ParseIP("2001:db8::5")))
+	assert.True(t, nets.Contains(net.ParseIP("192.0.2.7")))
+	assert.False(t, nets.Contains(net.ParseIP("192.0.2.8")))
+	assert.True(t, nets.Contains(net.ParseIP("::1")))
+	assert.False(t, nets.Contains(nil))
+
+	_, err = ParseNets("10.0.0.0/33")
+	assert.Error(t, err)
+	_, err = ParseNets("proxy.local")
+	assert.Error(t, err)
+}
+
+func TestFromHTTP(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:    "untrusted remote ignores headers",
+			remote:  "203.0.113.9:5000",
+			headers: map[string]string{"X-Forwarded-For": "127.0.0.1", "X-Real-IP": "127.0.0.1"},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "walks trusted hops only",
+			remote:  "10.0.0.2:5000",
+			headers: map[string]string{"X-Forwarded-For": "127.0.0.1, 198.51.100.4, 10.0.0.3"},
+			want:    "198.51.100.4",
+		},
+		{
+			name:    "all hops trusted",
+			remote:  "10.0.0.2:5000",
+			headers: map[string]string{"X-Forwarded-For": "10.0.0.7"},
+			want:    "10.0.0.7",
+		},
+		{
+			name:    "forwarded header with ipv6",
+			header:  "Forwarded",
+			remote:  "[fd00::1]:443",
+			headers: map[string]string{"Forwarded": `for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711"`},
+			want:    "2001:db8:cafe::17",
+		},
+		{
+			name:    "obfuscated hop stops walk",
+			header:  "Forwarded",
+			remote:  "10.0.0.2:5000",
+			headers: map[string]string{"Forwarded": "for=198.51.100.1, for=_hidden"},
+			want:    "10.0.0.2",
+		},
+		{
+			name:    "real ip from trusted proxy",
+			header:  "X-Real-IP",
+			remote:  "10.0.0.2:5000",
+			headers: map[string]string{"X-Real-IP": "198.51.100.8"},
+			want:    "198.51.100.8",
+		},
+		{
+			name:   "spoofed forwarded next to proxy appended xff",
+			remote: "10.0.0.2:5000",
+			headers: map[string]string{
+				"Forwarded":       "for=10.0.0.1",
+				"X-Forwarded-For": "198.51.100.4",
+			},
+			want: "198.51.100.4",
+		},
+		{
+			name:   "spoofed xff next to proxy set real ip",
+			header: "X-Real-IP",
+			remote: "10.0.0.2:5000",
+			headers: map[string]string{
+				"X-Forwarded-For": "10.0.0.1",
+				"X-Real-IP":       "198.51.100.8",
+			},
+			want: "198.51.100.8",
+		},
+		{
+			name:    "headers other than configured are ignored",
+			remote:  "10.0.0.2:5000",
+			headers: map[string]string{"Forwarded": "for=10.0.0.1", "X-Real-IP": "10.0.0.1"},
+			want:    "10.0.0.2",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := test.header
+			if header == "" {
+				header = "X-Forwarded-For"
+			}
+			r, err := NewResolver([]string{"10.0.0.0/8", "fd00::/8"}, header)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = test.remote
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			assert.Equal(t, test.want, r.FromHTTP(req).String())
+		})
+	}
+}
+
+func TestFromGRPC(t *testing.T) {
+	r, err := NewResolver([]string{"10.0.0.1"}, "X-Forwarded-For")
+	require.NoError(t, err)
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 9000}})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-forwarded-for", "198.51.100.2"))
+	assert.Equal(t, "198.51.100.2", r.FromGRPC(ctx).String())
+
+	_, err = r.UnaryServerInterceptor()(ctx, nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		assert.Equal(t, "198.51.100.2", FromCall(ctx).String())
+		return nil, nil
+	})
+	require.NoError(t, err)
+}
@@ -1,88 +1,479 @@
+// Package config loads service configuration from defaults, config file, environment and flags
 package config
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/gsk148/urlShorteningService/internal/app/domains"
 )
 
-// Config contains environment variables which should be set
+// Config contains environment variables which should be set.
+// Sources are applied in order defaults < file < env < flags.
 type Config struct {
-	ServerAddr      string `json:"server_address"`
-	BaseURL         string `json:"base_url"`
-	FileStoragePath string `json:"file_storage_path"`
-	DatabaseDSN     string `json:"database_dsn"`
-	EnableHTTPS     bool   `json:"enable_https" env:"ENABLE_HTTPS" envDefault:"false"`
-	StorageType     string
-	Config          string `env:"CONFIG"`
-	TrustedSubnet   string `json:"trusted_subnet" env:"TRUSTED_SUBNET"`
+	ServerAddr        string                `json:"server_address" env:"SERVER_ADDRESS"`
+	GRPCAddr          string                `json:"grpc_address" env:"GRPC_ADDRESS"`
+	BaseURL           string                `json:"base_url" env:"BASE_URL"`
+	Domains           []string              `json:"domains" env:"DOMAINS"`
+	DefaultDomain     string                `json:"default_domain" env:"DEFAULT_DOMAIN"`
+	FileStoragePath   string                `json:"file_storage_path" env:"FILE_STORAGE_PATH"`
+	BoltPath          string                `json:"bolt_path" env:"BOLT_PATH"`
+	SQLitePath        string                `json:"sqlite_path" env:"SQLITE_PATH"`
+	DatabaseDSN       string                `json:"database_dsn" env:"DATABASE_DSN" secret:"dsn"`
+	EnableHTTPS       bool                  `json:"enable_https" env:"ENABLE_HTTPS"`
+	StorageType       string                `json:"storage_type" env:"STORAGE_TYPE"`
+	DBMaxOpenConns    int                   `json:"db_max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	DBMaxIdleConns    int                   `json:"db_max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	DBConnMaxLifetime time.Duration         `json:"db_conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	DBConnMaxIdleTime time.Duration         `json:"db_conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+	DBConnectTimeout  time.Duration         `json:"db_connect_timeout" env:"DB_CONNECT_TIMEOUT"`
+	DBReplicas        []string              `json:"db_replicas" env:"DB_REPLICAS" secret:"dsn"`
+	DBReplicaInterval time.Duration         `json:"db_replica_interval" env:"DB_REPLICA_INTERVAL"`
+	CacheSize         int                   `json:"cache_size" env:"CACHE_SIZE"`
+	CacheTTL          time.Duration         `json:"cache_ttl" env:"CACHE_TTL"`
+	CacheNegativeTTL  time.Duration         `json:"cache_negative_ttl" env:"CACHE_NEGATIVE_TTL"`
+	Config            string                `json:"-" env:"CONFIG"`
+	TrustedSubnet     string                `json:"trusted_subnet" env:"TRUSTED_SUBNET" reload:"true"`
+	TrustedProxies    []string              `json:"trusted_proxies" env:"TRUSTED_PROXIES" reload:"true"`
+	ForwardedHeader   string                `json:"forwarded_header" env:"FORWARDED_HEADER" reload:"true"`
+	AdminToken        string                `json:"admin_token" env:"ADMIN_TOKEN" secret:"true" reload:"true"`
+	AuditLogFile      string                `json:"audit_log_file" env:"AUDIT_LOG_FILE"`
+	OTLPEndpoint      string                `json:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	OTLPInsecure      bool                  `json:"otlp_insecure" env:"OTEL_EXPORTER_OTLP_INSECURE"`
+	TLSCertFile       string                `json:"tls_cert_file" env:"TLS_CERT_FILE"`
+	TLSKeyFile        string                `json:"tls_key_file" env:"TLS_KEY_FILE"`
+	TLSSelfSigned     bool                  `json:"tls_self_signed" env:"TLS_SELF_SIGNED"`
+	TLSMinVersion     string                `json:"tls_min_version" env:"TLS_MIN_VERSION"`
+	TLSCipherSuites   []string              `json:"tls_cipher_suites" env:"TLS_CIPHER_SUITES"`
+	TLSClientCAFile   string                `json:"tls_client_ca_file" env:"TLS_CLIENT_CA_FILE"`
+	LogLevel          string                `json:"log_level" env:"LOG_LEVEL" reload:"true"`
+	ReloadInterval    time.Duration         `json:"reload_interval" env:"RELOAD_INTERVAL"`
+	ShutdownTimeout   time.Duration         `json:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	FlushTimeout      time.Duration         `json:"flush_timeout" env:"FLUSH_TIMEOUT"`
+	AllowedSchemes    []string              `json:"allowed_schemes" env:"ALLOWED_SCHEMES"`
+	MaxURLLength      int                   `json:"max_url_length" env:"MAX_URL_LENGTH"`
+	BlocklistFile     string                `json:"blocklist_file" env:"BLOCKLIST_FILE"`
+	AllowlistFile     string                `json:"allowlist_file" env:"ALLOWLIST_FILE"`
+	ReputationFile    string                `json:"reputation_file" env:"REPUTATION_FILE"`
+	BlockPrivateIPs   bool                  `json:"block_private_ips" env:"BLOCK_PRIVATE_IPS"`
+	RateLimit         float64               `json:"rate_limit" env:"RATE_LIMIT" reload:"true"`
+	RateLimitBurst    int                   `json:"rate_limit_burst" env:"RATE_LIMIT_BURST" reload:"true"`
+	RateLimitRoutes   map[string]RouteLimit `json:"rate_limit_routes" reload:"true"`
+	PrintConfig       bool                  `json:"-"`
 }
 
-// Load gets env vars from arguments or environment
-func Load() *Config {
-	cfg := &Config{}
-	flag.StringVar(&cfg.ServerAddr, "a", "localhost:8080", "The starting server address (format: host:port)")
-	flag.StringVar(&cfg.BaseURL, "b", "http://localhost:8080", "Returned address: net address host:port")
-	flag.StringVar(&cfg.StorageType, "storage", "file", "type of storage to use (memory/file)")
-	flag.StringVar(&cfg.FileStoragePath, "f", "/tmp/short-url-db.json", "File storage path")
-	flag.StringVar(&cfg.DatabaseDSN, "d", "", "Database host")
-	flag.BoolVar(&cfg.EnableHTTPS, "s", cfg.EnableHTTPS, "Enable HTTPS server mode")
-	flag.StringVar(&cfg.Config, "c", "", "JSON config file")
-	flag.StringVar(&cfg.TrustedSubnet, "t", cfg.TrustedSubnet, "Enable trusted service subnet")
-	flag.Parse()
+// RouteLimit is token bucket of single route: Rate requests per second refill
+// Burst tokens. Zero Rate disables limiting of the route.
+type RouteLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
 
-	if envRunAddr := os.Getenv("SERVER_ADDRESS"); envRunAddr != "" {
-		cfg.ServerAddr = envRunAddr
+// Default returns configuration used when no other source sets a value
+func Default() *Config {
+	return &Config{
+		ServerAddr:        "localhost:8080",
+		GRPCAddr:          ":3200",
+		BaseURL:           "http://localhost:8080",
+		FileStoragePath:   "/tmp/short-url-db.json",
+		BoltPath:          "/tmp/short-url-db.bolt",
+		SQLitePath:        "/tmp/short-url-db.sqlite",
+		DBMaxOpenConns:    25,
+		DBMaxIdleConns:    10,
+		DBConnMaxLifetime: 30 * time.Minute,
+		DBConnMaxIdleTime: 5 * time.Minute,
+		DBConnectTimeout:  30 * time.Second,
+		DBReplicaInterval: 5 * time.Second,
+		CacheTTL:          time.Minute,
+		CacheNegativeTTL:  10 * time.Second,
+		TLSMinVersion:     "1.2",
+		LogLevel:          "debug",
+		ReloadInterval:    5 * time.Second,
+		ShutdownTimeout:   10 * time.Second,
+		FlushTimeout:      10 * time.Second,
+		AllowedSchemes:    []string{"http", "https"},
+		MaxURLLength:      2048,
+		BlockPrivateIPs:   true,
+		RateLimitBurst:    20,
+		ForwardedHeader:   "X-Forwarded-For",
 	}
-	if envBaseAddr := os.Getenv("BASE_URL"); envBaseAddr != "" {
-		cfg.BaseURL = envBaseAddr
+}
+
+func bindFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.ServerAddr, "a", cfg.ServerAddr, "The starting server address (format: host:port)")
+	fs.StringVar(&cfg.GRPCAddr, "g", cfg.GRPCAddr, "The gRPC server address (format: host:port)")
+	fs.StringVar(&cfg.BaseURL, "b", cfg.BaseURL, "Returned address: net address host:port")
+	fs.Var(newListValue(&cfg.Domains), "domains", "Comma separated base URLs of additional short domains")
+	fs.StringVar(&cfg.DefaultDomain, "default-domain", cfg.DefaultDomain, "Host of short domain used when user does not choose one, empty is base URL host")
+	fs.StringVar(&cfg.StorageType, "storage", cfg.StorageType, "type of storage to use (memory/file/bolt/sqlite/db), db if -d is set")
+	fs.StringVar(&cfg.BoltPath, "bolt-path", cfg.BoltPath, "Embedded bolt database path")
+	fs.StringVar(&cfg.SQLitePath, "sqlite-path", cfg.SQLitePath, "SQLite database path")
+	fs.StringVar(&cfg.FileStoragePath, "f", cfg.FileStoragePath, "File storage path")
+	fs.StringVar(&cfg.DatabaseDSN, "d", cfg.DatabaseDSN, "Database host")
+	fs.IntVar(&cfg.DBMaxOpenConns, "db-max-open-conns", cfg.DBMaxOpenConns, "Maximal number of open database connections, 0 is unlimited")
+	fs.IntVar(&cfg.DBMaxIdleConns, "db-max-idle-conns", cfg.DBMaxIdleConns, "Maximal number of idle database connections")
+	fs.DurationVar(&cfg.DBConnMaxLifetime, "db-conn-max-lifetime", cfg.DBConnMaxLifetime, "Maximal lifetime of database connection, 0 is unlimited")
+	fs.DurationVar(&cfg.DBConnMaxIdleTime, "db-conn-max-idle-time", cfg.DBConnMaxIdleTime, "Maximal idle time of database connection, 0 is unlimited")
+	fs.DurationVar(&cfg.DBConnectTimeout, "db-connect-timeout", cfg.DBConnectTimeout, "How long to retry unavailable database at startup, 0 tries once")
+	fs.Var(newListValue(&cfg.DBReplicas), "db-replicas", "Comma separated DSNs of read replicas serving redirects and listings")
+	fs.DurationVar(&cfg.DBReplicaInterval, "db-replica-interval", cfg.DBReplicaInterval, "Period of read replica health checks")
+	fs.IntVar(&cfg.CacheSize, "cache-size", cfg.CacheSize, "Number of links cached in memory for redirects, 0 disables cache")
+	fs.DurationVar(&cfg.CacheTTL, "cache-ttl", cfg.CacheTTL, "Lifetime of cached link")
+	fs.DurationVar(&cfg.CacheNegativeTTL, "cache-negative-ttl", cfg.CacheNegativeTTL, "Lifetime of cached unknown short url, 0 disables negative caching")
+	fs.BoolVar(&cfg.EnableHTTPS, "s", cfg.EnableHTTPS, "Enable HTTPS server mode")
+	fs.StringVar(&cfg.Config, "c", cfg.Config, "Config file (.json, .yaml, .yml or .toml)")
+	fs.StringVar(&cfg.Config, "config", cfg.Config, "Config file (.json, .yaml, .yml or .toml)")
+	fs.StringVar(&cfg.TrustedSubnet, "t", cfg.TrustedSubnet, "Comma separated trusted service subnets (IPv4 or IPv6 CIDR)")
+	fs.StringVar(&cfg.AdminToken, "admin-token", cfg.AdminToken, "Bearer token of admin API, empty disables it")
+	fs.StringVar(&cfg.AuditLogFile, "audit-log", cfg.AuditLogFile, "File admin actions are appended to as JSON lines")
+	fs.Var(newListValue(&cfg.TrustedProxies), "trusted-proxies", "Comma separated reverse proxy addresses or CIDRs whose forwarding headers are trusted")
+	fs.StringVar(&cfg.ForwardedHeader, "forwarded-header", cfg.ForwardedHeader, "The only forwarding header set by trusted proxies: Forwarded, X-Forwarded-For or X-Real-IP")
+	fs.StringVar(&cfg.OTLPEndpoint, "otlp-endpoint", cfg.OTLPEndpoint, "OTLP gRPC collector address for traces (empty disables tracing)")
+	fs.BoolVar(&cfg.OTLPInsecure, "otlp-insecure", cfg.OTLPInsecure, "Use plaintext connection to OTLP collector")
+	fs.StringVar(&cfg.TLSCertFile, "tls-cert", cfg.TLSCertFile, "TLS certificate file, reloaded when changed")
+	fs.StringVar(&cfg.TLSKeyFile, "tls-key", cfg.TLSKeyFile, "TLS private key file, reloaded when changed")
+	fs.BoolVar(&cfg.TLSSelfSigned, "tls-self-signed", cfg.TLSSelfSigned, "Generate and save self-signed certificate when certificate files are missing")
+	fs.StringVar(&cfg.TLSMinVersion, "tls-min-version", cfg.TLSMinVersion, "Minimal TLS version (1.0, 1.1, 1.2, 1.3)")
+	fs.Var(newListValue(&cfg.TLSCipherSuites), "tls-ciphers", "Comma separated TLS 1.0-1.2 cipher suites, empty uses Go defaults")
+	fs.StringVar(&cfg.TLSClientCAFile, "tls-client-ca", cfg.TLSClientCAFile, "CA bundle to verify client certificates (enables mTLS)")
+	fs.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "Log level (debug, info, warn, error)")
+	fs.DurationVar(&cfg.ReloadInterval, "reload-interval", cfg.ReloadInterval, "How often config file is checked for changes, 0 disables watching")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "How long to drain requests on shutdown")
+	fs.DurationVar(&cfg.FlushTimeout, "flush-timeout", cfg.FlushTimeout, "How long to process pending deletions on shutdown after requests are drained")
+	fs.Var(newListValue(&cfg.AllowedSchemes), "allowed-schemes", "Comma separated url schemes accepted for shortening")
+	fs.IntVar(&cfg.MaxURLLength, "max-url-length", cfg.MaxURLLength, "Maximal length of url accepted for shortening")
+	fs.StringVar(&cfg.BlocklistFile, "blocklist", cfg.BlocklistFile, "File with blocked destination domains, one per line")
+	fs.StringVar(&cfg.AllowlistFile, "allowlist", cfg.AllowlistFile, "File with the only allowed destination domains, one per line")
+	fs.StringVar(&cfg.ReputationFile, "reputation-file", cfg.ReputationFile, "File with known malicious urls, one per line")
+	fs.BoolVar(&cfg.BlockPrivateIPs, "block-private-ips", cfg.BlockPrivateIPs, "Reject destinations pointing to loopback and private networks")
+	fs.Float64Var(&cfg.RateLimit, "rate-limit", cfg.RateLimit, "Requests per second allowed to each user with valid token cookie or else to each IP, 0 disables limiting")
+	fs.IntVar(&cfg.RateLimitBurst, "rate-limit-burst", cfg.RateLimitBurst, "Requests allowed at once before rate limit applies")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", cfg.PrintConfig, "Print effective configuration with secrets redacted and exit")
+}
+
+// Load gets config from command line arguments, environment and config file
+func Load() (*Config, error) {
+	return Parse(os.Args[1:], os.LookupEnv)
+}
+
+// Parse builds configuration from args and environment lookup function.
+// Config file path is taken from -c flag or CONFIG variable.
+func Parse(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	// flags are parsed first to learn config path, but applied last
+	fs := flag.NewFlagSet("shortener", flag.ContinueOnError)
+	bindFlags(fs, Default())
+	if err := fs.Parse(args); err != nil {
+		return nil, err
 	}
-	if envStorageType := os.Getenv("STORAGE_TYPE"); envStorageType != "" {
-		cfg.StorageType = envStorageType
+	setFlags := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = f.Value.String()
+	})
+
+	cfg := Default()
+	path := ""
+	if v, ok := lookupEnv("CONFIG"); ok {
+		path = v
+	}
+	if v, ok := setFlags["c"]; ok {
+		path = v
+	}
+	if v, ok := setFlags["config"]; ok {
+		path = v
+	}
+	if path != "" {
+		if err := LoadFile(path, cfg); err != nil {
+			return nil, err
+		}
 	}
 
-	if envFileStoragePath := os.Getenv("FILE_STORAGE_PATH"); envFileStoragePath != "" {
-		cfg.FileStoragePath = envFileStoragePath
+	if err := applyEnv(cfg, lookupEnv); err != nil {
+		return nil, err
 	}
 
-	if envDatabaseDSN := os.Getenv("DATABASE_DSN"); envDatabaseDSN != "" {
-		cfg.DatabaseDSN = envDatabaseDSN
+	final := flag.NewFlagSet("shortener", flag.ContinueOnError)
+	bindFlags(final, cfg)
+	for name, value := range setFlags {
+		if err := final.Set(name, value); err != nil {
+			return nil, err
+		}
 	}
+	cfg.Config = path
 
-	if cfg.DatabaseDSN != "" {
-		cfg.StorageType = "db"
+	if cfg.StorageType == "" {
+		cfg.StorageType = "file"
+		if cfg.DatabaseDSN != "" {
+			cfg.StorageType = "db"
+		}
 	}
 
-	// Read and parse JSON file if flag -c with value exists
-	jsonFileData, err := os.ReadFile(cfg.Config)
-	if err != nil {
-		return cfg
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
+	return cfg, nil
+}
+
+// ValidationError aggregates all problems found in configuration
+type ValidationError struct {
+	Problems []string
+}
 
-	var jsonCfg Config
-	if err = json.Unmarshal(jsonFileData, &jsonCfg); err != nil {
-		return cfg
+// Error returns all problems one per line
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks configuration and returns *ValidationError listing every problem
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
 	}
 
-	if cfg.ServerAddr == "" {
-		cfg.ServerAddr = jsonCfg.ServerAddr
+	if _, _, err := net.SplitHostPort(c.ServerAddr); err != nil {
+		add("server_address %q: %v", c.ServerAddr, err)
+	}
+	if _, _, err := net.SplitHostPort(c.GRPCAddr); err != nil {
+		add("grpc_address %q: %v", c.GRPCAddr, err)
+	}
+	if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("base_url %q: must be absolute http(s) URL", c.BaseURL)
+	} else if _, err = domains.New(c.BaseURL, c.Domains, c.DefaultDomain); err != nil {
+		add("domains: %v", err)
+	}
+	switch c.StorageType {
+	case "memory":
+	case "file":
+		if c.FileStoragePath == "" {
+			add("file_storage_path: required for file storage")
+		}
+	case "bolt":
+		if c.BoltPath == "" {
+			add("bolt_path: required for bolt storage")
+		}
+	case "sqlite":
+		if c.SQLitePath == "" {
+			add("sqlite_path: required for sqlite storage")
+		}
+	case "db":
+		if c.DatabaseDSN == "" {
+			add("database_dsn: required for db storage")
+		}
+	default:
+		add("storage_type %q: must be one of memory, file, bolt, sqlite, db", c.StorageType)
+	}
+	for name, v := range map[string]int{"db_max_open_conns": c.DBMaxOpenConns, "db_max_idle_conns": c.DBMaxIdleConns} {
+		if v < 0 {
+			add("%s %d: must not be negative", name, v)
+		}
+	}
+	for name, v := range map[string]time.Duration{
+		"db_conn_max_lifetime":  c.DBConnMaxLifetime,
+		"db_conn_max_idle_time": c.DBConnMaxIdleTime,
+		"db_connect_timeout":    c.DBConnectTimeout,
+	} {
+		if v < 0 {
+			add("%s %s: must not be negative", name, v)
+		}
+	}
+	if len(c.DBReplicas) > 0 {
+		if c.StorageType != "db" {
+			add("db_replicas: supported by db storage only")
+		}
+		if c.DBReplicaInterval <= 0 {
+			add("db_replica_interval %s: must be positive when replicas are set", c.DBReplicaInterval)
+		}
+	}
+	if c.CacheSize < 0 {
+		add("cache_size %d: must not be negative", c.CacheSize)
 	}
-	if cfg.BaseURL == "" {
-		cfg.BaseURL = jsonCfg.BaseURL
+	if c.CacheSize > 0 && c.CacheTTL <= 0 {
+		add("cache_ttl %s: must be positive when cache is enabled", c.CacheTTL)
 	}
-	if cfg.FileStoragePath == "" {
-		cfg.FileStoragePath = jsonCfg.FileStoragePath
+	if c.CacheNegativeTTL < 0 {
+		add("cache_negative_ttl %s: must not be negative", c.CacheNegativeTTL)
 	}
-	if cfg.DatabaseDSN == "" {
-		cfg.DatabaseDSN = jsonCfg.DatabaseDSN
+	for _, subnet := range strings.Split(c.TrustedSubnet, ",") {
+		if subnet = strings.TrimSpace(subnet); subnet == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(subnet); err != nil {
+			add("trusted_subnet %q: %v", subnet, err)
+		}
 	}
-	if !cfg.EnableHTTPS || jsonCfg.EnableHTTPS {
-		cfg.EnableHTTPS = jsonCfg.EnableHTTPS
+	if c.AdminToken != "" {
+		if len(c.AdminToken) < 16 {
+			add("admin_token: must be at least 16 characters")
+		}
+		if strings.TrimSpace(c.TrustedSubnet) == "" {
+			add("admin_token: trusted_subnet is required to enable admin API")
+		}
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			add("trusted_proxies %q: must be address or CIDR", proxy)
+		}
+	}
+	if !knownForwardedHeader(c.ForwardedHeader) {
+		add("forwarded_header %q: must be Forwarded, X-Forwarded-For or X-Real-IP", c.ForwardedHeader)
+	}
+	if c.OTLPEndpoint != "" {
+		if _, _, err := net.SplitHostPort(c.OTLPEndpoint); err != nil {
+			add("otlp_endpoint %q: %v", c.OTLPEndpoint, err)
+		}
 	}
 
-	if cfg.TrustedSubnet == "" {
-		cfg.TrustedSubnet = jsonCfg.TrustedSubnet
+	if c.EnableHTTPS {
+		if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+			add("tls_cert_file and tls_key_file must be set together")
+		}
+	}
+	if _, ok := TLSVersions[c.TLSMinVersion]; !ok {
+		add("tls_min_version %q: must be one of 1.0, 1.1, 1.2, 1.3", c.TLSMinVersion)
+	}
+	for _, name := range c.TLSCipherSuites {
+		if _, ok := cipherSuiteIDs()[name]; !ok {
+			add("tls_cipher_suites: unknown or insecure cipher suite %q", name)
+		}
 	}
 
-	return cfg
+	if _, err := zapcore.ParseLevel(c.LogLevel); err != nil {
+		add("log_level %q: %v", c.LogLevel, err)
+	}
+	if c.ReloadInterval < 0 {
+		add("reload_interval: must not be negative")
+	}
+	if c.ShutdownTimeout <= 0 {
+		add("shutdown_timeout: must be positive")
+	}
+	if c.FlushTimeout <= 0 {
+		add("flush_timeout: must be positive")
+	}
+
+	if len(c.AllowedSchemes) == 0 {
+		add("allowed_schemes: at least one scheme required")
+	}
+	for _, s := range c.AllowedSchemes {
+		if s == "" || strings.Trim(strings.ToLower(s), "abcdefghijklmnopqrstuvwxyz0123456789+-.") != "" {
+			add("allowed_schemes: invalid scheme %q", s)
+		}
+	}
+	if c.MaxURLLength < 1 {
+		add("max_url_length: must be positive")
+	}
+	if c.RateLimit < 0 {
+		add("rate_limit: must not be negative")
+	}
+	if c.RateLimit > 0 && c.RateLimitBurst < 1 {
+		add("rate_limit_burst: must be at least 1")
+	}
+	routes := make([]string, 0, len(c.RateLimitRoutes))
+	for route := range c.RateLimitRoutes {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		if l := c.RateLimitRoutes[route]; l.Rate < 0 || (l.Rate > 0 && l.Burst < 1) {
+			add("rate_limit_routes %q: rate must not be negative and burst must be at least 1", route)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Redacted returns copy of configuration with secrets masked
+func (c *Config) Redacted() *Config {
+	r := *c
+	redact(&r)
+	return &r
+}
+
+// Print writes effective configuration as JSON with secrets masked
+func (c *Config) Print(w io.Writer) error {
+	out, err := json.MarshalIndent(c.Redacted(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
+
+// Diff lists fields whose values differ between c and other as "name: old -> new", secrets masked.
+// Fields marked as reloadable are listed in reloadable, the rest in restart.
+func (c *Config) Diff(other *Config) (reloadable []string, restart []string) {
+	oldValues := fieldValues(c.Redacted())
+	newValues := fieldValues(other.Redacted())
+	for i, f := range oldValues {
+		n := newValues[i]
+		if reflect.DeepEqual(f.value, n.value) {
+			continue
+		}
+		line := fmt.Sprintf("%s: %v -> %v", f.name, f.value, n.value)
+		if f.reload {
+			reloadable = append(reloadable, line)
+		} else {
+			restart = append(restart, line)
+		}
+	}
+	return reloadable, restart
+}
+
+// WithReloadable returns copy of c with fields marked as reloadable taken from next
+func (c *Config) WithReloadable(next *Config) *Config {
+	merged := *c
+	copyReloadable(reflect.ValueOf(&merged).Elem(), reflect.ValueOf(next).Elem())
+	return &merged
+}
+
+// TLSVersions maps configured version names to crypto/tls constants
+var TLSVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// CipherSuites returns IDs of configured cipher suites, unknown names are skipped
+func (c *Config) CipherSuites() []uint16 {
+	ids := cipherSuiteIDs()
+	var result []uint16
+	for _, name := range c.TLSCipherSuites {
+		if id, ok := ids[name]; ok {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func cipherSuiteIDs() map[string]uint16 {
+	ids := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		ids[s.Name] = s.ID
+	}
+	return ids
+}
+
+// knownForwardedHeader reports whether name is forwarding header client address is read from
+func knownForwardedHeader(name string) bool {
+	for _, known := range []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"} {
+		if strings.EqualFold(name, known) {
+			return true
+		}
+	}
+	return false
 }
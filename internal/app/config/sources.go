@@ -0,0 +1,359 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const redactedValue = "******"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// LoadFile reads JSON, YAML or TOML file chosen by extension and applies present keys to cfg.
+// Keys are the json names of Config fields; unknown keys are reported as errors.
+func LoadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	raw := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .json, .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	if err = setStruct(reflect.ValueOf(cfg).Elem(), raw, ""); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv sets every field having env tag from variables found by lookupEnv
+func applyEnv(cfg *Config, lookupEnv func(string) (string, bool)) error {
+	var problems []string
+	walkFields(reflect.ValueOf(cfg).Elem(), func(f reflect.StructField, v reflect.Value) {
+		name := f.Tag.Get("env")
+		if name == "" {
+			return
+		}
+		value, ok := lookupEnv(name)
+		if !ok {
+			return
+		}
+		if err := setString(v, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	})
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// walkFields calls fn for every leaf field, descending into nested structs
+func walkFields(v reflect.Value, fn func(reflect.StructField, reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			walkFields(fv, fn)
+			continue
+		}
+		fn(f, fv)
+	}
+}
+
+func jsonName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+func setStruct(v reflect.Value, raw map[string]interface{}, prefix string) error {
+	fields := map[string]int{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if name := jsonName(t.Field(i)); name != "-" && t.Field(i).IsExported() {
+			fields[name] = i
+		}
+	}
+
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		i, ok := fields[k]
+		if !ok {
+			return fmt.Errorf("unknown key %q", prefix+k)
+		}
+		if err := setAny(v.Field(i), raw[k], prefix+k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setAny assigns decoded file value to field converting between compatible kinds
+func setAny(v reflect.Value, raw interface{}, path string) error {
+	switch value := raw.(type) {
+	case nil:
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	case string:
+		if err := setString(v, value); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	case map[string]interface{}:
+		switch v.Kind() {
+		case reflect.Struct:
+			return setStruct(v, value, path+".")
+		case reflect.Map:
+			m := reflect.MakeMapWithSize(v.Type(), len(value))
+			for k, item := range value {
+				elem := reflect.New(v.Type().Elem()).Elem()
+				if err := setAny(elem, item, path+"."+k); err != nil {
+					return err
+				}
+				m.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), elem)
+			}
+			v.Set(m)
+			return nil
+		}
+	case []interface{}:
+		if v.Kind() == reflect.Slice {
+			s := reflect.MakeSlice(v.Type(), len(value), len(value))
+			for i, item := range value {
+				if err := setAny(s.Index(i), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			v.Set(s)
+			return nil
+		}
+	case bool:
+		if v.Kind() == reflect.Bool {
+			v.SetBool(value)
+			return nil
+		}
+	case float64:
+		return setNumber(v, value, path)
+	case int64:
+		return setNumber(v, float64(value), path)
+	case int:
+		return setNumber(v, float64(value), path)
+	}
+	return fmt.Errorf("%s: cannot use %T as %s", path, raw, v.Type())
+}
+
+func setNumber(v reflect.Value, n float64, path string) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == durationType {
+			// plain numbers in files mean seconds
+			v.SetInt(int64(n * float64(time.Second)))
+			return nil
+		}
+		v.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n < 0 {
+			return fmt.Errorf("%s: must not be negative", path)
+		}
+		v.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("%s: cannot use number as %s", path, v.Type())
+	}
+	return nil
+}
+
+// setString parses s according to field kind, slices are comma separated
+func setString(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		var parts []string
+		for _, p := range strings.Split(s, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				parts = append(parts, p)
+			}
+		}
+		sl := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, p := range parts {
+			if err := setString(sl.Index(i), p); err != nil {
+				return err
+			}
+		}
+		v.Set(sl)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// redact masks every non-empty field tagged with secret
+func redact(cfg *Config) {
+	walkFields(reflect.ValueOf(cfg).Elem(), func(f reflect.StructField, v reflect.Value) {
+		kind := f.Tag.Get("secret")
+		if kind == "dsn" && v.Kind() == reflect.Slice {
+			// copy, redacted config shares backing array with original
+			dsns := make([]string, v.Len())
+			for i := range dsns {
+				dsns[i] = redactDSN(v.Index(i).String())
+			}
+			v.Set(reflect.ValueOf(dsns))
+			return
+		}
+		if kind == "" || v.Kind() != reflect.String || v.String() == "" {
+			return
+		}
+		if kind == "dsn" {
+			v.SetString(redactDSN(v.String()))
+			return
+		}
+		v.SetString(redactedValue)
+	})
+}
+
+// redactDSN keeps host and database of URL style DSN and hides the password
+func redactDSN(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" {
+		return redactedValue
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redactedValue)
+	}
+	q := u.Query()
+	if q.Has("password") {
+		q.Set("password", redactedValue)
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+type fieldValue struct {
+	value  interface{}
+	name   string
+	reload bool
+}
+
+// fieldValues returns every leaf field stored in files in declaration order
+func fieldValues(cfg *Config) []fieldValue {
+	var values []fieldValue
+	walkFields(reflect.ValueOf(cfg).Elem(), func(f reflect.StructField, v reflect.Value) {
+		if jsonName(f) == "-" {
+			return
+		}
+		values = append(values, fieldValue{
+			value:  v.Interface(),
+			name:   jsonName(f),
+			reload: f.Tag.Get("reload") == "true",
+		})
+	})
+	return values
+}
+
+func copyReloadable(dst, src reflect.Value) {
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if f.Tag.Get("reload") == "true" {
+			dst.Field(i).Set(src.Field(i))
+			continue
+		}
+		if f.Type.Kind() == reflect.Struct && f.Type != durationType {
+			copyReloadable(dst.Field(i), src.Field(i))
+		}
+	}
+}
+
+// listValue is flag.Value for comma separated string lists
+type listValue struct {
+	list *[]string
+}
+
+func newListValue(list *[]string) *listValue {
+	return &listValue{list: list}
+}
+
+// String returns list joined with commas
+func (l *listValue) String() string {
+	if l.list == nil {
+		return ""
+	}
+	return strings.Join(*l.list, ",")
+}
+
+// Set replaces list with comma separated values
+func (l *listValue) Set(s string) error {
+	return setString(reflect.ValueOf(l.list).Elem(), s)
+}
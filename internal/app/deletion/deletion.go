@@ -0,0 +1,110 @@
+// Package deletion contains background queue marking user urls as deleted
+package deletion
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/gsk148/urlShorteningService/internal/app/logger"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+)
+
+// DefaultQueueSize is number of short urls waiting for deletion before Enqueue blocks
+const DefaultQueueSize = 1024
+
+// ErrClosed returned by Enqueue after Close was called
+var ErrClosed = errors.New("deletion queue is closed")
+
+type task struct {
+	ctx      context.Context
+	userID   string
+	shortURL string
+}
+
+// Queue deletes short urls in background one by one
+type Queue struct {
+	store storage.Storage
+	log   zap.SugaredLogger
+	tasks chan task
+	done  chan struct{}
+
+	pending atomic.Int64
+	mu      sync.RWMutex
+	closed  bool
+}
+
+// NewQueue return Queue object with started worker
+func NewQueue(store storage.Storage, log zap.SugaredLogger, size int) *Queue {
+	q := &Queue{
+		store: store,
+		log:   log,
+		tasks: make(chan task, size),
+		done:  make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Enqueue schedules deletion of shortURLs owned by userID.
+// Deletion outlives the request, so only trace of ctx is kept.
+func (q *Queue) Enqueue(ctx context.Context, userID string, shortURLs []string) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrClosed
+	}
+
+	taskCtx := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+	for _, v := range shortURLs {
+		q.pending.Add(1)
+		select {
+		case q.tasks <- task{ctx: taskCtx, userID: userID, shortURL: v}:
+		case <-ctx.Done():
+			q.pending.Add(-1)
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Len returns number of short urls waiting for deletion
+func (q *Queue) Len() int {
+	return int(q.pending.Load())
+}
+
+// Cap returns number of short urls queue holds before Enqueue blocks
+func (q *Queue) Cap() int {
+	return cap(q.tasks)
+}
+
+// Close stops accepting new deletions and waits until queued ones are processed or ctx is done
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.tasks)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+	for t := range q.tasks {
+		if err := q.store.DeleteByUserIDAndShort(t.ctx, t.userID, t.shortURL); err != nil {
+			logger.WithTrace(t.ctx, &q.log).Warnf("Failed to mark deleted by short %s", t.shortURL)
+		}
+		q.pending.Add(-1)
+	}
+}
@@ -0,0 +1,29 @@
+package deletion
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gsk148/urlShorteningService/internal/app/logger"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+)
+
+func TestCloseFlushesPending(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := storage.NewMockStorage(ctrl)
+	store.EXPECT().DeleteByUserIDAndShort(gomock.Any(), "user", "a").Return(nil)
+	store.EXPECT().DeleteByUserIDAndShort(gomock.Any(), "user", "b").Return(nil)
+
+	q := NewQueue(store, *logger.NewLogger(), 1)
+	require.NoError(t, q.Enqueue(context.Background(), "user", []string{"a", "b"}))
+	require.NoError(t, q.Close(context.Background()))
+	assert.Equal(t, 0, q.Len())
+
+	assert.ErrorIs(t, q.Enqueue(context.Background(), "user", []string{"c"}), ErrClosed)
+}
@@ -0,0 +1,143 @@
+// Package domains maps short domains served by the service to base urls of their links.
+// Primary domain of base_url is stored as empty domain, so links created before
+// other domains were configured keep working
+package domains
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+)
+
+// ErrUnknown returned when user chooses domain which is not configured
+var ErrUnknown = errors.New("unknown domain")
+
+// Registry of configured short domains, domain name is lower case host without port
+type Registry struct {
+	primary  string
+	baseURLs map[string]string
+	def      string
+}
+
+// Single returns registry serving only primary domain of baseURL
+func Single(baseURL string) *Registry {
+	return &Registry{
+		primary:  host(baseURL),
+		baseURLs: map[string]string{"": strings.TrimSuffix(baseURL, "/")},
+	}
+}
+
+// New returns registry of primary domain of baseURL and other domains given by their
+// base urls. defaultDomain is name of domain used when user does not choose one,
+// empty means primary domain
+func New(baseURL string, others []string, defaultDomain string) (*Registry, error) {
+	r := Single(baseURL)
+	if r.primary == "" {
+		return nil, fmt.Errorf("base url %q: host is required", baseURL)
+	}
+	for _, other := range others {
+		u, err := url.Parse(other)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("domain %q: must be absolute http(s) URL", other)
+		}
+		name := host(other)
+		if _, ok := r.baseURLs[name]; ok || name == r.primary {
+			return nil, fmt.Errorf("domain %q: duplicate host %s", other, name)
+		}
+		r.baseURLs[name] = strings.TrimSuffix(other, "/")
+	}
+	def, err := r.Choose(defaultDomain)
+	if err != nil {
+		return nil, fmt.Errorf("default domain: %w", err)
+	}
+	r.def = def
+	return r, nil
+}
+
+// host returns lower case host of url without port
+func host(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// normalize returns lower case host of Host header or domain name without port
+func normalize(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if h, _, err := net.SplitHostPort(name); err == nil {
+		return h
+	}
+	return name
+}
+
+// Resolve returns domain of request Host header, primary and unknown hosts
+// resolve to primary domain
+func (r *Registry) Resolve(hostHeader string) string {
+	name := normalize(hostHeader)
+	if _, ok := r.baseURLs[name]; ok {
+		return name
+	}
+	return ""
+}
+
+// Choose returns domain of new link by name given by user, empty name is default domain
+func (r *Registry) Choose(name string) (string, error) {
+	if strings.TrimSpace(name) == "" {
+		return r.def, nil
+	}
+	name = normalize(name)
+	if name == r.primary {
+		return "", nil
+	}
+	if _, ok := r.baseURLs[name]; !ok || name == "" {
+		return "", fmt.Errorf("%w %q", ErrUnknown, name)
+	}
+	return name, nil
+}
+
+// LinkKey returns storage key of short url on domain named by user, empty name
+// and primary host refer to primary domain
+func (r *Registry) LinkKey(name string, shortURL string) string {
+	name = normalize(name)
+	if name == r.primary {
+		name = ""
+	}
+	return api.LinkKey(name, shortURL)
+}
+
+// BaseURL returns url short codes of domain are appended to. Domain removed from
+// configuration keeps scheme of primary domain
+func (r *Registry) BaseURL(domain string) string {
+	if base, ok := r.baseURLs[domain]; ok {
+		return base
+	}
+	scheme := "http"
+	if u, err := url.Parse(r.baseURLs[""]); err == nil && u.Scheme != "" {
+		scheme = u.Scheme
+	}
+	return scheme + "://" + domain
+}
+
+// ShortURL returns full short url of link
+func (r *Registry) ShortURL(data api.ShortenedData) string {
+	return r.BaseURL(data.Domain) + "/" + data.ShortURL
+}
+
+// Names returns names of configured domains, primary one first
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.baseURLs))
+	for name := range r.baseURLs {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{r.primary}, names...)
+}
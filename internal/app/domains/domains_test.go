@@ -0,0 +1,60 @@
+package domains
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+)
+
+func TestRegistry(t *testing.T) {
+	r, err := New("http://localhost:8080", []string{"https://go.example/", "http://short.example:8000"}, "go.example")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"localhost", "go.example", "short.example"}, r.Names())
+
+	for host, want := range map[string]string{
+		"localhost:8080":     "",
+		"unknown.example":    "",
+		"GO.example":         "go.example",
+		"short.example:8000": "short.example",
+	} {
+		assert.Equal(t, want, r.Resolve(host), host)
+	}
+
+	for name, want := range map[string]string{
+		"":              "go.example",
+		"localhost":     "",
+		"Short.Example": "short.example",
+	} {
+		got, err := r.Choose(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, got, name)
+	}
+	_, err = r.Choose("unknown.example")
+	assert.True(t, errors.Is(err, ErrUnknown))
+
+	assert.Equal(t, "a", r.LinkKey("localhost", "a"))
+	assert.Equal(t, "go.example/a", r.LinkKey("GO.example", "a"))
+	assert.Equal(t, "https://go.example/a", r.ShortURL(api.ShortenedData{Domain: "go.example", ShortURL: "a"}))
+	assert.Equal(t, "http://localhost:8080/a", r.ShortURL(api.ShortenedData{ShortURL: "a"}))
+	assert.Equal(t, "http://removed.example", r.BaseURL("removed.example"))
+}
+
+func TestNewErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		others []string
+		def    string
+	}{
+		"relative":         {others: []string{"go.example"}},
+		"primary host":     {others: []string{"https://localhost"}},
+		"duplicate host":   {others: []string{"https://go.example", "http://go.example:81"}},
+		"unknown default":  {def: "go.example"},
+		"default with url": {others: []string{"https://go.example"}, def: "https://go.example"},
+	} {
+		_, err := New("http://localhost:8080", tc.others, tc.def)
+		assert.Error(t, err, name)
+	}
+}
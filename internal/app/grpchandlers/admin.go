@@ -0,0 +1,145 @@
+package grpchandlers
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/gsk148/urlShorteningService/internal/app/admin"
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+	"github.com/gsk148/urlShorteningService/internal/app/clientip"
+	"github.com/gsk148/urlShorteningService/internal/app/domains"
+	pb "github.com/gsk148/urlShorteningService/internal/app/proto"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+)
+
+// HeaderAuthorization carries "Bearer <admin token>" of AdminService calls
+const HeaderAuthorization = "authorization"
+
+// AdminService exposes operator actions over gRPC
+type AdminService struct {
+	pb.UnimplementedAdminServiceServer
+	admin   *admin.Service
+	domains *domains.Registry
+}
+
+// NewAdminService return AdminService object
+func NewAdminService(svc *admin.Service, reg *domains.Registry) *AdminService {
+	return &AdminService{admin: svc, domains: reg}
+}
+
+// authorize checks caller and returns its name for audit trail
+func (s *AdminService) authorize(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	token, _ := GetMetadataValue(md, HeaderAuthorization)
+	ip := clientip.FromCall(ctx)
+	err := s.admin.Authorize(ip, admin.BearerToken(token))
+	switch {
+	case errors.Is(err, admin.ErrDisabled):
+		return "", status.Error(codes.Unimplemented, err.Error())
+	case errors.Is(err, admin.ErrUntrusted):
+		return "", status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		return "", status.Error(codes.Unauthenticated, err.Error())
+	}
+	if ip == nil {
+		return "unknown", nil
+	}
+	return ip.String(), nil
+}
+
+func adminError(err error) error {
+	switch {
+	case errors.Is(err, admin.ErrInvalidArgument), errors.Is(err, storage.ErrInvalidQuery):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, storage.ErrNotFound):
+		return status.Error(codes.NotFound, "link not found")
+	default:
+		return status.Error(codes.Internal, "admin action failed")
+	}
+}
+
+func adminLinkToProto(data api.ShortenedData) *pb.URLInfo {
+	info := modelUserURLToProto(api.NewUserURLV1(data, ""))
+	info.UserID = data.UserID
+	return info
+}
+
+// GetLink returns any link with its owner
+func (s *AdminService) GetLink(ctx context.Context, in *pb.AdminGetLinkRequest) (*pb.URLInfo, error) {
+	actor, err := s.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.admin.GetLink(ctx, actor, s.domains.LinkKey(in.GetDomain(), in.GetShortUrl()))
+	if err != nil {
+		return nil, adminError(err)
+	}
+	return adminLinkToProto(data), nil
+}
+
+// UpdateLink disables, enables or reassigns any link
+func (s *AdminService) UpdateLink(ctx context.Context, in *pb.AdminUpdateLinkRequest) (*pb.URLInfo, error) {
+	actor, err := s.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.admin.UpdateLink(ctx, actor, s.domains.LinkKey(in.GetDomain(), in.GetShortUrl()), api.AdminUpdate{Active: in.Active, UserID: in.UserId})
+	if err != nil {
+		return nil, adminError(err)
+	}
+	return adminLinkToProto(data), nil
+}
+
+// DeleteByDomain marks deleted every link to domain or its subdomains
+func (s *AdminService) DeleteByDomain(ctx context.Context, in *pb.AdminDeleteByDomainRequest) (*pb.AdminDeleteByDomainResponse, error) {
+	actor, err := s.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := s.admin.DeleteByDomain(ctx, actor, in.GetDomain())
+	if err != nil {
+		return nil, adminError(err)
+	}
+	return &pb.AdminDeleteByDomainResponse{Deleted: int32(deleted)}, nil
+}
+
+// ListUserLinks returns page of links owned by user
+func (s *AdminService) ListUserLinks(ctx context.Context, in *pb.AdminListUserLinksRequest) (*pb.FindUserURLSResponse, error) {
+	actor, err := s.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := in.GetQuery()
+	if query == nil {
+		query = &pb.FindUserURLSRequest{}
+	}
+	page, err := s.admin.ListUserLinks(ctx, actor, in.GetUserId(), protoListQueryToModel(query))
+	if err != nil {
+		return nil, adminError(err)
+	}
+	return modelListPageToProto(page), nil
+}
+
+// ListAudit returns latest operator actions, newest first
+func (s *AdminService) ListAudit(ctx context.Context, in *pb.AdminListAuditRequest) (*pb.AdminListAuditResponse, error) {
+	if _, err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	var resp pb.AdminListAuditResponse
+	for _, e := range s.admin.Trail().Recent(int(in.GetLimit())) {
+		resp.Entries = append(resp.Entries, &pb.AuditEntry{
+			Time:   timestamppb.New(e.Time),
+			Actor:  e.Actor,
+			Action: e.Action,
+			Target: e.Target,
+			Params: e.Params,
+			Error:  e.Error,
+		})
+	}
+	return &resp, nil
+}
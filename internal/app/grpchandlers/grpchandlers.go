@@ -4,23 +4,56 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/gsk148/urlShorteningService/internal/app/api"
-	"github.com/gsk148/urlShorteningService/internal/app/hashutil"
+	"github.com/gsk148/urlShorteningService/internal/app/clientip"
+	"github.com/gsk148/urlShorteningService/internal/app/config"
+	"github.com/gsk148/urlShorteningService/internal/app/domains"
+	"github.com/gsk148/urlShorteningService/internal/app/policy"
 	pb "github.com/gsk148/urlShorteningService/internal/app/proto"
 	"github.com/gsk148/urlShorteningService/internal/app/storage"
+	"github.com/gsk148/urlShorteningService/internal/app/urlnorm"
 )
 
 type ShortenerService struct {
 	pb.UnimplementedShortenerServiceServer
-	strg storage.Storage
-	log  zap.SugaredLogger
+	strg    storage.Storage
+	log     zap.SugaredLogger
+	urlOpts urlnorm.Options
+	policy  *policy.Policy
+	domains *domains.Registry
+
+	mu            sync.RWMutex
+	trustedSubnet string
+}
+
+// NewShortenerService return ShortenerService object
+func NewShortenerService(strg storage.Storage, log zap.SugaredLogger, trustedSubnet string, urlOpts urlnorm.Options, pol *policy.Policy, reg *domains.Registry) *ShortenerService {
+	return &ShortenerService{
+		strg:          strg,
+		log:           log,
+		urlOpts:       urlOpts,
+		policy:        pol,
+		domains:       reg,
+		trustedSubnet: trustedSubnet,
+	}
+}
+
+// ApplyConfig swaps reloadable settings of running service
+func (s *ShortenerService) ApplyConfig(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trustedSubnet = cfg.TrustedSubnet
 }
 
 const (
@@ -33,15 +66,40 @@ var (
 
 func (s *ShortenerService) BatchShortenAPI(ctx context.Context, in *pb.BatchShortenAPIRequest) (*pb.BatchShortenAPIResponse, error) {
 	var resp pb.BatchShortenAPIResponse
-	urls := in.GetEntities()
-	shortenerData := protoURLInfoToModel(urls)
-	_, err := getUserIDFromMD(ctx)
+	urls := protoURLInfoToModel(in.GetEntities())
+	userID, err := getUserIDFromMD(ctx)
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, "no userID in metadata")
 	}
 
-	result := shortenerData
-	resp.Entities = modelURLInfoToProto(result)
+	links := make([]api.ShortenedData, len(urls))
+	for i, entity := range in.GetEntities() {
+		links[i] = api.ShortenedData{UserID: userID, UUID: urls[i].UUID}
+		links[i].OriginalURL, err = s.urlOpts.Normalize(urls[i].OriginalURL)
+		if err == nil {
+			links[i].Domain, err = s.domains.Choose(urls[i].Domain)
+		}
+		if err == nil {
+			err = links[i].SetRedirect(entity.GetRedirectType(), entity.GetPassQuery(), utmFromProto(entity.GetUtm()))
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "correlation_id %s: %v", urls[i].CorrelationID, err)
+		}
+		if err = s.checkDestination(ctx, links[i].OriginalURL); err != nil {
+			return nil, err
+		}
+		urls[i].OriginalURL, urls[i].Domain = links[i].OriginalURL, links[i].Domain
+	}
+
+	for i := range urls {
+		urls[i].UserID = userID
+		stored, err := storage.Shorten(ctx, s.strg, links[i])
+		if err != nil && !errors.Is(err, &storage.ErrURLExists{}) {
+			return nil, status.Error(codes.DataLoss, "error while post long url in storage")
+		}
+		urls[i].ShortURL = stored.ShortURL
+	}
+	resp.Entities = modelURLInfoToProto(urls)
 	return &resp, nil
 }
 
@@ -54,7 +112,7 @@ func (s *ShortenerService) DeleteURLs(ctx context.Context, in *pb.DeleteURLsRequ
 	}
 
 	for _, v := range urls {
-		err := s.strg.DeleteByUserIDAndShort(userID, v)
+		err := s.strg.DeleteByUserIDAndShort(ctx, userID, s.domains.LinkKey(api.SplitLinkKey(v)))
 		if err != nil {
 			return nil, err
 		}
@@ -64,43 +122,175 @@ func (s *ShortenerService) DeleteURLs(ctx context.Context, in *pb.DeleteURLsRequ
 
 func (s *ShortenerService) FindByShortLink(ctx context.Context, in *pb.FindByShortLinkRequest) (*pb.URLInfo, error) {
 	var resp pb.URLInfo
-	url := in.GetShortUrl()
-	if url == "" {
+	short := in.GetShortUrl()
+	if short == "" {
 		return nil, status.Error(codes.InvalidArgument, "no url in request")
 	}
-	res, err := s.strg.Get(url)
+	res, err := s.strg.Get(ctx, s.domains.LinkKey(in.GetDomain(), short))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "short url not found")
+	}
 	if err != nil {
 		return nil, status.Error(codes.DataLoss, "error while get short url in storage")
 	}
-	resp.OriginalUrl = res.OriginalURL
+	if res.IsDeleted {
+		return nil, status.Error(codes.NotFound, "link is deleted")
+	}
+	if res.Disabled {
+		return nil, status.Error(codes.NotFound, "link is disabled")
+	}
+	if res.Expired(time.Now()) {
+		return nil, status.Error(codes.NotFound, "link is expired")
+	}
+	if err = s.policy.Recheck(ctx, res.OriginalURL); err != nil {
+		if policy.IsBlocked(err) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		s.log.Warnw("destination check failed, returning url", "error", err)
+	}
+	if err = s.strg.IncrementClicks(ctx, res.Key()); err != nil {
+		s.log.Warnw("failed to count click", "short_url", res.Key(), "error", err)
+	}
+	query := url.Values{}
+	for name, v := range in.GetQuery() {
+		query.Set(name, v)
+	}
+	resp.OriginalUrl = res.Target(query)
+	resp.RedirectType = res.RedirectType
 	return &resp, nil
 }
 
-func (s *ShortenerService) FindUserURLS(ctx context.Context, in *pb.FindUserURLSRequest) (*pb.BatchShortenAPIResponse, error) {
-	var resp pb.BatchShortenAPIResponse
+// UpdateURL changes destination, title, description, tags or active state of user link
+func (s *ShortenerService) UpdateURL(ctx context.Context, in *pb.UpdateURLRequest) (*pb.URLInfo, error) {
 	userID, err := getUserIDFromMD(ctx)
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, "no userID in metadata")
 	}
-	results, err := s.strg.GetBatchByUserID(userID)
+	if in.GetShortUrl() == "" {
+		return nil, status.Error(codes.InvalidArgument, "no short url in request")
+	}
+
+	upd := api.URLUpdate{
+		Title:       in.Title,
+		Description: in.Description,
+		Active:      in.Active,
+	}
+	if in.Tags != nil {
+		tags := in.Tags.GetValues()
+		upd.Tags = &tags
+	}
+	if in.ExpiresAt != nil {
+		var expiresAt time.Time
+		if in.ExpiresAt.GetSeconds() != 0 || in.ExpiresAt.GetNanos() != 0 {
+			expiresAt = in.ExpiresAt.AsTime()
+		}
+		upd.ExpiresAt = &expiresAt
+	}
+	if in.OriginalUrl != nil {
+		normalized, err := s.urlOpts.Normalize(in.GetOriginalUrl())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if err = s.checkDestination(ctx, normalized); err != nil {
+			return nil, err
+		}
+		upd.OriginalURL = &normalized
+	}
+
+	updated, err := s.strg.UpdateByUserIDAndShort(ctx, userID, s.domains.LinkKey(in.GetDomain(), in.GetShortUrl()), upd)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return nil, status.Error(codes.NotFound, "link not found")
+	case errors.Is(err, storage.ErrForbidden):
+		return nil, status.Error(codes.PermissionDenied, "link belongs to another user")
+	case errors.Is(err, &storage.ErrURLExists{}):
+		return nil, status.Errorf(codes.AlreadyExists, "destination is already shortened as %s", updated.ShortURL)
+	case err != nil:
+		return nil, status.Error(codes.Internal, "error while update url in storage")
+	}
+	return modelUserURLToProto(api.NewUserURLV1(updated, "")), nil
+}
+
+func (s *ShortenerService) FindUserURLS(ctx context.Context, in *pb.FindUserURLSRequest) (*pb.FindUserURLSResponse, error) {
+	userID, err := getUserIDFromMD(ctx)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "no userID in metadata")
+	}
+	page, err := s.strg.ListByUserID(ctx, userID, protoListQueryToModel(in))
+	if errors.Is(err, storage.ErrInvalidQuery) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 	if err != nil {
 		return nil, status.Error(codes.DataLoss, "error while get urls in storage")
 	}
-	urls := modelShortenedDataToProto(results)
-	resp.Entities = urls
-	return &resp, nil
+	return modelListPageToProto(page), nil
+}
+
+func protoListQueryToModel(in *pb.FindUserURLSRequest) api.URLListQuery {
+	return api.URLListQuery{
+		Deleted: in.Deleted,
+		Expired: in.Expired,
+		Tag:     in.GetTag(),
+		Domain:  in.GetDomain(),
+		Search:  in.GetSearch(),
+		Sort:    in.GetSort(),
+		Asc:     in.GetAscending(),
+		Limit:   int(in.GetLimit()),
+		Cursor:  in.GetCursor(),
+	}
+}
+
+func modelListPageToProto(page api.URLListPage) *pb.FindUserURLSResponse {
+	resp := pb.FindUserURLSResponse{
+		Total:      int32(page.Total),
+		NextCursor: page.NextCursor,
+	}
+	for _, v := range page.Items {
+		info := modelUserURLToProto(api.NewUserURLV1(v, ""))
+		info.UserID = v.UserID
+		resp.Entities = append(resp.Entities, info)
+	}
+	return &resp
 }
 
-func (s *ShortenerService) GetStats(context.Context, *pb.GetStatisticRequest) (*pb.GetStatisticResponse, error) {
+func (s *ShortenerService) GetStats(ctx context.Context, in *pb.GetStatisticRequest) (*pb.GetStatisticResponse, error) {
 	var resp pb.GetStatisticResponse
-	stat := s.strg.GetStatistic()
+	if !s.isTrusted(ctx) {
+		return nil, status.Error(codes.PermissionDenied, "client is not in trusted subnet")
+	}
+	q := api.StatisticQuery{Top: int(in.GetTop())}
+	if in.From != nil {
+		q.From = in.From.AsTime()
+	}
+	if in.To != nil {
+		q.To = in.To.AsTime()
+	}
+	stat, err := s.strg.GetStatistic(ctx, q)
+	if errors.Is(err, storage.ErrInvalidQuery) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error while get statistic from storage")
+	}
 	resp.Urls = int32(stat.URLs)
 	resp.Users = int32(stat.Users)
+	resp.Active = int32(stat.Active)
+	resp.Deleted = int32(stat.Deleted)
+	resp.Redirects = stat.Redirects
+	for _, v := range stat.CreatedPerDay {
+		resp.CreatedPerDay = append(resp.CreatedPerDay, &pb.DayCount{Day: v.Day, Count: int32(v.Count)})
+	}
+	for _, v := range stat.TopDomains {
+		resp.TopDomains = append(resp.TopDomains, &pb.DomainCount{Domain: v.Domain, Count: int32(v.Count)})
+	}
+	for _, v := range stat.TopLinks {
+		resp.TopLinks = append(resp.TopLinks, &pb.LinkClicks{ShortUrl: v.ShortURL, OriginalUrl: v.OriginalURL, Clicks: v.Clicks})
+	}
 	return &resp, nil
 }
 
-func (s *ShortenerService) Ping(context.Context, *pb.PingRequest) (*pb.PingResponse, error) {
-	err := s.strg.Ping()
+func (s *ShortenerService) Ping(ctx context.Context, _ *pb.PingRequest) (*pb.PingResponse, error) {
+	err := s.strg.Ping(ctx)
 	return &pb.PingResponse{}, err
 }
 
@@ -111,52 +301,77 @@ func (s *ShortenerService) ShortenAPI(ctx context.Context, in *pb.ShortenAPIRequ
 		return nil, status.Error(codes.InvalidArgument, "no userID in metadata")
 	}
 
-	originURL := in.GetUrl()
-	shortURL := hashutil.Encode([]byte(originURL))
-
+	originURL, err := s.urlOpts.Normalize(in.GetUrl())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	domain, err := s.domains.Choose(in.GetDomain())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err = s.checkDestination(ctx, originURL); err != nil {
+		return nil, err
+	}
 	shortenedData := api.ShortenedData{
 		UserID:      userID,
 		UUID:        uuid.New().String(),
-		ShortURL:    shortURL,
+		Domain:      domain,
 		OriginalURL: originURL,
-		IsDeleted:   false,
+	}
+	if err = shortenedData.SetRedirect(in.GetRedirectType(), in.GetPassQuery(), utmFromProto(in.GetUtm())); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	res, err := s.strg.Store(shortenedData)
+	stored, err := storage.Shorten(ctx, s.strg, shortenedData)
+	if errors.Is(err, &storage.ErrURLExists{}) {
+		return nil, status.Errorf(codes.AlreadyExists, "url already shortened as %s", stored.ShortURL)
+	}
 	if err != nil {
 		return nil, status.Error(codes.DataLoss, "error while post long url in storage")
 	}
-	resp.Result = res.ShortURL
+	resp.Result = stored.ShortURL
+	resp.Domain = stored.Domain
 	return &resp, nil
 }
 
 func (s *ShortenerService) Shorten(ctx context.Context, in *pb.ShortenRequest) (*pb.ShortenResponse, error) {
 	resp := pb.ShortenResponse{}
-	url := in.GetOriginalUrl()
 	userID, err := getUserIDFromMD(ctx)
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, "no userID in metadata")
 	}
 
-	if url == "" {
-		return nil, status.Error(codes.InvalidArgument, "no url in request")
+	url, err := s.urlOpts.Normalize(in.GetOriginalUrl())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	domain, err := s.domains.Choose(in.GetDomain())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err = s.checkDestination(ctx, url); err != nil {
+		return nil, err
 	}
-
-	shortURL := hashutil.Encode([]byte(url))
 
 	shortenedData := api.ShortenedData{
 		UserID:      userID,
 		UUID:        uuid.New().String(),
-		ShortURL:    shortURL,
+		Domain:      domain,
 		OriginalURL: url,
-		IsDeleted:   false,
+	}
+	if err = shortenedData.SetRedirect(in.GetRedirectType(), in.GetPassQuery(), utmFromProto(in.GetUtm())); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	short, err := s.strg.Store(shortenedData)
+	stored, err := storage.Shorten(ctx, s.strg, shortenedData)
+	if errors.Is(err, &storage.ErrURLExists{}) {
+		return nil, status.Errorf(codes.AlreadyExists, "url already shortened as %s", stored.ShortURL)
+	}
 	if err != nil {
 		return nil, status.Error(codes.DataLoss, "error while post long url in storage")
 	}
-	resp.ShortUrl = short.ShortURL
+	resp.ShortUrl = stored.ShortURL
+	resp.Domain = stored.Domain
 	return &resp, nil
 }
 
@@ -168,6 +383,7 @@ func protoURLInfoToModel(urls []*pb.URLInfo) []api.URLInfo {
 			UserID:        v.UserID,
 			CorrelationID: v.CorrelationId,
 			OriginalURL:   v.OriginalUrl,
+			Domain:        v.Domain,
 			ShortURL:      v.ShortUrl,
 			IsDeleted:     v.GetIsDeleted(),
 		}
@@ -184,6 +400,7 @@ func modelURLInfoToProto(urls []api.URLInfo) []*pb.URLInfo {
 			UserID:        v.UserID,
 			CorrelationId: v.CorrelationID,
 			OriginalUrl:   v.OriginalURL,
+			Domain:        v.Domain,
 			ShortUrl:      v.ShortURL,
 			IsDeleted:     v.IsDeleted,
 		}
@@ -192,20 +409,69 @@ func modelURLInfoToProto(urls []api.URLInfo) []*pb.URLInfo {
 	return convertedURLS
 }
 
-func modelShortenedDataToProto(urls []api.ShortenedData) []*pb.URLInfo {
-	var convertedURLS []*pb.URLInfo
-	for _, v := range urls {
-		newURL := pb.URLInfo{
-			Uuid:          v.UUID,
-			UserID:        v.UserID,
-			CorrelationId: v.OriginalURL,
-			OriginalUrl:   v.OriginalURL,
-			ShortUrl:      v.ShortURL,
-			IsDeleted:     v.IsDeleted,
-		}
-		convertedURLS = append(convertedURLS, &newURL)
+func modelUserURLToProto(v api.UserURLV1) *pb.URLInfo {
+	converted := pb.URLInfo{
+		Uuid:         v.UUID,
+		OriginalUrl:  v.OriginalURL,
+		Domain:       v.Domain,
+		ShortUrl:     v.ShortURL,
+		IsDeleted:    v.IsDeleted,
+		RedirectType: v.RedirectType,
+		PassQuery:    v.PassQuery,
+		Utm:          utmToProto(v.UTM),
+		Title:        v.Title,
+		Description:  v.Description,
+		Tags:         v.Tags,
+		Active:       v.Active,
+		Clicks:       v.Clicks,
 	}
-	return convertedURLS
+	if !v.CreatedAt.IsZero() {
+		converted.CreatedAt = timestamppb.New(v.CreatedAt)
+	}
+	if v.ExpiresAt != nil {
+		converted.ExpiresAt = timestamppb.New(*v.ExpiresAt)
+	}
+	return &converted
+}
+
+func utmFromProto(u *pb.UTM) *api.UTM {
+	if u == nil {
+		return nil
+	}
+	return &api.UTM{Source: u.Source, Medium: u.Medium, Campaign: u.Campaign, Term: u.Term, Content: u.Content}
+}
+
+func utmToProto(u *api.UTM) *pb.UTM {
+	if u == nil {
+		return nil
+	}
+	return &pb.UTM{Source: u.Source, Medium: u.Medium, Campaign: u.Campaign, Term: u.Term, Content: u.Content}
+}
+
+// checkDestination returns status error when policy rejects url or cannot check it
+func (s *ShortenerService) checkDestination(ctx context.Context, url string) error {
+	err := s.policy.Check(ctx, url)
+	switch {
+	case err == nil:
+		return nil
+	case policy.IsBlocked(err):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		s.log.Errorw("destination check failed", "error", err)
+		return status.Error(codes.Unavailable, "destination check unavailable")
+	}
+}
+
+func (s *ShortenerService) isTrusted(ctx context.Context) bool {
+	s.mu.RLock()
+	subnet := s.trustedSubnet
+	s.mu.RUnlock()
+
+	trusted, err := clientip.ParseNets(subnet)
+	if err != nil {
+		return false
+	}
+	return trusted.Contains(clientip.FromCall(ctx))
 }
 
 func getUserIDFromMD(ctx context.Context) (string, error) {
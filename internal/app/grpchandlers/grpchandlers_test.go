@@ -0,0 +1,46 @@
+package grpchandlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+	"github.com/gsk148/urlShorteningService/internal/app/domains"
+	"github.com/gsk148/urlShorteningService/internal/app/logger"
+	pb "github.com/gsk148/urlShorteningService/internal/app/proto"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+	"github.com/gsk148/urlShorteningService/internal/app/urlnorm"
+)
+
+func TestFindByShortLink(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	svc := NewShortenerService(store, *logger.NewLogger(), "", urlnorm.Options{}, nil, domains.Single("http://localhost:8080"))
+	for _, data := range []api.ShortenedData{
+		{UserID: "u1", ShortURL: "live", OriginalURL: "https://live.example/"},
+		{UserID: "u1", ShortURL: "gone", OriginalURL: "https://gone.example/"},
+		{UserID: "u1", ShortURL: "off", OriginalURL: "https://off.example/", Disabled: true},
+	} {
+		_, err := store.Store(ctx, data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.DeleteByUserIDAndShort(ctx, "u1", "gone"))
+
+	resp, err := svc.FindByShortLink(ctx, &pb.FindByShortLinkRequest{ShortUrl: "live"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://live.example/", resp.GetOriginalUrl())
+
+	for _, short := range []string{"missing", "gone", "off"} {
+		_, err = svc.FindByShortLink(ctx, &pb.FindByShortLinkRequest{ShortUrl: short})
+		assert.Equal(t, codes.NotFound, status.Code(err), short)
+	}
+
+	gone, err := store.Get(ctx, "gone")
+	require.NoError(t, err)
+	assert.Zero(t, gone.Clicks)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/gsk148/urlShorteningService/internal/app/admin"
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+	"github.com/gsk148/urlShorteningService/internal/app/clientip"
+	"github.com/gsk148/urlShorteningService/internal/app/logger"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+)
+
+// adminRoutes mounts operator API, every route requires trusted subnet and admin token
+func (h *Handler) adminRoutes(r chi.Router) {
+	r.Use(h.adminOnly)
+	r.Get("/links/{short}", h.AdminGetLink)
+	r.Patch("/links/{short}", h.AdminUpdateLink)
+	r.Delete("/domains/{domain}/links", h.AdminDeleteByDomain)
+	r.Get("/users/{userID}/links", h.AdminUserLinks)
+	r.Get("/audit", h.AdminAudit)
+	r.Get("/export", h.AdminExport)
+	r.Post("/import", h.AdminImport)
+}
+
+func (h *Handler) adminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := h.Admin.Authorize(clientip.FromRequest(r), admin.BearerToken(r.Header.Get("Authorization")))
+		switch {
+		case errors.Is(err, admin.ErrDisabled):
+			http.NotFound(w, r)
+		case errors.Is(err, admin.ErrUntrusted):
+			w.WriteHeader(http.StatusForbidden)
+		case errors.Is(err, admin.ErrBadToken):
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// adminActor names operator in audit trail
+func adminActor(r *http.Request) string {
+	if ip := clientip.FromRequest(r); ip != nil {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
+
+// AdminGetLink returns any link with its owner
+func (h *Handler) AdminGetLink(w http.ResponseWriter, r *http.Request) {
+	data, err := h.Admin.GetLink(r.Context(), adminActor(r), h.linkKey(r))
+	if err != nil {
+		h.writeAdminError(w, r, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, data)
+}
+
+// AdminUpdateLink disables, enables or reassigns any link
+func (h *Handler) AdminUpdateLink(w http.ResponseWriter, r *http.Request) {
+	var upd api.AdminUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		http.Error(w, "Unmarshalling request failed", http.StatusBadRequest)
+		return
+	}
+	data, err := h.Admin.UpdateLink(r.Context(), adminActor(r), h.linkKey(r), upd)
+	if err != nil {
+		h.writeAdminError(w, r, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, data)
+}
+
+// AdminDeleteByDomain marks deleted every link to domain or its subdomains
+func (h *Handler) AdminDeleteByDomain(w http.ResponseWriter, r *http.Request) {
+	deleted, err := h.Admin.DeleteByDomain(r.Context(), adminActor(r), chi.URLParam(r, "domain"))
+	if err != nil {
+		h.writeAdminError(w, r, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]int{"deleted": deleted})
+}
+
+// AdminUserLinks returns page of links owned by user, query is the same as for user listing
+func (h *Handler) AdminUserLinks(w http.ResponseWriter, r *http.Request) {
+	q, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := h.Admin.ListUserLinks(r.Context(), adminActor(r), chi.URLParam(r, "userID"), q)
+	if err != nil {
+		h.writeAdminError(w, r, err)
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	if page.Items == nil {
+		page.Items = []api.ShortenedData{}
+	}
+	h.writeJSON(w, http.StatusOK, page.Items)
+}
+
+// AdminAudit returns latest operator actions, newest first
+func (h *Handler) AdminAudit(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			http.Error(w, "limit must be positive number", http.StatusBadRequest)
+			return
+		}
+	}
+	h.writeJSON(w, http.StatusOK, h.Admin.Trail().Recent(limit))
+}
+
+// writeAdminError maps errors of admin actions to response codes
+func (h *Handler) writeAdminError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, admin.ErrInvalidArgument), errors.Is(err, storage.ErrInvalidQuery):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(w, "Link not found", http.StatusNotFound)
+	default:
+		logger.WithTrace(r.Context(), &h.Logger).Errorw("admin action failed", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -4,36 +4,93 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"expvar"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/http/pprof"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 
+	"github.com/gsk148/urlShorteningService/internal/app/admin"
 	"github.com/gsk148/urlShorteningService/internal/app/api"
 	"github.com/gsk148/urlShorteningService/internal/app/auth"
+	"github.com/gsk148/urlShorteningService/internal/app/clientip"
 	"github.com/gsk148/urlShorteningService/internal/app/compress"
-	"github.com/gsk148/urlShorteningService/internal/app/hashutil"
+	"github.com/gsk148/urlShorteningService/internal/app/config"
+	"github.com/gsk148/urlShorteningService/internal/app/deletion"
+	"github.com/gsk148/urlShorteningService/internal/app/domains"
+	"github.com/gsk148/urlShorteningService/internal/app/health"
 	"github.com/gsk148/urlShorteningService/internal/app/logger"
+	"github.com/gsk148/urlShorteningService/internal/app/policy"
+	"github.com/gsk148/urlShorteningService/internal/app/ratelimit"
 	"github.com/gsk148/urlShorteningService/internal/app/storage"
+	"github.com/gsk148/urlShorteningService/internal/app/tracing"
+	"github.com/gsk148/urlShorteningService/internal/app/urlnorm"
 )
 
 // Handler structure of Handler
 type Handler struct {
 	BaseURL       string
+	Domains       *domains.Registry
 	TrustedSubnet string
 	Store         storage.Storage
+	Deletions     *deletion.Queue
+	Health        *health.Checker
+	Limiter       *ratelimit.Limiter
+	ClientIP      *clientip.Resolver
+	Admin         *admin.Service
+	URLOptions    urlnorm.Options
+	Policy        *policy.Policy
 	Logger        zap.SugaredLogger
+
+	// mu guards settings changed by ApplyConfig
+	mu sync.RWMutex
+}
+
+// ApplyConfig swaps reloadable settings of running handler
+func (h *Handler) ApplyConfig(cfg *config.Config) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.TrustedSubnet = cfg.TrustedSubnet
+}
+
+// shortDomains returns registry of short domains, only BaseURL is served when Domains is not set
+func (h *Handler) shortDomains() *domains.Registry {
+	if h.Domains == nil {
+		return domains.Single(h.BaseURL)
+	}
+	return h.Domains
+}
+
+// chooseDomain returns domain of new link named by user and writes error response when it is unknown
+func (h *Handler) chooseDomain(w http.ResponseWriter, name string) (string, bool) {
+	domain, err := h.shortDomains().Choose(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return domain, true
+}
+
+// linkKey returns storage key of {short} path parameter on domain of "domain" query parameter
+func (h *Handler) linkKey(r *http.Request) string {
+	return h.shortDomains().LinkKey(r.URL.Query().Get("domain"), chi.URLParam(r, "short"))
 }
 
 func (h *Handler) InitRoutes() *chi.Mux {
 	r := chi.NewRouter()
 
+	r.Use(tracing.Middleware)
 	r.Use(middleware.Compress(5,
 		"application/javascript",
 		"application/json",
@@ -42,7 +99,13 @@ func (h *Handler) InitRoutes() *chi.Mux {
 		"text/plain",
 		"text/xml"))
 	r.Use(compress.Middleware)
+	if h.ClientIP != nil {
+		r.Use(h.ClientIP.Middleware)
+	}
 	r.Use(logger.WithLogging)
+	if h.Limiter != nil {
+		r.Use(h.Limiter.Middleware)
+	}
 
 	r.Group(func(r chi.Router) {
 		r.Use(middleware.AllowContentType("application/json"))
@@ -50,13 +113,26 @@ func (h *Handler) InitRoutes() *chi.Mux {
 		r.Post("/api/shorten/batch", h.BatchShortenAPI)
 		r.Get("/api/user/urls", h.FindUserURLS)
 		r.Delete("/api/user/urls", h.DeleteURLs)
+		r.Patch("/api/user/urls/{short}", h.UpdateURL)
+		r.Get("/api/user/urls/{short}/history", h.URLHistory)
 	})
 
+	r.Get("/api/user/urls/export", h.ExportURLs)
+	r.Post("/api/user/urls/import", h.ImportURLs)
+
 	r.Post("/", h.Shorten)
 	r.Get("/{id}", h.FindByShortLink)
 	r.Get("/ping", h.Ping)
+	if h.Health != nil {
+		r.Get("/healthz", h.Health.LiveHandler)
+		r.Get("/readyz", h.Health.ReadyHandler)
+	}
 	r.Get("/api/internal/stats", h.GetStats)
+	if h.Admin != nil {
+		r.Route("/api/admin", h.adminRoutes)
+	}
 
+	r.Handle("/debug/vars", expvar.Handler())
 	r.HandleFunc("/debug/pprof", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, r.URL.Path[1:])
 	})
@@ -76,7 +152,8 @@ func (h *Handler) InitRoutes() *chi.Mux {
 	return r
 }
 
-// Shorten save provided in text/plain format full url and returns short
+// Shorten save provided in text/plain format full url and returns short,
+// short domain and redirect settings are given by query parameters
 func (h *Handler) Shorten(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Not supported", http.StatusBadRequest)
@@ -93,36 +170,54 @@ func (h *Handler) Shorten(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	encoded := hashutil.Encode(body)
+	originalURL, err := h.URLOptions.Normalize(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	domain, ok := h.chooseDomain(w, query.Get("domain"))
+	if !ok {
+		return
+	}
+	data := api.ShortenedData{
+		UUID:        uuid.New().String(),
+		Domain:      domain,
+		OriginalURL: originalURL,
+	}
+	passQuery := false
+	if v := query.Get("pass_query"); v != "" {
+		if passQuery, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "pass_query must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+	if err = data.SetRedirect(query.Get("redirect_type"), passQuery, api.ParseUTM(query)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !h.allowDestination(w, r, originalURL) {
+		return
+	}
 
-	userID, err := auth.GetUserToken(w, r)
+	data.UserID, err = auth.GetUserToken(w, r)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	storedData, err := h.Store.Store(api.ShortenedData{
-		UserID:      userID,
-		UUID:        uuid.New().String(),
-		ShortURL:    encoded,
-		OriginalURL: string(body),
-	})
+	status := http.StatusCreated
+	storedData, err := storage.Shorten(r.Context(), h.Store, data)
 	if err != nil {
-		if errors.Is(err, &storage.ErrURLExists{}) {
-			w.Header().Set("content-type", "text/plain")
-			w.WriteHeader(http.StatusConflict)
-			url := h.BaseURL + "/" + storedData.ShortURL
-			_, err = w.Write([]byte(url))
-			if err != nil {
-				http.Error(w, "Failed to write response", http.StatusInternalServerError)
-				return
-			}
+		if !errors.Is(err, &storage.ErrURLExists{}) {
+			http.Error(w, "Failed to store url", http.StatusInternalServerError)
 			return
 		}
+		status = http.StatusConflict
 	}
 	w.Header().Set("content-type", "text/plain")
-	w.WriteHeader(http.StatusCreated)
-	url := h.BaseURL + "/" + encoded
+	w.WriteHeader(status)
+	url := h.shortDomains().ShortURL(storedData)
 	_, err = w.Write([]byte(url))
 	if err != nil {
 		http.Error(w, "Failed to write response", http.StatusInternalServerError)
@@ -130,14 +225,15 @@ func (h *Handler) Shorten(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// FindByShortLink returns full url by provided id
+// FindByShortLink redirects to full url by provided id on short domain of request Host,
+// response depends on redirect type of link
 func (h *Handler) FindByShortLink(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Not supported", http.StatusBadRequest)
 		return
 	}
-	shortLink := chi.URLParam(r, "id")
-	data, err := h.Store.Get(shortLink)
+	key := api.LinkKey(h.shortDomains().Resolve(r.Host), chi.URLParam(r, "id"))
+	data, err := h.Store.Get(r.Context(), key)
 	if err != nil {
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
@@ -146,10 +242,27 @@ func (h *Handler) FindByShortLink(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusGone)
 		return
 	}
+	if data.Disabled {
+		http.Error(w, "Link is disabled", http.StatusNotFound)
+		return
+	}
+	if data.Expired(time.Now()) {
+		http.Error(w, "Link is expired", http.StatusGone)
+		return
+	}
+	// lists may have changed since the link was created; unavailable checkers do not break links
+	if err = h.Policy.Recheck(r.Context(), data.OriginalURL); err != nil {
+		if policy.IsBlocked(err) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		logger.WithTrace(r.Context(), &h.Logger).Warnw("destination check failed, redirecting", "error", err)
+	}
+	if err = h.Store.IncrementClicks(r.Context(), data.Key()); err != nil {
+		logger.WithTrace(r.Context(), &h.Logger).Warnw("failed to count click", "short_url", data.Key(), "error", err)
+	}
 
-	w.Header().Set("content-type", "text/plain")
-	w.Header().Set("Location", data.OriginalURL)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	writeRedirect(w, r, data)
 }
 
 // ShortenAPI save provided in json format full url and returns short
@@ -171,43 +284,51 @@ func (h *Handler) ShortenAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	encoded := hashutil.Encode([]byte(request.URL))
-	var response api.ShortenResponse
-	response.Result = h.BaseURL + "/" + encoded
-	result, err := json.Marshal(response)
+	originalURL, err := h.URLOptions.Normalize(request.URL)
 	if err != nil {
-		http.Error(w, "Marshaling response failed", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	domain, ok := h.chooseDomain(w, request.Domain)
+	if !ok {
+		return
+	}
+	data := api.ShortenedData{
+		UUID:        uuid.New().String(),
+		Domain:      domain,
+		OriginalURL: originalURL,
+	}
+	if err = data.SetRedirect(request.RedirectType, request.PassQuery, request.UTM); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !h.allowDestination(w, r, originalURL) {
 		return
 	}
 
-	userID, err := auth.GetUserToken(w, r)
+	data.UserID, err = auth.GetUserToken(w, r)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
 
-	_, err = h.Store.Store(api.ShortenedData{
-		UserID:      userID,
-		UUID:        uuid.New().String(),
-		ShortURL:    encoded,
-		OriginalURL: request.URL,
-		IsDeleted:   false,
-	})
+	status := http.StatusCreated
+	storedData, err := storage.Shorten(r.Context(), h.Store, data)
 	if err != nil {
-		if errors.Is(err, &storage.ErrURLExists{}) {
-			w.Header().Set("content-type", "application/json")
-			w.WriteHeader(http.StatusConflict)
-
-			_, err = w.Write(result)
-			if err != nil {
-				http.Error(w, "Failed to write response", http.StatusInternalServerError)
-				return
-			}
+		if !errors.Is(err, &storage.ErrURLExists{}) {
+			http.Error(w, "Failed to store url", http.StatusInternalServerError)
 			return
 		}
+		status = http.StatusConflict
 	}
 
+	result, err := json.Marshal(api.ShortenResponse{Result: h.shortDomains().ShortURL(storedData)})
+	if err != nil {
+		http.Error(w, "Marshaling response failed", http.StatusBadRequest)
+		return
+	}
 	w.Header().Set("content-type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	_, err = w.Write(result)
 	if err != nil {
 		http.Error(w, "Failed to write response", http.StatusInternalServerError)
@@ -215,9 +336,24 @@ func (h *Handler) ShortenAPI(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// allowDestination checks url against policy and writes error response when it is rejected
+func (h *Handler) allowDestination(w http.ResponseWriter, r *http.Request, url string) bool {
+	err := h.Policy.Check(r.Context(), url)
+	switch {
+	case err == nil:
+		return true
+	case policy.IsBlocked(err):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		logger.WithTrace(r.Context(), &h.Logger).Errorw("destination check failed", "error", err)
+		http.Error(w, "Destination check unavailable", http.StatusServiceUnavailable)
+	}
+	return false
+}
+
 // Ping makes test connection to storage
 func (h *Handler) Ping(res http.ResponseWriter, req *http.Request) {
-	if err := h.Store.Ping(); err != nil {
+	if err := h.Store.Ping(req.Context()); err != nil {
 		res.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -240,24 +376,35 @@ func (h *Handler) BatchShortenAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	for _, reqItem := range reqItems {
-		shortURL := hashutil.Encode([]byte(reqItem.OriginalURL))
-
-		_, err := h.Store.Store(api.ShortenedData{
-			UserID:      userID,
-			UUID:        uuid.New().String(),
-			ShortURL:    shortURL,
-			OriginalURL: reqItem.OriginalURL,
-			IsDeleted:   false,
-		})
+	links := make([]api.ShortenedData, len(reqItems))
+	for i, reqItem := range reqItems {
+		links[i] = api.ShortenedData{UserID: userID, UUID: uuid.New().String()}
+		links[i].OriginalURL, err = h.URLOptions.Normalize(reqItem.OriginalURL)
+		if err == nil {
+			links[i].Domain, err = h.shortDomains().Choose(reqItem.Domain)
+		}
+		if err == nil {
+			err = links[i].SetRedirect(reqItem.RedirectType, reqItem.PassQuery, reqItem.UTM)
+		}
 		if err != nil {
+			http.Error(w, "correlation_id "+reqItem.CorrelationID+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !h.allowDestination(w, r, links[i].OriginalURL) {
+			return
+		}
+	}
+
+	for i, reqItem := range reqItems {
+		stored, err := storage.Shorten(r.Context(), h.Store, links[i])
+		if err != nil && !errors.Is(err, &storage.ErrURLExists{}) {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		respItems = append(respItems, api.BatchShortenResponseItem{
 			CorrelationID: reqItem.CorrelationID,
-			ShortURL:      h.BaseURL + "/" + shortURL,
+			ShortURL:      h.shortDomains().ShortURL(stored),
 		})
 	}
 
@@ -275,7 +422,8 @@ func (h *Handler) BatchShortenAPI(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// FindUserURLS returns array of all saved by user urls
+// FindUserURLS returns page of saved by user urls. Total number of matching urls
+// and cursor of next page are passed in X-Total-Count and X-Next-Cursor headers
 func (h *Handler) FindUserURLS(w http.ResponseWriter, r *http.Request) {
 	userID, err := auth.GetUserToken(w, r)
 	if err != nil {
@@ -283,38 +431,69 @@ func (h *Handler) FindUserURLS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	batch, err := h.Store.GetBatchByUserID(userID)
+	q, err := parseListQuery(r.URL.Query())
 	if err != nil {
-		w.WriteHeader(http.StatusNoContent)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	if len(batch) < 1 {
-		w.WriteHeader(http.StatusNoContent)
+	page, err := h.Store.ListByUserID(r.Context(), userID, q)
+	if errors.Is(err, storage.ErrInvalidQuery) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	type UserLinksResponse struct {
-		ShortURL    string `json:"short_url"`
-		OriginalURL string `json:"original_url"`
+	if err != nil {
+		logger.WithTrace(r.Context(), &h.Logger).Errorw("failed to list user urls", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
-	var result []UserLinksResponse
 
-	for _, v := range batch {
-		shortURL := h.BaseURL + "/" + v.ShortURL
-		b := &UserLinksResponse{shortURL, v.OriginalURL}
-		result = append(result, *b)
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
 	}
-
-	response, err := json.Marshal(result)
-	if err != nil {
+	if len(page.Items) < 1 {
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(response)
+	h.writeJSON(w, http.StatusOK, api.NewUserURLsV1(page.Items, h.shortDomains().BaseURL))
 }
 
-// DeleteURLs removes array of provided urls
+// parseListQuery reads filters and page of user links listing from query string
+func parseListQuery(values url.Values) (api.URLListQuery, error) {
+	q := api.URLListQuery{
+		Tag:    values.Get("tag"),
+		Domain: values.Get("domain"),
+		Search: values.Get("q"),
+		Sort:   values.Get("sort"),
+		Cursor: values.Get("cursor"),
+	}
+	var err error
+	if v := values.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 {
+			return q, errors.New("limit must be positive number")
+		}
+	}
+	for name, dst := range map[string]**bool{"deleted": &q.Deleted, "expired": &q.Expired} {
+		if v := values.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return q, fmt.Errorf("%s must be true or false", name)
+			}
+			*dst = &b
+		}
+	}
+	switch values.Get("order") {
+	case "", "desc":
+	case "asc":
+		q.Asc = true
+	default:
+		return q, errors.New("order must be asc or desc")
+	}
+	return q, nil
+}
+
+// DeleteURLs removes array of provided urls, links of other than primary domain
+// are given as domain/short
 func (h *Handler) DeleteURLs(w http.ResponseWriter, r *http.Request) {
 	var inputArray []string
 	userID, err := auth.GetUserToken(w, r)
@@ -333,32 +512,100 @@ func (h *Handler) DeleteURLs(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	for i, key := range inputArray {
+		inputArray[i] = h.shortDomains().LinkKey(api.SplitLinkKey(key))
+	}
 
-	inputCh := addShortURLs(inputArray)
-	go h.MarkAsDeleted(inputCh, userID)
+	if err = h.Deletions.Enqueue(r.Context(), userID, inputArray); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
 
 	w.WriteHeader(http.StatusAccepted)
 }
 
-// MarkAsDeleted set flag deleted=true for provided short url
-func (h *Handler) MarkAsDeleted(inputShort chan string, userID string) {
-	for v := range inputShort {
-		err := h.Store.DeleteByUserIDAndShort(userID, v)
+// UpdateURL changes destination, title, description, tags or active state of user link,
+// link of other than primary domain is chosen by "domain" query parameter
+func (h *Handler) UpdateURL(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserToken(w, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var upd api.URLUpdate
+	if err = json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		http.Error(w, "Unmarshalling request failed", http.StatusBadRequest)
+		return
+	}
+	if upd.OriginalURL != nil {
+		normalized, err := h.URLOptions.Normalize(*upd.OriginalURL)
 		if err != nil {
-			h.Logger.Warnf("Failed to mark deleted by short %s", v)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !h.allowDestination(w, r, normalized) {
+			return
 		}
+		upd.OriginalURL = &normalized
 	}
+
+	updated, err := h.Store.UpdateByUserIDAndShort(r.Context(), userID, h.linkKey(r), upd)
+	if err != nil {
+		h.writeStorageError(w, r, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, api.NewUserURLV1(updated, h.shortDomains().BaseURL(updated.Domain)))
 }
 
-// GetStats returns count of urls and users
-func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP == "" {
-		w.WriteHeader(http.StatusForbidden)
+// URLHistory returns previous destinations of user link
+func (h *Handler) URLHistory(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserToken(w, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	history, err := h.Store.GetHistoryByUserIDAndShort(r.Context(), userID, h.linkKey(r))
+	if err != nil {
+		h.writeStorageError(w, r, err)
+		return
+	}
+	if history == nil {
+		history = []api.URLHistoryEntry{}
+	}
+	h.writeJSON(w, http.StatusOK, history)
+}
+
+// writeStorageError maps storage errors of link management to response codes
+func (h *Handler) writeStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(w, "Link not found", http.StatusNotFound)
+	case errors.Is(err, storage.ErrForbidden):
+		http.Error(w, "Link belongs to another user", http.StatusForbidden)
+	case errors.Is(err, &storage.ErrURLExists{}):
+		http.Error(w, "Destination is already shortened", http.StatusConflict)
+	default:
+		logger.WithTrace(r.Context(), &h.Logger).Errorw("storage request failed", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, v any) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Marshaling response failed", http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
 
-	isTrusted, err := h.checkIPIsTrusted(realIP)
+// GetStats returns count of urls and users
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	isTrusted, err := h.checkIPIsTrusted(clientip.FromRequest(r))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -369,8 +616,18 @@ func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stat := h.Store.GetStatistic()
-	if stat == nil {
+	q, err := parseStatisticQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	stat, err := h.Store.GetStatistic(r.Context(), q)
+	if errors.Is(err, storage.ErrInvalidQuery) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger.WithTrace(r.Context(), &h.Logger).Errorw("failed to get statistic", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -384,32 +641,41 @@ func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
 	w.Write(resp)
 }
 
-func (h *Handler) checkIPIsTrusted(clientIP string) (bool, error) {
-	_, trustedIP, err := net.ParseCIDR(h.TrustedSubnet)
-	if err != nil {
-		return false, err
-	}
-
-	parsedIP := net.ParseIP(clientIP)
-	if parsedIP == nil {
-		return false, err
+// parseStatisticQuery reads window of statistic from query string, bounds are
+// RFC 3339 time or UTC day
+func parseStatisticQuery(values url.Values) (api.StatisticQuery, error) {
+	var (
+		q   api.StatisticQuery
+		err error
+	)
+	for name, dst := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		v := values.Get(name)
+		if v == "" {
+			continue
+		}
+		if *dst, err = time.Parse(time.RFC3339, v); err != nil {
+			if *dst, err = time.Parse("2006-01-02", v); err != nil {
+				return q, fmt.Errorf("%s must be RFC 3339 time or YYYY-MM-DD day", name)
+			}
+		}
 	}
-
-	if !trustedIP.Contains(parsedIP) {
-		return false, nil
+	if v := values.Get("top"); v != "" {
+		if q.Top, err = strconv.Atoi(v); err != nil || q.Top < 1 {
+			return q, errors.New("top must be positive number")
+		}
 	}
-	return true, nil
+	return q, nil
 }
 
-func addShortURLs(input []string) chan string {
-	inputCh := make(chan string, 10)
+// checkIPIsTrusted reports whether client belongs to one of trusted subnets
+func (h *Handler) checkIPIsTrusted(clientIP net.IP) (bool, error) {
+	h.mu.RLock()
+	subnet := h.TrustedSubnet
+	h.mu.RUnlock()
 
-	go func() {
-		defer close(inputCh)
-		for _, url := range input {
-			inputCh <- url
-		}
-	}()
-
-	return inputCh
+	trusted, err := clientip.ParseNets(subnet)
+	if err != nil {
+		return false, err
+	}
+	return trusted.Contains(clientIP), nil
 }
@@ -2,17 +2,24 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/gsk148/urlShorteningService/internal/app/admin"
 	"github.com/gsk148/urlShorteningService/internal/app/api"
+	"github.com/gsk148/urlShorteningService/internal/app/config"
+	"github.com/gsk148/urlShorteningService/internal/app/deletion"
+	"github.com/gsk148/urlShorteningService/internal/app/domains"
 	"github.com/gsk148/urlShorteningService/internal/app/logger"
 	"github.com/gsk148/urlShorteningService/internal/app/storage"
 )
@@ -23,6 +30,7 @@ func getTestHandler(store storage.Storage) *Handler {
 		BaseURL:       "http://localhost:8080",
 		TrustedSubnet: "127.0.0.1/24",
 		Store:         store,
+		Deletions:     deletion.NewQueue(store, *myLog, deletion.DefaultQueueSize),
 		Logger:        *myLog,
 	}
 
@@ -83,6 +91,16 @@ func TestCreateShortLinkHandler(t *testing.T) {
 				contentType: "text/plain; charset=utf-8",
 			},
 		},
+		{
+			name:          "create short link not allowed scheme",
+			requestMethod: http.MethodPost,
+			requestPath:   "/",
+			requestData:   "javascript:alert(1)",
+			want: want{
+				code:        400,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
 	}
 
 	h := getTestHandler(storage.NewInMemoryStorage())
@@ -111,7 +129,7 @@ func TestFailCreateShortLink(t *testing.T) {
 			dbMock := storage.NewMockStorage(ctrl)
 
 			data := api.ShortenedData{}
-			dbMock.EXPECT().Store(gomock.Any()).Return(data, new(storage.ErrURLExists))
+			dbMock.EXPECT().Store(gomock.Any(), gomock.Any()).Return(data, new(storage.ErrURLExists))
 
 			handler := getTestHandler(dbMock)
 
@@ -187,7 +205,8 @@ func TestDeletedFindByShortLink(t *testing.T) {
 				OriginalURL: "praktikum.yandex.ru",
 				IsDeleted:   false,
 			}
-			dbMock.EXPECT().Get(gomock.Any()).Return(mockedDBResult, nil)
+			dbMock.EXPECT().Get(gomock.Any(), gomock.Any()).Return(mockedDBResult, nil)
+			dbMock.EXPECT().IncrementClicks(gomock.Any(), gomock.Any()).Return(nil)
 
 			handler := getTestHandler(dbMock)
 			request := httptest.NewRequest(http.MethodGet, "/ngaCAPJ", nil)
@@ -207,7 +226,28 @@ func TestDeletedFindByShortLink(t *testing.T) {
 				OriginalURL: "praktikum.yandex.ru",
 				IsDeleted:   true,
 			}
-			dbMock.EXPECT().Get(gomock.Any()).Return(mockedDBResult, nil)
+			dbMock.EXPECT().Get(gomock.Any(), gomock.Any()).Return(mockedDBResult, nil)
+
+			handler := getTestHandler(dbMock)
+			request := httptest.NewRequest(http.MethodGet, "/ngaCAPJ", nil)
+			w := httptest.NewRecorder()
+			handler.FindByShortLink(w, request)
+
+			res := w.Result()
+			assert.Equal(t, http.StatusGone, res.StatusCode)
+			defer res.Body.Close()
+		})
+		t.Run("expired short link", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			dbMock := storage.NewMockStorage(ctrl)
+			expiresAt := time.Now().Add(-time.Minute)
+			mockedDBResult := api.ShortenedData{
+				OriginalURL: "praktikum.yandex.ru",
+				ExpiresAt:   &expiresAt,
+			}
+			dbMock.EXPECT().Get(gomock.Any(), gomock.Any()).Return(mockedDBResult, nil)
 
 			handler := getTestHandler(dbMock)
 			request := httptest.NewRequest(http.MethodGet, "/ngaCAPJ", nil)
@@ -296,7 +336,7 @@ func TestFailCreateShortLinkApi(t *testing.T) {
 		dbMock := storage.NewMockStorage(ctrl)
 
 		data := api.ShortenedData{}
-		dbMock.EXPECT().Store(gomock.Any()).Return(data, new(storage.ErrURLExists))
+		dbMock.EXPECT().Store(gomock.Any(), gomock.Any()).Return(data, new(storage.ErrURLExists))
 
 		handler := getTestHandler(dbMock)
 
@@ -320,7 +360,7 @@ func TestPingHandler(t *testing.T) {
 			defer ctrl.Finish()
 
 			dbMock := storage.NewMockStorage(ctrl)
-			dbMock.EXPECT().Ping().Return(errors.New("err"))
+			dbMock.EXPECT().Ping(gomock.Any()).Return(errors.New("err"))
 			handler := getTestHandler(dbMock)
 
 			request := httptest.NewRequest(http.MethodGet, "/ping", nil)
@@ -337,7 +377,7 @@ func TestPingHandler(t *testing.T) {
 			defer ctrl.Finish()
 
 			dbMock := storage.NewMockStorage(ctrl)
-			dbMock.EXPECT().Ping().Return(error(nil))
+			dbMock.EXPECT().Ping(gomock.Any()).Return(error(nil))
 			handler := getTestHandler(dbMock)
 
 			request := httptest.NewRequest(http.MethodGet, "/ping", nil)
@@ -461,7 +501,14 @@ func TestFindUserURLS(t *testing.T) {
 	t.Run("get user's urls", func(t *testing.T) {
 		t.Run("success", func(t *testing.T) {
 			h := getTestHandler(storage.NewInMemoryStorage())
+			shorten := httptest.NewRecorder()
+			h.Shorten(shorten, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://practicum.yandex.ru/")))
+			require.Equal(t, http.StatusCreated, shorten.Code)
+
 			request := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+			for _, c := range shorten.Result().Cookies() {
+				request.AddCookie(c)
+			}
 			// создаём новый Recorder
 			w := httptest.NewRecorder()
 			h.FindUserURLS(w, request)
@@ -478,7 +525,7 @@ func TestFindUserURLS(t *testing.T) {
 
 		dbMock := storage.NewMockStorage(ctrl)
 
-		dbMock.EXPECT().GetBatchByUserID(gomock.Any()).Return(nil, errors.New("error"))
+		dbMock.EXPECT().ListByUserID(gomock.Any(), gomock.Any(), gomock.Any()).Return(api.URLListPage{}, nil)
 
 		handler := getTestHandler(dbMock)
 		request := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
@@ -487,8 +534,61 @@ func TestFindUserURLS(t *testing.T) {
 
 		res := w.Result()
 		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+		assert.Equal(t, "0", res.Header.Get("X-Total-Count"))
 		defer res.Body.Close()
 	})
+
+	t.Run("pages of user's urls", func(t *testing.T) {
+		h := getTestHandler(storage.NewInMemoryStorage())
+		var cookies []*http.Cookie
+		for _, u := range []string{"https://a.example.com/", "https://b.example.com/", "https://other.org/"} {
+			request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(u))
+			for _, c := range cookies {
+				request.AddCookie(c)
+			}
+			w := httptest.NewRecorder()
+			h.Shorten(w, request)
+			require.Equal(t, http.StatusCreated, w.Code)
+			if cookies == nil {
+				cookies = w.Result().Cookies()
+			}
+		}
+
+		list := func(query string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(http.MethodGet, "/api/user/urls?"+query, nil)
+			for _, c := range cookies {
+				request.AddCookie(c)
+			}
+			w := httptest.NewRecorder()
+			h.FindUserURLS(w, request)
+			return w
+		}
+
+		first := list("limit=2&order=asc")
+		require.Equal(t, http.StatusOK, first.Code)
+		assert.Equal(t, "3", first.Header().Get("X-Total-Count"))
+		var items []api.UserURLV1
+		require.NoError(t, json.Unmarshal(first.Body.Bytes(), &items))
+		assert.Len(t, items, 2)
+		assert.NotEmpty(t, items[0].UUID)
+		assert.False(t, items[0].CreatedAt.IsZero())
+		assert.True(t, items[0].Active)
+
+		cursor := first.Header().Get("X-Next-Cursor")
+		require.NotEmpty(t, cursor)
+		second := list("limit=2&order=asc&cursor=" + cursor)
+		require.Equal(t, http.StatusOK, second.Code)
+		require.NoError(t, json.Unmarshal(second.Body.Bytes(), &items))
+		assert.Len(t, items, 1)
+		assert.Empty(t, second.Header().Get("X-Next-Cursor"))
+
+		filtered := list("domain=example.com")
+		assert.Equal(t, "2", filtered.Header().Get("X-Total-Count"))
+
+		assert.Equal(t, http.StatusBadRequest, list("limit=-1").Code)
+		assert.Equal(t, http.StatusBadRequest, list("sort=title").Code)
+		assert.Equal(t, http.StatusBadRequest, list("cursor="+cursor).Code)
+	})
 }
 
 func TestGetStats(t *testing.T) {
@@ -503,17 +603,30 @@ func TestGetStats(t *testing.T) {
 		defer res.Body.Close()
 	})
 
+	t.Run("forged real ip", func(t *testing.T) {
+		handler := getTestHandler(storage.NewInMemoryStorage())
+		request := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+		request.Header.Set("X-Real-IP", "127.0.0.1")
+		request.Header.Set("X-Forwarded-For", "127.0.0.1")
+		w := httptest.NewRecorder()
+		handler.GetStats(w, request)
+
+		res := w.Result()
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		defer res.Body.Close()
+	})
+
 	t.Run("fail get stats", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 
 		dbMock := storage.NewMockStorage(ctrl)
 
-		dbMock.EXPECT().GetStatistic().Return(nil).AnyTimes()
+		dbMock.EXPECT().GetStatistic(gomock.Any(), gomock.Any()).Return(nil, errors.New("error")).AnyTimes()
 
 		h := getTestHandler(dbMock)
 		request := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
-		request.Header.Set("X-Real-IP", "127.0.0.1")
+		request.RemoteAddr = "127.0.0.1:41234"
 		w := httptest.NewRecorder()
 		h.GetStats(w, request)
 
@@ -529,11 +642,11 @@ func TestGetStats(t *testing.T) {
 		dbMock := storage.NewMockStorage(ctrl)
 
 		stats := &api.Statistic{}
-		dbMock.EXPECT().GetStatistic().Return(stats).AnyTimes()
+		dbMock.EXPECT().GetStatistic(gomock.Any(), gomock.Any()).Return(stats, nil).AnyTimes()
 
 		handler := getTestHandler(dbMock)
 		request := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
-		request.Header.Set("X-Real-IP", "127.0.0.1")
+		request.RemoteAddr = "127.0.0.1:41234"
 		w := httptest.NewRecorder()
 		handler.GetStats(w, request)
 
@@ -541,4 +654,215 @@ func TestGetStats(t *testing.T) {
 		assert.Equal(t, http.StatusOK, res.StatusCode)
 		defer res.Body.Close()
 	})
+
+	t.Run("stats window", func(t *testing.T) {
+		h := getTestHandler(storage.NewInMemoryStorage())
+		shorten := httptest.NewRecorder()
+		h.Shorten(shorten, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://practicum.yandex.ru/")))
+		require.Equal(t, http.StatusCreated, shorten.Code)
+
+		stats := func(query string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(http.MethodGet, "/api/internal/stats?"+query, nil)
+			request.RemoteAddr = "127.0.0.1:41234"
+			w := httptest.NewRecorder()
+			h.GetStats(w, request)
+			return w
+		}
+
+		w := stats("from=2000-01-01&top=5")
+		require.Equal(t, http.StatusOK, w.Code)
+		var st api.Statistic
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &st))
+		assert.Equal(t, 1, st.URLs)
+		assert.Equal(t, 1, st.Active)
+		require.Len(t, st.TopDomains, 1)
+		assert.Equal(t, "practicum.yandex.ru", st.TopDomains[0].Domain)
+
+		require.NoError(t, json.Unmarshal(stats("to=2000-01-01T00:00:00Z").Body.Bytes(), &st))
+		assert.Equal(t, 0, st.URLs)
+
+		assert.Equal(t, http.StatusBadRequest, stats("from=yesterday").Code)
+		assert.Equal(t, http.StatusBadRequest, stats("from=2024-02-01&to=2024-01-01").Code)
+	})
+}
+
+func TestUpdateURL(t *testing.T) {
+	h := getTestHandler(storage.NewInMemoryStorage())
+	router := h.InitRoutes()
+
+	shorten := httptest.NewRecorder()
+	router.ServeHTTP(shorten, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://practicum.yandex.ru/")))
+	require.Equal(t, http.StatusCreated, shorten.Code)
+	short := strings.TrimPrefix(shorten.Body.String(), h.BaseURL+"/")
+
+	patch := func(body string, owner bool) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPatch, "/api/user/urls/"+short, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		if owner {
+			for _, c := range shorten.Result().Cookies() {
+				request.AddCookie(c)
+			}
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, patch(`{"title":"stolen"}`, false).Code)
+	assert.Equal(t, http.StatusBadRequest, patch(`{"original_url":"javascript:alert(1)"}`, true).Code)
+
+	w := patch(`{"original_url":"https://Sports.ru:443/","title":"Sport","tags":["news"],"active":false}`, true)
+	require.Equal(t, http.StatusOK, w.Code)
+	var updated api.UserURLV1
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&updated))
+	assert.Equal(t, "https://sports.ru/", updated.OriginalURL)
+	assert.Equal(t, []string{"news"}, updated.Tags)
+	assert.False(t, updated.Active)
+
+	redirect := httptest.NewRecorder()
+	router.ServeHTTP(redirect, httptest.NewRequest(http.MethodGet, "/"+short, nil))
+	assert.Equal(t, http.StatusNotFound, redirect.Code)
+}
+
+func TestShortDomains(t *testing.T) {
+	h := getTestHandler(storage.NewInMemoryStorage())
+	var err error
+	h.Domains, err = domains.New(h.BaseURL, []string{"https://go.example"}, "")
+	require.NoError(t, err)
+	router := h.InitRoutes()
+
+	shorten := httptest.NewRecorder()
+	router.ServeHTTP(shorten, httptest.NewRequest(http.MethodPost, "/?domain=go.example", strings.NewReader("https://practicum.yandex.ru/")))
+	require.Equal(t, http.StatusCreated, shorten.Code)
+	short := strings.TrimPrefix(shorten.Body.String(), "https://go.example/")
+	require.NotEqual(t, shorten.Body.String(), short)
+
+	redirect := func(host string) int {
+		request := httptest.NewRequest(http.MethodGet, "/"+short, nil)
+		request.Host = host
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusTemporaryRedirect, redirect("GO.example:443"))
+	assert.Equal(t, http.StatusBadRequest, redirect("localhost:8080"))
+
+	// the same destination gets own link on primary domain
+	request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://practicum.yandex.ru/"}`))
+	request.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), h.BaseURL+"/")
+
+	request = httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://sports.ru/","domain":"unknown.example"}`))
+	request.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRedirectTypes(t *testing.T) {
+	h := getTestHandler(storage.NewInMemoryStorage())
+	router := h.InitRoutes()
+
+	shorten := func(body string) (string, int) {
+		request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		var resp api.ShortenResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return strings.TrimPrefix(resp.Result, h.BaseURL), w.Code
+	}
+	open := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	path, code := shorten(`{"url":"https://a.example/"}`)
+	require.Equal(t, http.StatusCreated, code)
+	w := open(path + "?ref=x")
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "https://a.example/", w.Header().Get("Location"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	path, _ = shorten(`{"url":"https://b.example/p?utm_source=own#top","redirect_type":"301","pass_query":true,"utm":{"source":"news","campaign":"spring"}}`)
+	w = open(path + "?ref=x&utm_campaign=mail")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "https://b.example/p?utm_source=own&ref=x&utm_campaign=mail#top", w.Header().Get("Location"))
+	assert.Equal(t, "max-age=3600", w.Header().Get("Cache-Control"))
+
+	path, _ = shorten(`{"url":"https://c.example/?q=1&x=<b>","redirect_type":"page","utm":{"medium":"email"}}`)
+	w = open(path)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), `href="https://c.example/?q=1&amp;x=%3cb%3e&amp;utm_medium=email"`)
+	assert.NotContains(t, w.Body.String(), "<b>")
+
+	_, code = shorten(`{"url":"https://d.example/","redirect_type":"303"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/?redirect_type=308&utm_source=plain", strings.NewReader("https://e.example/")))
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = open(strings.TrimPrefix(w.Body.String(), h.BaseURL))
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	assert.Equal(t, "https://e.example/?utm_source=plain", w.Header().Get("Location"))
+	assert.Equal(t, "max-age=3600", w.Header().Get("Cache-Control"))
+}
+
+func TestAdminRoutes(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	_, err := store.Store(context.Background(), api.ShortenedData{UserID: "u1", ShortURL: "abc", OriginalURL: "https://example.com/"})
+	require.NoError(t, err)
+
+	cfg := config.Default()
+	cfg.AdminToken = "0123456789abcdef"
+	cfg.TrustedSubnet = "127.0.0.0/8"
+	trail, err := admin.NewTrail("", *logger.NewLogger())
+	require.NoError(t, err)
+	h := getTestHandler(store)
+	h.Admin = admin.New(store, trail, cfg)
+	router := h.InitRoutes()
+
+	call := func(method, path, remote, token, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.RemoteAddr = remote
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, call(http.MethodGet, "/api/admin/links/abc", "203.0.113.1:1000", cfg.AdminToken, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodGet, "/api/admin/links/abc", "127.0.0.1:1000", "wrong", "").Code)
+
+	w := call(http.MethodPatch, "/api/admin/links/abc", "127.0.0.1:1000", cfg.AdminToken, `{"active":false,"user_id":"u2"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var link api.ShortenedData
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+	assert.Equal(t, "u2", link.UserID)
+	assert.True(t, link.Disabled)
+
+	w = call(http.MethodGet, "/api/admin/users/u2/links", "127.0.0.1:1000", cfg.AdminToken, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-Total-Count"))
+
+	w = call(http.MethodDelete, "/api/admin/domains/example.com/links", "127.0.0.1:1000", cfg.AdminToken, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"deleted":1}`, w.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, call(http.MethodGet, "/api/admin/links/missing", "127.0.0.1:1000", cfg.AdminToken, "").Code)
+
+	w = call(http.MethodGet, "/api/admin/audit?limit=2", "127.0.0.1:1000", cfg.AdminToken, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var entries []api.AuditEntry
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	require.Len(t, entries, 2)
+	assert.Equal(t, "127.0.0.1", entries[0].Actor)
+	assert.Equal(t, admin.ActionGetLink, entries[0].Action)
 }
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+)
+
+// redirectPage opens destination of api.RedirectPage links, the link is shown
+// for clients ignoring meta refresh
+var redirectPage = template.Must(template.New("redirect").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="0; url={{.}}">
+<title>Redirect</title>
+</head>
+<body>
+<p>Redirecting to <a href="{{.}}">{{.}}</a></p>
+</body>
+</html>
+`))
+
+// writeRedirect sends client to destination of link in the way chosen by its redirect type.
+// Query of short url is passed to destination when link allows it. Permanent redirects
+// are cached for limited time, so changes of link reach clients eventually
+func writeRedirect(w http.ResponseWriter, r *http.Request, data api.ShortenedData) {
+	target := data.Target(r.URL.Query())
+	w.Header().Set("Cache-Control", api.RedirectCacheControl(data.RedirectType))
+	if data.RedirectType == api.RedirectPage {
+		w.Header().Set("content-type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		redirectPage.Execute(w, target)
+		return
+	}
+	w.Header().Set("content-type", "text/plain")
+	w.Header().Set("Location", target)
+	w.WriteHeader(api.RedirectStatus(data.RedirectType))
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+	"github.com/gsk148/urlShorteningService/internal/app/auth"
+	"github.com/gsk148/urlShorteningService/internal/app/logger"
+	"github.com/gsk148/urlShorteningService/internal/app/transfer"
+)
+
+// maxImportSize limits body of import requests
+const maxImportSize = 32 << 20
+
+// ExportURLs streams links of user in format of "format" query parameter, jsonl by default
+func (h *Handler) ExportURLs(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserToken(w, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	format, ok := exportFormat(w, r)
+	if !ok {
+		return
+	}
+	if _, err = transfer.Export(r.Context(), h.Store, w, format, userID); err != nil {
+		// headers are already sent, client sees truncated body
+		logger.WithTrace(r.Context(), &h.Logger).Errorw("export failed", "error", err)
+	}
+}
+
+// ImportURLs stores links of request body for user and reports result of every line
+func (h *Handler) ImportURLs(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserToken(w, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	format := importFormat(r)
+	if err = transfer.CheckFormat(format); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := api.ImportResponse{Results: []api.ImportResult{}}
+	resp.Summary, err = transfer.Import(r.Context(), h.Store, http.MaxBytesReader(w, r.Body, maxImportSize), format,
+		h.importOptions(userID), func(res api.ImportResult) {
+			resp.Results = append(resp.Results, res)
+		})
+	h.writeImportResponse(w, r, resp, err)
+}
+
+// AdminExport streams all links or links of user_id query parameter
+func (h *Handler) AdminExport(w http.ResponseWriter, r *http.Request) {
+	format, ok := exportFormat(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.Admin.Export(r.Context(), adminActor(r), w, format, r.URL.Query().Get("user_id")); err != nil {
+		logger.WithTrace(r.Context(), &h.Logger).Errorw("admin export failed", "error", err)
+	}
+}
+
+// AdminImport stores links of request body keeping their owners
+func (h *Handler) AdminImport(w http.ResponseWriter, r *http.Request) {
+	format := importFormat(r)
+	if err := transfer.CheckFormat(format); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := api.ImportResponse{Results: []api.ImportResult{}}
+	var err error
+	resp.Summary, err = h.Admin.Import(r.Context(), adminActor(r), http.MaxBytesReader(w, r.Body, maxImportSize), format,
+		h.importOptions(""), func(res api.ImportResult) {
+			resp.Results = append(resp.Results, res)
+		})
+	h.writeImportResponse(w, r, resp, err)
+}
+
+func (h *Handler) importOptions(owner string) transfer.ImportOptions {
+	return transfer.ImportOptions{Owner: owner, URLOptions: h.URLOptions, Policy: h.Policy, Domains: h.shortDomains()}
+}
+
+// writeImportResponse reports results, input that could not be read to the end is bad request
+func (h *Handler) writeImportResponse(w http.ResponseWriter, r *http.Request, resp api.ImportResponse, err error) {
+	status := http.StatusOK
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			status = http.StatusRequestEntityTooLarge
+		case r.Context().Err() != nil:
+			logger.WithTrace(r.Context(), &h.Logger).Warnw("import interrupted", "error", err)
+			return
+		default:
+			status = http.StatusBadRequest
+		}
+		resp.Error = err.Error()
+	}
+	h.writeJSON(w, status, resp)
+}
+
+// exportFormat validates format query parameter and sets response headers for it
+func exportFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = transfer.FormatJSONL
+	}
+	if err := transfer.CheckFormat(format); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	w.Header().Set("Content-Type", transfer.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="links.%s"`, format))
+	return format, true
+}
+
+// importFormat takes format query parameter, CSV body may be recognized by content type
+func importFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		return transfer.FormatCSV
+	}
+	return transfer.FormatJSONL
+}
@@ -11,3 +11,11 @@ func Encode(data []byte) string {
 	base64Hash := base64.RawURLEncoding.EncodeToString(hash[:])
 	return base64Hash[:7]
 }
+
+// EncodeAttempt return Encode of data for first attempt and other short url for next ones
+func EncodeAttempt(data []byte, attempt int) string {
+	if attempt == 0 {
+		return Encode(data)
+	}
+	return Encode(append(append([]byte(nil), data...), byte(attempt)))
+}
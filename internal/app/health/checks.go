@@ -0,0 +1,49 @@
+package health
+
+import (
+	"context"
+
+	"github.com/gsk148/urlShorteningService/internal/app/deletion"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+)
+
+// StorageCheck pings storage and reports schema migration state when storage has one.
+// For file storage ping verifies the file is writable.
+func StorageCheck(store storage.Storage, storageType string) Check {
+	return func(ctx context.Context) Component {
+		c := Component{Status: StatusUp, Details: map[string]any{"type": storageType}}
+		if err := store.Ping(ctx); err != nil {
+			c.Status = StatusDown
+			c.Error = err.Error()
+			return c
+		}
+
+		status, ok, err := storage.Migrations(ctx, store)
+		switch {
+		case !ok:
+		case err != nil:
+			c.Status = StatusDown
+			c.Error = "migration state: " + err.Error()
+		default:
+			c.Details["migration"] = status
+			if status.Pending() {
+				c.Status = StatusDown
+				c.Error = "migrations pending"
+			}
+		}
+		return c
+	}
+}
+
+// DeletionCheck reports deletion queue backlog, full queue means deletes block requests
+func DeletionCheck(q *deletion.Queue) Check {
+	return func(context.Context) Component {
+		backlog, capacity := q.Len(), q.Cap()
+		c := Component{Status: StatusUp, Details: map[string]any{"backlog": backlog, "capacity": capacity}}
+		if backlog >= capacity {
+			c.Status = StatusDown
+			c.Error = "deletion queue is full"
+		}
+		return c
+	}
+}
@@ -0,0 +1,180 @@
+// Package health contains liveness and readiness checks for REST and gRPC
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Component statuses
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Defaults for readiness checks
+const (
+	// DefaultTimeout limits time spent by all readiness checks
+	DefaultTimeout = 2 * time.Second
+	// DefaultInterval is period of gRPC health status refresh
+	DefaultInterval = 5 * time.Second
+)
+
+// Component is result of single check
+type Component struct {
+	Status  string         `json:"status"`
+	Error   string         `json:"error,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// Report is result of liveness or readiness probe
+type Report struct {
+	Status     string               `json:"status"`
+	Components map[string]Component `json:"components,omitempty"`
+}
+
+// Check reports state of one component
+type Check func(ctx context.Context) Component
+
+// Checker runs registered checks and keeps gRPC health service in sync
+type Checker struct {
+	timeout  time.Duration
+	names    []string
+	checks   map[string]Check
+	services []string
+	serving  atomic.Bool
+	grpc     *health.Server
+	mu       sync.Mutex
+}
+
+// NewChecker return Checker object, services are gRPC service names reported by health service
+func NewChecker(timeout time.Duration, services ...string) *Checker {
+	c := &Checker{
+		timeout:  timeout,
+		checks:   map[string]Check{},
+		services: append([]string{""}, services...),
+		grpc:     health.NewServer(),
+	}
+	c.setGRPC(healthpb.HealthCheckResponse_NOT_SERVING)
+	return c
+}
+
+// Add registers readiness check under name
+func (c *Checker) Add(name string, check Check) {
+	c.names = append(c.names, name)
+	c.checks[name] = check
+}
+
+// GRPC returns grpc.health.v1 service implementation
+func (c *Checker) GRPC() healthpb.HealthServer {
+	return c.grpc
+}
+
+// SetServing marks whether servers accept traffic, false fails readiness at once
+func (c *Checker) SetServing(serving bool) {
+	c.serving.Store(serving)
+	c.Refresh(context.Background())
+}
+
+// Live reports that process is able to serve requests at all.
+// Dependencies are not checked so their outage does not restart the service.
+func (c *Checker) Live(context.Context) Report {
+	return Report{Status: StatusUp}
+}
+
+// Ready runs all checks concurrently and reports whether service may receive traffic
+func (c *Checker) Ready(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	report := Report{Status: StatusUp, Components: make(map[string]Component, len(c.names)+1)}
+	server := Component{Status: StatusUp}
+	if !c.serving.Load() {
+		server = Component{Status: StatusDown, Error: "not serving"}
+	}
+	report.Components["server"] = server
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range c.names {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			res := check(ctx)
+			mu.Lock()
+			report.Components[name] = res
+			mu.Unlock()
+		}(name, c.checks[name])
+	}
+	wg.Wait()
+
+	for _, comp := range report.Components {
+		if comp.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// Refresh runs readiness checks and publishes result to gRPC health service
+func (c *Checker) Refresh(ctx context.Context) Report {
+	report := c.Ready(ctx)
+	if report.Status == StatusUp {
+		c.setGRPC(healthpb.HealthCheckResponse_SERVING)
+	} else {
+		c.setGRPC(healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	return report
+}
+
+// Run refreshes gRPC health status every interval until ctx is done
+func (c *Checker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Refresh(ctx)
+		}
+	}
+}
+
+func (c *Checker) setGRPC(status healthpb.HealthCheckResponse_ServingStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.services {
+		c.grpc.SetServingStatus(s, status)
+	}
+}
+
+// LiveHandler serves liveness report
+func (c *Checker) LiveHandler(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, c.Live(r.Context()))
+}
+
+// ReadyHandler serves readiness report, 503 when any component is down
+func (c *Checker) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, c.Refresh(r.Context()))
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status == StatusUp {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(report)
+}
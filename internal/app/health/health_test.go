@@ -0,0 +1,53 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+)
+
+func grpcStatus(t *testing.T, c *Checker) healthpb.HealthCheckResponse_ServingStatus {
+	resp, err := c.GRPC().Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	return resp.Status
+}
+
+func TestReadiness(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := storage.NewMockStorage(ctrl)
+	c := NewChecker(DefaultTimeout)
+	c.Add("storage", StorageCheck(store, "memory"))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, grpcStatus(t, c))
+
+	store.EXPECT().Ping(gomock.Any()).Return(nil)
+	c.SetServing(true)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, grpcStatus(t, c))
+
+	store.EXPECT().Ping(gomock.Any()).Return(errors.New("connection refused"))
+	rec := httptest.NewRecorder()
+	c.ReadyHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, grpcStatus(t, c))
+
+	var report Report
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, StatusUp, report.Components["server"].Status)
+	assert.Equal(t, "connection refused", report.Components["storage"].Error)
+
+	rec = httptest.NewRecorder()
+	c.LiveHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
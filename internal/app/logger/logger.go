@@ -1,17 +1,27 @@
 package logger
 
 import (
+	"context"
 	"net/http"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/gsk148/urlShorteningService/internal/app/clientip"
 )
 
-var sugar zap.SugaredLogger
+var (
+	sugar zap.SugaredLogger
+	level = zap.NewAtomicLevelAt(zap.DebugLevel)
+)
 
 // NewLogger return logger object
 func NewLogger() *zap.SugaredLogger {
-	logger, err := zap.NewDevelopment()
+	cfg := zap.NewDevelopmentConfig()
+	cfg.Level = level
+	logger, err := cfg.Build()
 	if err != nil {
 		panic(err)
 	}
@@ -21,6 +31,25 @@ func NewLogger() *zap.SugaredLogger {
 	return &sugar
 }
 
+// WithTrace returns logger annotated with trace_id and span_id of the span stored in ctx
+func WithTrace(ctx context.Context, l *zap.SugaredLogger) *zap.SugaredLogger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return l
+	}
+	return l.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+}
+
+// SetLevel changes level of every logger created by NewLogger
+func SetLevel(l string) error {
+	parsed, err := zapcore.ParseLevel(l)
+	if err != nil {
+		return err
+	}
+	level.SetLevel(parsed)
+	return nil
+}
+
 type (
 	responseData struct {
 		status int
@@ -64,8 +93,9 @@ func WithLogging(h http.Handler) http.Handler {
 
 		duration := time.Since(start)
 
-		sugar.Infoln("uri", r.RequestURI,
+		WithTrace(r.Context(), &sugar).Infoln("uri", r.RequestURI,
 			"method", r.Method,
+			"ip", clientip.FromRequest(r),
 			"status", responseData.status,
 			"duration", duration,
 			"size", responseData.size)
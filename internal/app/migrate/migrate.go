@@ -0,0 +1,199 @@
+// Package migrate copies links between storage backends keeping UUIDs, owners
+// and deletion flags, so storage type can be switched without losing data
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+)
+
+// ErrNotEmpty returned by Copy when destination has links and resume is not requested
+var ErrNotEmpty = errors.New("destination storage is not empty, use resume to continue interrupted copy")
+
+// errStop ends walk of destination after the first link
+var errStop = errors.New("stop")
+
+// Options configures Copy
+type Options struct {
+	// DryRun reads both storages and reports what would be copied without writing
+	DryRun bool
+	// Resume skips links already copied, links that differ are reported as conflicts
+	Resume bool
+	// Verify only compares every source link with destination
+	Verify bool
+	// Progress is called after every ProgressEvery links
+	Progress func(Stats)
+}
+
+// ProgressEvery is number of links between Progress calls
+const ProgressEvery = 1000
+
+// Stats counts links processed by Copy
+type Stats struct {
+	Read      int `json:"read"`
+	Copied    int `json:"copied"`
+	Skipped   int `json:"skipped"`
+	Conflicts int `json:"conflicts"`
+	Verified  int `json:"verified"`
+	Missing   int `json:"missing"`
+}
+
+// Problem describes link that was not copied or differs in destination
+type Problem struct {
+	ShortURL string `json:"short_url"`
+	Reason   string `json:"reason"`
+}
+
+// Copy streams every link of from to to. Failures of single links are passed to
+// report and counted, error is returned only when storages cannot be read or written
+func Copy(ctx context.Context, from, to storage.Storage, opts Options, report func(Problem)) (Stats, error) {
+	var stats Stats
+	if report == nil {
+		report = func(Problem) {}
+	}
+	if !opts.Resume && !opts.Verify {
+		err := to.Walk(ctx, "", func(api.ShortenedData) error { return errStop })
+		if errors.Is(err, errStop) {
+			return stats, ErrNotEmpty
+		}
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	err := from.Walk(ctx, "", func(data api.ShortenedData) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		stats.Read++
+		var err error
+		if opts.Verify {
+			err = verifyLink(ctx, to, data, &stats, report)
+		} else {
+			err = copyLink(ctx, to, data, opts.DryRun, &stats, report)
+		}
+		if opts.Progress != nil && stats.Read%ProgressEvery == 0 {
+			opts.Progress(stats)
+		}
+		return err
+	})
+	return stats, err
+}
+
+func copyLink(ctx context.Context, to storage.Storage, data api.ShortenedData, dryRun bool, stats *Stats, report func(Problem)) error {
+	existing, err := to.Get(ctx, data.Key())
+	switch {
+	case err == nil:
+		if diff := compareLinks(data, existing); diff != "" {
+			stats.Conflicts++
+			report(Problem{ShortURL: data.Key(), Reason: "differs in destination: " + diff})
+			return nil
+		}
+		stats.Skipped++
+		return nil
+	case !errors.Is(err, storage.ErrNotFound):
+		return fmt.Errorf("get %s: %w", data.Key(), err)
+	}
+
+	if dryRun {
+		stats.Copied++
+		return nil
+	}
+	stored, err := to.Store(ctx, data)
+	switch {
+	case errors.Is(err, &storage.ErrURLExists{}):
+		stats.Conflicts++
+		report(Problem{ShortURL: data.Key(), Reason: "original url is stored as " + stored.Key()})
+	case errors.Is(err, storage.ErrShortURLTaken):
+		stats.Conflicts++
+		report(Problem{ShortURL: data.Key(), Reason: "short url is taken"})
+	case err != nil:
+		return fmt.Errorf("store %s: %w", data.Key(), err)
+	default:
+		stats.Copied++
+	}
+	return nil
+}
+
+func verifyLink(ctx context.Context, to storage.Storage, data api.ShortenedData, stats *Stats, report func(Problem)) error {
+	existing, err := to.Get(ctx, data.Key())
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		stats.Missing++
+		report(Problem{ShortURL: data.Key(), Reason: "missing in destination"})
+	case err != nil:
+		return fmt.Errorf("get %s: %w", data.Key(), err)
+	default:
+		if diff := compareLinks(data, existing); diff != "" {
+			stats.Conflicts++
+			report(Problem{ShortURL: data.Key(), Reason: "differs in destination: " + diff})
+			return nil
+		}
+		stats.Verified++
+	}
+	return nil
+}
+
+// compareLinks returns name of the first field that differs or empty string.
+// Times are compared with database precision, links without creation time
+// get it from destination and are not compared by it
+func compareLinks(src, dst api.ShortenedData) string {
+	switch {
+	case src.UUID != dst.UUID:
+		return "uuid"
+	case src.UserID != dst.UserID:
+		return "user_id"
+	case src.OriginalURL != dst.OriginalURL:
+		return "original_url"
+	case src.IsDeleted != dst.IsDeleted:
+		return "is_deleted"
+	case src.Disabled != dst.Disabled:
+		return "disabled"
+	case src.Title != dst.Title:
+		return "title"
+	case src.Description != dst.Description:
+		return "description"
+	case !sameTags(src.Tags, dst.Tags):
+		return "tags"
+	case src.Clicks != dst.Clicks:
+		return "clicks"
+	case src.RedirectType != dst.RedirectType:
+		return "redirect_type"
+	case src.PassQuery != dst.PassQuery:
+		return "pass_query"
+	case src.UTM.Values().Encode() != dst.UTM.Values().Encode():
+		return "utm"
+	case !src.CreatedAt.IsZero() && !sameTime(src.CreatedAt, dst.CreatedAt):
+		return "created_at"
+	case hasTime(src.ExpiresAt) != hasTime(dst.ExpiresAt),
+		hasTime(src.ExpiresAt) && !sameTime(*src.ExpiresAt, *dst.ExpiresAt):
+		return "expires_at"
+	}
+	return ""
+}
+
+// hasTime reports whether optional time is set, zero time is stored as NULL
+func hasTime(t *time.Time) bool {
+	return t != nil && !t.IsZero()
+}
+
+func sameTime(a, b time.Time) bool {
+	return a.Truncate(time.Microsecond).Equal(b.Truncate(time.Microsecond))
+}
+
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+)
+
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	src := storage.NewInMemoryStorage()
+	links := []api.ShortenedData{
+		{UUID: "1", UserID: "u1", ShortURL: "a", OriginalURL: "https://a.example/", CreatedAt: created, Tags: []string{"x"}},
+		{UUID: "2", UserID: "u1", ShortURL: "b", OriginalURL: "https://b.example/", CreatedAt: created.Add(time.Hour), IsDeleted: true},
+		{UUID: "3", UserID: "u2", ShortURL: "c", OriginalURL: "https://c.example/", CreatedAt: created.Add(2 * time.Hour), Clicks: 5},
+	}
+	for _, link := range links {
+		_, err := src.Store(ctx, link)
+		require.NoError(t, err)
+	}
+
+	dst := storage.NewInMemoryStorage()
+	stats, err := Copy(ctx, src, dst, Options{DryRun: true}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Read: 3, Copied: 3}, stats)
+	_, err = dst.Get(ctx, "a")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// interrupted copy leaves part of links in destination
+	_, err = dst.Store(ctx, links[0])
+	require.NoError(t, err)
+	_, err = Copy(ctx, src, dst, Options{}, nil)
+	assert.ErrorIs(t, err, ErrNotEmpty)
+
+	stats, err = Copy(ctx, src, dst, Options{Resume: true}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Read: 3, Copied: 2, Skipped: 1}, stats)
+	for _, link := range links {
+		got, err := dst.Get(ctx, link.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, link, got)
+	}
+
+	stats, err = Copy(ctx, src, dst, Options{Verify: true}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Read: 3, Verified: 3}, stats)
+
+	other := storage.NewInMemoryStorage()
+	changed := links[1]
+	changed.IsDeleted = false
+	_, err = other.Store(ctx, changed)
+	require.NoError(t, err)
+	var problems []Problem
+	stats, err = Copy(ctx, src, other, Options{Verify: true}, func(p Problem) {
+		problems = append(problems, p)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Read: 3, Conflicts: 1, Missing: 2}, stats)
+	assert.Contains(t, problems, Problem{ShortURL: "b", Reason: "differs in destination: is_deleted"})
+}
@@ -0,0 +1,243 @@
+package policy
+
+import (
+	"bufio"
+	"context"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/idna"
+
+	"github.com/gsk148/urlShorteningService/internal/app/urlnorm"
+)
+
+// fileList holds lines of text file and rereads it when modification time changes.
+// Empty lines and lines starting with # are skipped.
+type fileList struct {
+	path  string
+	parse func(line string) (string, error)
+	// index builds lookup structures of entries, it is called with lock held
+	index func(entries map[string]bool)
+
+	mu      sync.RWMutex
+	entries map[string]bool
+	modTime time.Time
+}
+
+func newFileList(path string, parse func(string) (string, error), index func(map[string]bool)) (*fileList, error) {
+	l := &fileList{path: path, parse: parse, index: index}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *fileList) load() error {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entries := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry, err := l.parse(line)
+		if err != nil {
+			return err
+		}
+		entries[entry] = true
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = entries
+	l.modTime = info.ModTime()
+	if l.index != nil {
+		l.index(entries)
+	}
+	return nil
+}
+
+func (l *fileList) refresh() error {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return err
+	}
+	l.mu.RLock()
+	changed := !info.ModTime().Equal(l.modTime)
+	l.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+	return l.load()
+}
+
+func (l *fileList) has(entry string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.entries[entry]
+}
+
+func (l *fileList) empty() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.entries) == 0
+}
+
+// hasDomain reports whether host or any of its parent domains is listed
+func (l *fileList) hasDomain(host string) bool {
+	for {
+		if l.has(host) {
+			return true
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			return false
+		}
+		host = host[i+1:]
+	}
+}
+
+func parseDomain(line string) (string, error) {
+	line = strings.TrimPrefix(strings.TrimSuffix(line, "."), "*.")
+	return idna.Lookup.ToASCII(line)
+}
+
+func hostOf(u *url.URL) string {
+	host, err := idna.Lookup.ToASCII(strings.TrimSuffix(u.Hostname(), "."))
+	if err != nil {
+		return strings.ToLower(u.Hostname())
+	}
+	return host
+}
+
+// Blocklist rejects listed domains and their subdomains
+type Blocklist struct {
+	*fileList
+}
+
+// NewBlocklist return Blocklist object loaded from file with one domain per line
+func NewBlocklist(path string) (*Blocklist, error) {
+	l, err := newFileList(path, parseDomain, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Blocklist{l}, nil
+}
+
+// Name returns rule name
+func (b *Blocklist) Name() string {
+	return "blocklist"
+}
+
+// Check rejects url when its host is listed
+func (b *Blocklist) Check(_ context.Context, u *url.URL) error {
+	if host := hostOf(u); b.hasDomain(host) {
+		return &BlockedError{Rule: b.Name(), Reason: "domain " + host + " is blocked"}
+	}
+	return nil
+}
+
+// Allowlist rejects every domain which is not listed, empty list allows everything
+type Allowlist struct {
+	*fileList
+}
+
+// NewAllowlist return Allowlist object loaded from file with one domain per line
+func NewAllowlist(path string) (*Allowlist, error) {
+	l, err := newFileList(path, parseDomain, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Allowlist{l}, nil
+}
+
+// Name returns rule name
+func (a *Allowlist) Name() string {
+	return "allowlist"
+}
+
+// Check rejects url when its host is not listed
+func (a *Allowlist) Check(_ context.Context, u *url.URL) error {
+	if a.empty() {
+		return nil
+	}
+	if host := hostOf(u); !a.hasDomain(host) {
+		return &BlockedError{Rule: a.Name(), Reason: "domain " + host + " is not allowed"}
+	}
+	return nil
+}
+
+// ReputationFile is local stand-in for external reputation service.
+// File lists known malicious urls, url is rejected when it has host of listed one
+// and its path is listed path or below it. Query of listed url must match exactly,
+// scheme is ignored.
+type ReputationFile struct {
+	*fileList
+	// hosts maps lower case host with port to listed urls, guarded by fileList lock
+	hosts map[string][]*url.URL
+}
+
+// NewReputationFile return ReputationFile object loaded from file with one url per line
+func NewReputationFile(path string) (*ReputationFile, error) {
+	r := &ReputationFile{}
+	l, err := newFileList(path, urlnorm.Normalize, r.index)
+	if err != nil {
+		return nil, err
+	}
+	r.fileList = l
+	return r, nil
+}
+
+// index parses normalized entries once per load
+func (r *ReputationFile) index(entries map[string]bool) {
+	hosts := make(map[string][]*url.URL, len(entries))
+	for entry := range entries {
+		if listed, err := url.Parse(entry); err == nil {
+			host := strings.ToLower(listed.Host)
+			hosts[host] = append(hosts[host], listed)
+		}
+	}
+	r.hosts = hosts
+}
+
+// Name returns rule name
+func (r *ReputationFile) Name() string {
+	return "reputation"
+}
+
+// Check rejects url matching listed malicious url
+func (r *ReputationFile) Check(_ context.Context, u *url.URL) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, listed := range r.hosts[strings.ToLower(u.Host)] {
+		if matchesURL(u, listed) {
+			return &BlockedError{Rule: r.Name(), Reason: "url is reported as malicious"}
+		}
+	}
+	return nil
+}
+
+// matchesURL reports whether u of listed host is listed url or lies below its path
+func matchesURL(u, listed *url.URL) bool {
+	if listed.RawQuery != "" && u.RawQuery != listed.RawQuery {
+		return false
+	}
+	dir := strings.TrimSuffix(listed.Path, "/")
+	return dir == "" || u.Path == listed.Path || u.Path == dir || strings.HasPrefix(u.Path, dir+"/")
+}
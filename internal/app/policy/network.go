@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"time"
+)
+
+// resolveTimeout limits host name lookup of single check
+const resolveTimeout = 2 * time.Second
+
+// Resolver looks up addresses of host, *net.Resolver satisfies it
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// PrivateAddressRule rejects destinations in loopback, private and link-local networks.
+// Host names are resolved when link is created or updated, so names pointing to
+// internal addresses are rejected too. Names which cannot be resolved are allowed.
+// Redirects only check literal addresses and never wait for DNS.
+type PrivateAddressRule struct {
+	resolver Resolver
+}
+
+// NewPrivateAddressRule return PrivateAddressRule object, nil resolver uses net.DefaultResolver
+func NewPrivateAddressRule(resolver Resolver) *PrivateAddressRule {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &PrivateAddressRule{resolver: resolver}
+}
+
+// Name returns rule name
+func (r *PrivateAddressRule) Name() string {
+	return "private_address"
+}
+
+// Check rejects url whose host is or resolves to internal address
+func (r *PrivateAddressRule) Check(ctx context.Context, u *url.URL) error {
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		return r.checkIP(host, ip)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	addrs, err := r.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, a := range addrs {
+		if err := r.checkIP(host, a.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Recheck rejects url whose host is internal address, host names are not resolved
+func (r *PrivateAddressRule) Recheck(ctx context.Context, u *url.URL) error {
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		return r.checkIP(host, ip)
+	}
+	return nil
+}
+
+func (r *PrivateAddressRule) checkIP(host string, ip net.IP) error {
+	if isInternal(ip) {
+		return &BlockedError{Rule: r.Name(), Reason: host + " points to internal address " + ip.String()}
+	}
+	return nil
+}
+
+func isInternal(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		carrierGradeNAT.Contains(ip)
+}
@@ -0,0 +1,154 @@
+// Package policy decides whether destination url may be shortened and redirected to
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/gsk148/urlShorteningService/internal/app/config"
+)
+
+// BlockedError returned when destination is rejected by policy
+type BlockedError struct {
+	Rule   string
+	Reason string
+}
+
+// Error returns string message
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("destination blocked by %s: %s", e.Rule, e.Reason)
+}
+
+// IsBlocked reports whether err is a policy rejection rather than a checker failure
+func IsBlocked(err error) bool {
+	var blocked *BlockedError
+	return errors.As(err, &blocked)
+}
+
+// Rule checks single aspect of destination. It returns *BlockedError to reject
+// destination and any other error when it was unable to decide.
+// External reputation services plug in by implementing Rule.
+type Rule interface {
+	Name() string
+	Check(ctx context.Context, u *url.URL) error
+}
+
+// Rechecker is implemented by rules which check stored destination differently when
+// link is opened, e.g. without network lookups made when link was created or updated
+type Rechecker interface {
+	Recheck(ctx context.Context, u *url.URL) error
+}
+
+// refresher is implemented by rules backed by files
+type refresher interface {
+	refresh() error
+}
+
+// Policy applies rules in order, first rejection wins
+type Policy struct {
+	rules []Rule
+	log   zap.SugaredLogger
+}
+
+// New return Policy object
+func New(log zap.SugaredLogger, rules ...Rule) *Policy {
+	return &Policy{rules: rules, log: log}
+}
+
+// FromConfig builds policy of configured lists and private address blocking
+func FromConfig(cfg *config.Config, log zap.SugaredLogger) (*Policy, error) {
+	var rules []Rule
+	if cfg.AllowlistFile != "" {
+		l, err := NewAllowlist(cfg.AllowlistFile)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, l)
+	}
+	if cfg.BlocklistFile != "" {
+		l, err := NewBlocklist(cfg.BlocklistFile)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, l)
+	}
+	if cfg.BlockPrivateIPs {
+		rules = append(rules, NewPrivateAddressRule(nil))
+	}
+	if cfg.ReputationFile != "" {
+		l, err := NewReputationFile(cfg.ReputationFile)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, l)
+	}
+	return New(log, rules...), nil
+}
+
+// Check parses rawURL and runs every rule, nil policy allows everything
+func (p *Policy) Check(ctx context.Context, rawURL string) error {
+	if p == nil {
+		return nil
+	}
+	return p.check(ctx, rawURL, func(r Rule, u *url.URL) error {
+		return r.Check(ctx, u)
+	})
+}
+
+func (p *Policy) check(ctx context.Context, rawURL string, check func(r Rule, u *url.URL) error) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return &BlockedError{Rule: "url", Reason: err.Error()}
+	}
+	for _, r := range p.rules {
+		if err := check(r, u); err != nil {
+			if !IsBlocked(err) {
+				return fmt.Errorf("policy %s: %w", r.Name(), err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Recheck runs rules against destination of stored link on redirect. Rules
+// implementing Rechecker use Recheck, so redirects do not wait for lookups
+func (p *Policy) Recheck(ctx context.Context, rawURL string) error {
+	if p == nil {
+		return nil
+	}
+	return p.check(ctx, rawURL, func(r Rule, u *url.URL) error {
+		if rc, ok := r.(Rechecker); ok {
+			return rc.Recheck(ctx, u)
+		}
+		return r.Check(ctx, u)
+	})
+}
+
+// Run reloads changed list files every interval until ctx is done
+func (p *Policy) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, r := range p.rules {
+				if f, ok := r.(refresher); ok {
+					if err := f.refresh(); err != nil {
+						p.log.Errorw("policy list reload failed, keeping previous", "rule", r.Name(), "error", err)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gsk148/urlShorteningService/internal/app/logger"
+)
+
+type fakeResolver map[string][]net.IPAddr
+
+func (f fakeResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	if addrs, ok := f[host]; ok {
+		return addrs, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func writeList(t *testing.T, lines string) string {
+	path := filepath.Join(t.TempDir(), "list.txt")
+	require.NoError(t, os.WriteFile(path, []byte(lines), 0644))
+	return path
+}
+
+func TestPolicy(t *testing.T) {
+	block, err := NewBlocklist(writeList(t, "# phishing\nEvil.example\n"))
+	require.NoError(t, err)
+	reputation, err := NewReputationFile(writeList(t, "https://files.example/malware\n"))
+	require.NoError(t, err)
+	private := NewPrivateAddressRule(fakeResolver{
+		"intranet.example": {{IP: net.ParseIP("10.1.2.3")}},
+		"public.example":   {{IP: net.ParseIP("93.184.216.34")}},
+	})
+	p := New(*logger.NewLogger(), block, private, reputation)
+
+	for _, u := range []string{
+		"https://evil.example/login",
+		"https://login.evil.example",
+		"http://127.0.0.1:8080/admin",
+		"http://[::1]/",
+		"http://169.254.169.254/latest/meta-data",
+		"https://intranet.example/",
+		"https://files.example/malware/setup.exe",
+	} {
+		assert.True(t, IsBlocked(p.Check(context.Background(), u)), u)
+	}
+	for _, u := range []string{
+		"https://public.example/",
+		"https://unresolvable.example/",
+		"https://notevil.example/",
+		"https://files.example/docs",
+	} {
+		assert.NoError(t, p.Check(context.Background(), u), u)
+	}
+
+	var nilPolicy *Policy
+	assert.NoError(t, nilPolicy.Check(context.Background(), "http://127.0.0.1"))
+}
+
+// failingResolver fails test when host name is looked up
+type failingResolver struct{ t *testing.T }
+
+func (f failingResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	f.t.Errorf("unexpected lookup of %s", host)
+	return nil, errors.New("unexpected lookup")
+}
+
+func TestRecheck(t *testing.T) {
+	block, err := NewBlocklist(writeList(t, "evil.example\n"))
+	require.NoError(t, err)
+	p := New(*logger.NewLogger(), block, NewPrivateAddressRule(failingResolver{t}))
+
+	assert.True(t, IsBlocked(p.Recheck(context.Background(), "https://evil.example/")))
+	assert.True(t, IsBlocked(p.Recheck(context.Background(), "http://10.0.0.1/")))
+	assert.NoError(t, p.Recheck(context.Background(), "https://intranet.example/"))
+
+	var nilPolicy *Policy
+	assert.NoError(t, nilPolicy.Recheck(context.Background(), "http://127.0.0.1"))
+}
+
+func TestReputationFile(t *testing.T) {
+	reputation, err := NewReputationFile(writeList(t, "https://evil.example\nhttps://files.example/malware/\nhttps://cdn.example/get?id=1\nhttp://bad.example/x\n"))
+	require.NoError(t, err)
+	p := New(*logger.NewLogger(), reputation)
+
+	for _, u := range []string{
+		"https://evil.example/",
+		"https://EVIL.example/login",
+		"https://files.example/malware",
+		"https://files.example/malware/setup.exe",
+		"https://cdn.example/get?id=1",
+		"http://files.example/malware/setup.exe",
+		"http://cdn.example/get?id=1",
+		"https://bad.example/x",
+		"https://bad.example/x/y",
+	} {
+		assert.True(t, IsBlocked(p.Check(context.Background(), u)), u)
+	}
+	for _, u := range []string{
+		"https://evil.example.org/",
+		"https://evil.example:8443/",
+		"https://sub.evil.example/",
+		"https://files.example/malware-free",
+		"https://files.example/malwarex/setup.exe",
+		"https://cdn.example/get?id=10",
+		"https://bad.example/xy",
+	} {
+		assert.NoError(t, p.Check(context.Background(), u), u)
+	}
+}
+
+func TestAllowlistReload(t *testing.T) {
+	path := writeList(t, "example.com\n")
+	allow, err := NewAllowlist(path)
+	require.NoError(t, err)
+	p := New(*logger.NewLogger(), allow)
+
+	assert.NoError(t, p.Check(context.Background(), "https://www.example.com/"))
+	assert.True(t, IsBlocked(p.Check(context.Background(), "https://other.org/")))
+
+	require.NoError(t, os.WriteFile(path, []byte("other.org\n"), 0644))
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx, 10*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return p.Check(context.Background(), "https://other.org/") == nil
+	}, time.Second, 10*time.Millisecond)
+}
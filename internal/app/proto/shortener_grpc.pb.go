@@ -27,6 +27,7 @@ const (
 	ShortenerService_Ping_FullMethodName            = "/proto.ShortenerService/Ping"
 	ShortenerService_ShortenAPI_FullMethodName      = "/proto.ShortenerService/ShortenAPI"
 	ShortenerService_Shorten_FullMethodName         = "/proto.ShortenerService/Shorten"
+	ShortenerService_UpdateURL_FullMethodName       = "/proto.ShortenerService/UpdateURL"
 )
 
 // ShortenerServiceClient is the client API for ShortenerService service.
@@ -36,11 +37,12 @@ type ShortenerServiceClient interface {
 	BatchShortenAPI(ctx context.Context, in *BatchShortenAPIRequest, opts ...grpc.CallOption) (*BatchShortenAPIResponse, error)
 	DeleteURLs(ctx context.Context, in *DeleteURLsRequest, opts ...grpc.CallOption) (*DeleteURLsResponse, error)
 	FindByShortLink(ctx context.Context, in *FindByShortLinkRequest, opts ...grpc.CallOption) (*URLInfo, error)
-	FindUserURLS(ctx context.Context, in *FindUserURLSRequest, opts ...grpc.CallOption) (*BatchShortenAPIResponse, error)
+	FindUserURLS(ctx context.Context, in *FindUserURLSRequest, opts ...grpc.CallOption) (*FindUserURLSResponse, error)
 	GetStats(ctx context.Context, in *GetStatisticRequest, opts ...grpc.CallOption) (*GetStatisticResponse, error)
 	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
 	ShortenAPI(ctx context.Context, in *ShortenAPIRequest, opts ...grpc.CallOption) (*ShortenAPIResponse, error)
 	Shorten(ctx context.Context, in *ShortenRequest, opts ...grpc.CallOption) (*ShortenResponse, error)
+	UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*URLInfo, error)
 }
 
 type shortenerServiceClient struct {
@@ -78,8 +80,8 @@ func (c *shortenerServiceClient) FindByShortLink(ctx context.Context, in *FindBy
 	return out, nil
 }
 
-func (c *shortenerServiceClient) FindUserURLS(ctx context.Context, in *FindUserURLSRequest, opts ...grpc.CallOption) (*BatchShortenAPIResponse, error) {
-	out := new(BatchShortenAPIResponse)
+func (c *shortenerServiceClient) FindUserURLS(ctx context.Context, in *FindUserURLSRequest, opts ...grpc.CallOption) (*FindUserURLSResponse, error) {
+	out := new(FindUserURLSResponse)
 	err := c.cc.Invoke(ctx, ShortenerService_FindUserURLS_FullMethodName, in, out, opts...)
 	if err != nil {
 		return nil, err
@@ -123,6 +125,15 @@ func (c *shortenerServiceClient) Shorten(ctx context.Context, in *ShortenRequest
 	return out, nil
 }
 
+func (c *shortenerServiceClient) UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*URLInfo, error) {
+	out := new(URLInfo)
+	err := c.cc.Invoke(ctx, ShortenerService_UpdateURL_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ShortenerServiceServer is the server API for ShortenerService service.
 // All implementations must embed UnimplementedShortenerServiceServer
 // for forward compatibility
@@ -130,11 +141,12 @@ type ShortenerServiceServer interface {
 	BatchShortenAPI(context.Context, *BatchShortenAPIRequest) (*BatchShortenAPIResponse, error)
 	DeleteURLs(context.Context, *DeleteURLsRequest) (*DeleteURLsResponse, error)
 	FindByShortLink(context.Context, *FindByShortLinkRequest) (*URLInfo, error)
-	FindUserURLS(context.Context, *FindUserURLSRequest) (*BatchShortenAPIResponse, error)
+	FindUserURLS(context.Context, *FindUserURLSRequest) (*FindUserURLSResponse, error)
 	GetStats(context.Context, *GetStatisticRequest) (*GetStatisticResponse, error)
 	Ping(context.Context, *PingRequest) (*PingResponse, error)
 	ShortenAPI(context.Context, *ShortenAPIRequest) (*ShortenAPIResponse, error)
 	Shorten(context.Context, *ShortenRequest) (*ShortenResponse, error)
+	UpdateURL(context.Context, *UpdateURLRequest) (*URLInfo, error)
 	mustEmbedUnimplementedShortenerServiceServer()
 }
 
@@ -151,7 +163,7 @@ func (UnimplementedShortenerServiceServer) DeleteURLs(context.Context, *DeleteUR
 func (UnimplementedShortenerServiceServer) FindByShortLink(context.Context, *FindByShortLinkRequest) (*URLInfo, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method FindByShortLink not implemented")
 }
-func (UnimplementedShortenerServiceServer) FindUserURLS(context.Context, *FindUserURLSRequest) (*BatchShortenAPIResponse, error) {
+func (UnimplementedShortenerServiceServer) FindUserURLS(context.Context, *FindUserURLSRequest) (*FindUserURLSResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method FindUserURLS not implemented")
 }
 func (UnimplementedShortenerServiceServer) GetStats(context.Context, *GetStatisticRequest) (*GetStatisticResponse, error) {
@@ -166,6 +178,9 @@ func (UnimplementedShortenerServiceServer) ShortenAPI(context.Context, *ShortenA
 func (UnimplementedShortenerServiceServer) Shorten(context.Context, *ShortenRequest) (*ShortenResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Shorten not implemented")
 }
+func (UnimplementedShortenerServiceServer) UpdateURL(context.Context, *UpdateURLRequest) (*URLInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateURL not implemented")
+}
 func (UnimplementedShortenerServiceServer) mustEmbedUnimplementedShortenerServiceServer() {}
 
 // UnsafeShortenerServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -323,8 +338,26 @@ func _ShortenerService_Shorten_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ShortenerService_UpdateURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServiceServer).UpdateURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShortenerService_UpdateURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServiceServer).UpdateURL(ctx, req.(*UpdateURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ShortenerService_ServiceDesc is the grpc.ServiceDesc for ShortenerService service.
-// It's only intended for direct use with grpchandlers.RegisterService,
+// It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var ShortenerService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "proto.ShortenerService",
@@ -362,6 +395,248 @@ var ShortenerService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Shorten",
 			Handler:    _ShortenerService_Shorten_Handler,
 		},
+		{
+			MethodName: "UpdateURL",
+			Handler:    _ShortenerService_UpdateURL_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/shortener.proto",
+}
+
+const (
+	AdminService_GetLink_FullMethodName        = "/proto.AdminService/GetLink"
+	AdminService_UpdateLink_FullMethodName     = "/proto.AdminService/UpdateLink"
+	AdminService_DeleteByDomain_FullMethodName = "/proto.AdminService/DeleteByDomain"
+	AdminService_ListUserLinks_FullMethodName  = "/proto.AdminService/ListUserLinks"
+	AdminService_ListAudit_FullMethodName      = "/proto.AdminService/ListAudit"
+)
+
+// AdminServiceClient is the client API for AdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AdminServiceClient interface {
+	GetLink(ctx context.Context, in *AdminGetLinkRequest, opts ...grpc.CallOption) (*URLInfo, error)
+	UpdateLink(ctx context.Context, in *AdminUpdateLinkRequest, opts ...grpc.CallOption) (*URLInfo, error)
+	DeleteByDomain(ctx context.Context, in *AdminDeleteByDomainRequest, opts ...grpc.CallOption) (*AdminDeleteByDomainResponse, error)
+	ListUserLinks(ctx context.Context, in *AdminListUserLinksRequest, opts ...grpc.CallOption) (*FindUserURLSResponse, error)
+	ListAudit(ctx context.Context, in *AdminListAuditRequest, opts ...grpc.CallOption) (*AdminListAuditResponse, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) GetLink(ctx context.Context, in *AdminGetLinkRequest, opts ...grpc.CallOption) (*URLInfo, error) {
+	out := new(URLInfo)
+	err := c.cc.Invoke(ctx, AdminService_GetLink_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) UpdateLink(ctx context.Context, in *AdminUpdateLinkRequest, opts ...grpc.CallOption) (*URLInfo, error) {
+	out := new(URLInfo)
+	err := c.cc.Invoke(ctx, AdminService_UpdateLink_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) DeleteByDomain(ctx context.Context, in *AdminDeleteByDomainRequest, opts ...grpc.CallOption) (*AdminDeleteByDomainResponse, error) {
+	out := new(AdminDeleteByDomainResponse)
+	err := c.cc.Invoke(ctx, AdminService_DeleteByDomain_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListUserLinks(ctx context.Context, in *AdminListUserLinksRequest, opts ...grpc.CallOption) (*FindUserURLSResponse, error) {
+	out := new(FindUserURLSResponse)
+	err := c.cc.Invoke(ctx, AdminService_ListUserLinks_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListAudit(ctx context.Context, in *AdminListAuditRequest, opts ...grpc.CallOption) (*AdminListAuditResponse, error) {
+	out := new(AdminListAuditResponse)
+	err := c.cc.Invoke(ctx, AdminService_ListAudit_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServiceServer is the server API for AdminService service.
+// All implementations must embed UnimplementedAdminServiceServer
+// for forward compatibility
+type AdminServiceServer interface {
+	GetLink(context.Context, *AdminGetLinkRequest) (*URLInfo, error)
+	UpdateLink(context.Context, *AdminUpdateLinkRequest) (*URLInfo, error)
+	DeleteByDomain(context.Context, *AdminDeleteByDomainRequest) (*AdminDeleteByDomainResponse, error)
+	ListUserLinks(context.Context, *AdminListUserLinksRequest) (*FindUserURLSResponse, error)
+	ListAudit(context.Context, *AdminListAuditRequest) (*AdminListAuditResponse, error)
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+// UnimplementedAdminServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAdminServiceServer struct {
+}
+
+func (UnimplementedAdminServiceServer) GetLink(context.Context, *AdminGetLinkRequest) (*URLInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLink not implemented")
+}
+func (UnimplementedAdminServiceServer) UpdateLink(context.Context, *AdminUpdateLinkRequest) (*URLInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateLink not implemented")
+}
+func (UnimplementedAdminServiceServer) DeleteByDomain(context.Context, *AdminDeleteByDomainRequest) (*AdminDeleteByDomainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteByDomain not implemented")
+}
+func (UnimplementedAdminServiceServer) ListUserLinks(context.Context, *AdminListUserLinksRequest) (*FindUserURLSResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListUserLinks not implemented")
+}
+func (UnimplementedAdminServiceServer) ListAudit(context.Context, *AdminListAuditRequest) (*AdminListAuditResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAudit not implemented")
+}
+func (UnimplementedAdminServiceServer) mustEmbedUnimplementedAdminServiceServer() {}
+
+// UnsafeAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServiceServer will
+// result in compilation errors.
+type UnsafeAdminServiceServer interface {
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	s.RegisterService(&AdminService_ServiceDesc, srv)
+}
+
+func _AdminService_GetLink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminGetLinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetLink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetLink_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetLink(ctx, req.(*AdminGetLinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_UpdateLink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminUpdateLinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).UpdateLink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_UpdateLink_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).UpdateLink(ctx, req.(*AdminUpdateLinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_DeleteByDomain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminDeleteByDomainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).DeleteByDomain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_DeleteByDomain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).DeleteByDomain(ctx, req.(*AdminDeleteByDomainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListUserLinks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminListUserLinksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListUserLinks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ListUserLinks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListUserLinks(ctx, req.(*AdminListUserLinksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListAudit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminListAuditRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListAudit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ListAudit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListAudit(ctx, req.(*AdminListAuditRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AdminService_ServiceDesc is the grpc.ServiceDesc for AdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetLink",
+			Handler:    _AdminService_GetLink_Handler,
+		},
+		{
+			MethodName: "UpdateLink",
+			Handler:    _AdminService_UpdateLink_Handler,
+		},
+		{
+			MethodName: "DeleteByDomain",
+			Handler:    _AdminService_DeleteByDomain_Handler,
+		},
+		{
+			MethodName: "ListUserLinks",
+			Handler:    _AdminService_ListUserLinks_Handler,
+		},
+		{
+			MethodName: "ListAudit",
+			Handler:    _AdminService_ListAudit_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/shortener.proto",
@@ -0,0 +1,169 @@
+// Package ratelimit limits requests with token buckets for REST routes and gRPC methods.
+// REST requests with valid token cookie are limited per user, other requests per
+// client IP. gRPC user id metadata is not authenticated, so gRPC calls are limited per IP
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/gsk148/urlShorteningService/internal/app/auth"
+	"github.com/gsk148/urlShorteningService/internal/app/clientip"
+	"github.com/gsk148/urlShorteningService/internal/app/config"
+)
+
+// exempt routes are never limited so probes keep working under load
+var exempt = map[string]bool{
+	"GET /healthz":                 true,
+	"GET /readyz":                  true,
+	"/grpc.health.v1.Health/Check": true,
+	"/grpc.health.v1.Health/Watch": true,
+}
+
+// Limiter applies configured limits, routes are keyed as "METHOD /pattern"
+// for REST and as full method name like "/proto.ShortenerService/Shorten" for gRPC
+type Limiter struct {
+	store Store
+	log   zap.SugaredLogger
+	now   func() time.Time
+
+	mu     sync.RWMutex
+	def    Limit
+	routes map[string]Limit
+}
+
+// New return Limiter object
+func New(cfg *config.Config, store Store, log zap.SugaredLogger) *Limiter {
+	l := &Limiter{store: store, log: log, now: time.Now}
+	l.ApplyConfig(cfg)
+	return l
+}
+
+// ApplyConfig swaps limits, buckets keep their tokens
+func (l *Limiter) ApplyConfig(cfg *config.Config) {
+	routes := make(map[string]Limit, len(cfg.RateLimitRoutes))
+	for route, rl := range cfg.RateLimitRoutes {
+		routes[route] = Limit{Rate: rl.Rate, Burst: rl.Burst}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.def = Limit{Rate: cfg.RateLimit, Burst: cfg.RateLimitBurst}
+	l.routes = routes
+}
+
+// limitFor returns limit and bucket name of route, ok is false when route is not limited
+func (l *Limiter) limitFor(route string) (limit Limit, bucket string, ok bool) {
+	if exempt[route] {
+		return Limit{}, "", false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if rl, found := l.routes[route]; found {
+		return rl, route, rl.Rate > 0
+	}
+	return l.def, "*", l.def.Rate > 0
+}
+
+// Allow takes token of client for route. Errors of store let request through.
+func (l *Limiter) Allow(ctx context.Context, route, client string) (Result, bool) {
+	limit, bucket, ok := l.limitFor(route)
+	if !ok {
+		return Result{Allowed: true}, false
+	}
+	res, err := l.store.Take(ctx, bucket+"|"+client, limit, l.now())
+	if err != nil {
+		l.log.Warnw("rate limit store failed, request allowed", "error", err)
+		return Result{Allowed: true}, false
+	}
+	return res, true
+}
+
+// Middleware rejects requests over limit with 429 and reports limit in RateLimit-* headers
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, limited := l.Allow(r.Context(), routeOf(r), httpClient(r))
+		if limited {
+			setHeaders(res, w.Header().Set)
+		}
+		if !res.Allowed {
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UnaryServerInterceptor rejects calls over limit with ResourceExhausted
+// and reports limit in ratelimit-* response headers
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		res, limited := l.Allow(ctx, info.FullMethod, clientKey(clientip.FromCall(ctx)))
+		if limited {
+			md := metadata.MD{}
+			setHeaders(res, func(key, value string) { md.Set(key, value) })
+			_ = grpc.SetHeader(ctx, md)
+		}
+		if !res.Allowed {
+			return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry after %s", res.RetryAfter.Round(time.Millisecond))
+		}
+		return handler(ctx, req)
+	}
+}
+
+func setHeaders(res Result, set func(key, value string)) {
+	set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	set("RateLimit-Reset", ceilSeconds(res.Reset))
+	if !res.Allowed {
+		set("Retry-After", ceilSeconds(res.RetryAfter))
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// routeOf resolves chi route pattern before routing happens
+func routeOf(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.Routes != nil {
+		match := chi.NewRouteContext()
+		if rctx.Routes.Match(match, r.Method, r.URL.Path) {
+			// chi trims trailing slash, so root pattern comes back empty
+			pattern := match.RoutePattern()
+			if pattern == "" {
+				pattern = "/"
+			}
+			return r.Method + " " + pattern
+		}
+	}
+	return r.Method + " " + r.URL.Path
+}
+
+// httpClient returns bucket key of verified user or of client address for anonymous requests
+func httpClient(r *http.Request) string {
+	if userID, ok := auth.UserIDFromRequest(r); ok {
+		return "user:" + userID
+	}
+	return clientKey(clientip.FromRequest(r))
+}
+
+// clientKey returns bucket key of client address
+func clientKey(ip net.IP) string {
+	if ip == nil {
+		return "ip:unknown"
+	}
+	return "ip:" + ip.String()
+}
@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/gsk148/urlShorteningService/internal/app/auth"
+	"github.com/gsk148/urlShorteningService/internal/app/config"
+	"github.com/gsk148/urlShorteningService/internal/app/logger"
+)
+
+func TestMemoryStoreRefill(t *testing.T) {
+	s := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Now()
+
+	res, _ := s.Take(context.Background(), "k", limit, now)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+	res, _ = s.Take(context.Background(), "k", limit, now)
+	assert.True(t, res.Allowed)
+	res, _ = s.Take(context.Background(), "k", limit, now)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+
+	res, _ = s.Take(context.Background(), "k", limit, now.Add(time.Second))
+	assert.True(t, res.Allowed)
+
+	s.Take(context.Background(), "k", limit, now.Add(sweepInterval+time.Hour))
+	assert.Equal(t, 1, s.Len())
+}
+
+func testLimiter() *Limiter {
+	cfg := config.Default()
+	cfg.RateLimit = 100
+	cfg.RateLimitRoutes = map[string]config.RouteLimit{
+		"POST /":       {Rate: 1, Burst: 1},
+		"GET /{id}":    {Rate: 0},
+		"/svc/Shorten": {Rate: 1, Burst: 1},
+	}
+	return New(cfg, NewMemoryStore(), *logger.NewLogger())
+}
+
+func TestMiddleware(t *testing.T) {
+	l := testLimiter()
+	r := chi.NewRouter()
+	r.Use(l.Middleware)
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	r.Post("/", ok)
+	r.Get("/{id}", ok)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "10.0.0.1:5555"
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+	rec = do(http.MethodPost, "/")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = "10.0.0.1:6666"
+	req.AddCookie(&http.Cookie{Name: auth.CookieName, Value: "rotated"})
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, "invalid cookie shares bucket of address")
+
+	// verified user has own bucket
+	issued := httptest.NewRecorder()
+	_, err := auth.GetUserToken(issued, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Len(t, issued.Result().Cookies(), 1)
+	userPost := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = "10.0.0.1:7777"
+		req.AddCookie(issued.Result().Cookies()[0])
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusOK, userPost())
+	assert.Equal(t, http.StatusTooManyRequests, userPost())
+
+	for i := 0; i < 3; i++ {
+		rec = do(http.MethodGet, "/abc")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := testLimiter().UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/svc/Shorten"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+
+	call := func(userID string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user-id", userID))
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 5555}})
+		_, err := interceptor(ctx, nil, info, handler)
+		return err
+	}
+
+	require.NoError(t, call("a"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(call("b")), "new user id shares bucket of address")
+}
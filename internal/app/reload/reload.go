@@ -0,0 +1,143 @@
+// Package reload re-reads configuration on SIGHUP or config file change
+// and applies reloadable settings to running components
+package reload
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/gsk148/urlShorteningService/internal/app/config"
+)
+
+// Target is a running component accepting new settings
+type Target interface {
+	ApplyConfig(cfg *config.Config)
+}
+
+// TargetFunc adapts function to Target
+type TargetFunc func(cfg *config.Config)
+
+// ApplyConfig calls f(cfg)
+func (f TargetFunc) ApplyConfig(cfg *config.Config) {
+	f(cfg)
+}
+
+// Reloader keeps current configuration and swaps it on reload
+type Reloader struct {
+	args      []string
+	lookupEnv func(string) (string, bool)
+	targets   []Target
+	log       zap.SugaredLogger
+
+	mu      sync.Mutex
+	current *config.Config
+	modTime time.Time
+	size    int64
+}
+
+// New return Reloader object. args and lookupEnv must be the same that produced current
+func New(current *config.Config, args []string, lookupEnv func(string) (string, bool), log zap.SugaredLogger, targets ...Target) *Reloader {
+	r := &Reloader{
+		args:      args,
+		lookupEnv: lookupEnv,
+		targets:   targets,
+		log:       log,
+		current:   current,
+	}
+	r.modTime, r.size = stat(current.Config)
+	return r
+}
+
+// Current returns configuration applied last
+func (r *Reloader) Current() *config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload parses configuration again and applies it to every target.
+// Invalid configuration is rejected and the running settings stay untouched.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := config.Parse(r.args, r.lookupEnv)
+	if err != nil {
+		r.log.Errorw("config reload rejected", "error", err)
+		return err
+	}
+	r.modTime, r.size = stat(next.Config)
+
+	reloadable, restart := r.current.Diff(next)
+	for _, line := range restart {
+		r.log.Warnw("config change requires restart, ignored", "change", line)
+	}
+	if len(reloadable) == 0 {
+		r.log.Info("config reloaded, nothing changed")
+		return nil
+	}
+
+	// keep non reloadable values so the next diff is computed against what is running
+	r.current = r.current.WithReloadable(next)
+	for _, t := range r.targets {
+		t.ApplyConfig(r.current)
+	}
+
+	for _, line := range reloadable {
+		r.log.Infow("config reloaded", "change", line)
+	}
+	return nil
+}
+
+// Run reloads configuration on SIGHUP and when config file changes until ctx is done
+func (r *Reloader) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval := r.current.ReloadInterval; interval > 0 && r.current.Config != "" {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.log.Info("SIGHUP received, reloading config")
+			_ = r.Reload()
+		case <-tick:
+			if r.fileChanged() {
+				r.log.Infow("config file changed, reloading", "file", r.current.Config)
+				_ = r.Reload()
+			}
+		}
+	}
+}
+
+func (r *Reloader) fileChanged() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	modTime, size := stat(r.current.Config)
+	return !modTime.Equal(r.modTime) || size != r.size
+}
+
+func stat(path string) (time.Time, int64) {
+	if path == "" {
+		return time.Time{}, 0
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return info.ModTime(), info.Size()
+}
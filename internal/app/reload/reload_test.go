@@ -0,0 +1,57 @@
+package reload
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gsk148/urlShorteningService/internal/app/config"
+	"github.com/gsk148/urlShorteningService/internal/app/logger"
+)
+
+func noEnv(string) (string, bool) {
+	return "", false
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	write("trusted_subnet: 10.0.0.0/8\nserver_address: localhost:8080\n")
+
+	args := []string{"-c", path}
+	cfg, err := config.Parse(args, noEnv)
+	require.NoError(t, err)
+
+	var applied []*config.Config
+	r := New(cfg, args, noEnv, *logger.NewLogger(), TargetFunc(func(c *config.Config) {
+		applied = append(applied, c)
+	}))
+
+	t.Run("reloadable change is applied", func(t *testing.T) {
+		write("trusted_subnet: 192.168.0.0/16\nserver_address: localhost:9090\nlog_level: warn\n")
+		require.NoError(t, r.Reload())
+		require.Len(t, applied, 1)
+		assert.Equal(t, "192.168.0.0/16", applied[0].TrustedSubnet)
+		assert.Equal(t, "warn", applied[0].LogLevel)
+		assert.Equal(t, "localhost:8080", applied[0].ServerAddr, "non reloadable setting must stay")
+		assert.Equal(t, "192.168.0.0/16", r.Current().TrustedSubnet)
+	})
+
+	t.Run("invalid config is rejected", func(t *testing.T) {
+		write("trusted_subnet: not-a-subnet\n")
+		assert.Error(t, r.Reload())
+		assert.Len(t, applied, 1)
+		assert.Equal(t, "192.168.0.0/16", r.Current().TrustedSubnet)
+	})
+
+	t.Run("unchanged config is not applied", func(t *testing.T) {
+		write("trusted_subnet: 192.168.0.0/16\nlog_level: warn\n")
+		require.NoError(t, r.Reload())
+		assert.Len(t, applied, 1)
+	})
+}
@@ -0,0 +1,174 @@
+// Package server runs REST and gRPC servers with background workers
+// and shuts them down gracefully
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/gsk148/urlShorteningService/internal/app/config"
+	"github.com/gsk148/urlShorteningService/internal/app/deletion"
+	"github.com/gsk148/urlShorteningService/internal/app/health"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+)
+
+// Worker is background job running until ctx is done
+type Worker func(ctx context.Context)
+
+// App owns every long living part of the service
+type App struct {
+	Config    *config.Config
+	Log       zap.SugaredLogger
+	Store     storage.Storage
+	Deletions *deletion.Queue
+	Health    *health.Checker
+	REST      http.Handler
+	GRPC      *grpc.Server
+	TLS       *tls.Config
+	Workers   []Worker
+}
+
+// NewGRPCServer returns gRPC server with tracing and TLS when tlsCfg is set
+func NewGRPCServer(tlsCfg *tls.Config, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.StatsHandler(otelgrpc.NewServerHandler()))
+	if tlsCfg != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+	return grpc.NewServer(opts...)
+}
+
+// Run starts servers and workers and blocks until ctx is done or a server fails.
+// Then it stops workers, drains servers within Config.ShutdownTimeout, flushes pending
+// deletions within Config.FlushTimeout and closes storage. Deletions get their own
+// budget, so slow requests cannot starve them.
+func (a *App) Run(ctx context.Context) error {
+	restLn, err := net.Listen("tcp", a.Config.ServerAddr)
+	if err != nil {
+		return fmt.Errorf("listen REST: %w", err)
+	}
+	grpcLn, err := net.Listen("tcp", a.Config.GRPCAddr)
+	if err != nil {
+		restLn.Close()
+		return fmt.Errorf("listen gRPC: %w", err)
+	}
+
+	restSrv := &http.Server{
+		Handler:           a.REST,
+		TLSConfig:         a.TLS,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	for _, w := range a.Workers {
+		workers.Add(1)
+		go func(w Worker) {
+			defer workers.Done()
+			w(workersCtx)
+		}(w)
+	}
+
+	errCh := make(chan error, 2)
+	go func() {
+		var err error
+		if a.TLS != nil {
+			err = restSrv.ServeTLS(restLn, "", "")
+		} else {
+			err = restSrv.Serve(restLn)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- fmt.Errorf("REST server: %w", err)
+		}
+	}()
+	go func() {
+		if err := a.GRPC.Serve(grpcLn); err != nil {
+			errCh <- fmt.Errorf("gRPC server: %w", err)
+		}
+	}()
+
+	a.setReady(true)
+	a.Log.Infow("server started", "rest", restLn.Addr().String(), "grpc", grpcLn.Addr().String(), "tls", a.TLS != nil)
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		a.Log.Info("shutdown signal received")
+	case runErr = <-errCh:
+		a.Log.Errorw("server failed, shutting down", "error", runErr)
+	}
+	a.setReady(false)
+
+	// workers use storage, so they are stopped before it is closed
+	stopWorkers()
+	workers.Wait()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.Config.ShutdownTimeout)
+	defer cancel()
+	shutdownErr := a.shutdown(shutdownCtx, restSrv)
+
+	if runErr != nil {
+		return runErr
+	}
+	return shutdownErr
+}
+
+func (a *App) setReady(ready bool) {
+	if a.Health != nil {
+		a.Health.SetServing(ready)
+	}
+}
+
+func (a *App) shutdown(ctx context.Context, restSrv *http.Server) error {
+	var errs []error
+
+	grpcStopped := make(chan struct{})
+	go func() {
+		a.GRPC.GracefulStop()
+		close(grpcStopped)
+	}()
+
+	if err := restSrv.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("REST shutdown: %w", err))
+	}
+
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		a.GRPC.Stop()
+		errs = append(errs, errors.New("gRPC shutdown: drain timeout exceeded"))
+	}
+
+	if a.Deletions != nil {
+		if pending := a.Deletions.Len(); pending > 0 {
+			a.Log.Infow("flushing pending deletions", "count", pending)
+		}
+		flushCtx, cancel := context.WithTimeout(context.Background(), a.Config.FlushTimeout)
+		err := a.Deletions.Close(flushCtx)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("flush deletions: %w", err))
+		}
+	}
+
+	if err := a.Store.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close storage: %w", err))
+	}
+
+	if len(errs) > 0 {
+		a.Log.Errorw("shutdown finished with errors", "errors", errs)
+		return errs[0]
+	}
+	a.Log.Info("shutdown complete")
+	return nil
+}
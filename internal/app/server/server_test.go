@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+	"github.com/gsk148/urlShorteningService/internal/app/config"
+	"github.com/gsk148/urlShorteningService/internal/app/deletion"
+	"github.com/gsk148/urlShorteningService/internal/app/logger"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+)
+
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+type slowDeleteStorage struct {
+	storage.Storage
+}
+
+func (s slowDeleteStorage) DeleteByUserIDAndShort(ctx context.Context, userID, shortURL string) error {
+	time.Sleep(30 * time.Millisecond)
+	return s.Storage.DeleteByUserIDAndShort(ctx, userID, shortURL)
+}
+
+func TestRunFlushesDeletionsAfterDrainTimeout(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	var shorts []string
+	for i := 0; i < 5; i++ {
+		short := fmt.Sprintf("s%d", i)
+		_, err := store.Store(context.Background(), api.ShortenedData{UserID: "user", ShortURL: short, OriginalURL: "https://" + short + ".example/"})
+		require.NoError(t, err)
+		shorts = append(shorts, short)
+	}
+
+	cfg := config.Default()
+	cfg.ServerAddr = freeAddr(t)
+	cfg.GRPCAddr = freeAddr(t)
+	cfg.ShutdownTimeout = 50 * time.Millisecond
+	cfg.FlushTimeout = 5 * time.Second
+
+	log := *logger.NewLogger()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	app := &App{
+		Config:    cfg,
+		Log:       log,
+		Store:     slowDeleteStorage{store},
+		Deletions: deletion.NewQueue(slowDeleteStorage{store}, log, len(shorts)),
+		REST: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		}),
+		GRPC: NewGRPCServer(nil),
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.Run(ctx) }()
+
+	// the request stays in flight, so draining REST exceeds ShutdownTimeout
+	go func() {
+		for {
+			resp, err := http.Get("http://" + cfg.ServerAddr)
+			if err == nil {
+				resp.Body.Close()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	<-started
+
+	require.NoError(t, app.Deletions.Enqueue(context.Background(), "user", shorts))
+	stop()
+
+	select {
+	case err := <-done:
+		assert.Error(t, err, "drain should exceed ShutdownTimeout")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+
+	for _, short := range shorts {
+		data, err := store.Get(context.Background(), short)
+		require.NoError(t, err)
+		assert.True(t, data.IsDeleted, short)
+	}
+}
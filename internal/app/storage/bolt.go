@@ -0,0 +1,460 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+)
+
+// Buckets of BoltStorage. Links are keyed by api.LinkKey, indexes map originalKey
+// to link key and userID+0+link key to nothing
+var (
+	linksBucket      = []byte("links")
+	byOriginalBucket = []byte("by_original")
+	byUserBucket     = []byte("by_user")
+	historyBucket    = []byte("history")
+	metaBucket       = []byte("meta")
+)
+
+// boltSchemaVersion is stored in meta bucket for future layout changes
+const boltSchemaVersion = "1"
+
+// boltWalkBatch is number of links read in one transaction by Walk
+const boltWalkBatch = 500
+
+// BoltStorage keeps links in embedded bbolt database, every change is one durable transaction
+type BoltStorage struct {
+	db *bolt.DB
+}
+
+// NewBoltStorage return BoltStorage object, database file is created when missing
+func NewBoltStorage(path string) (*BoltStorage, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{linksBucket, byOriginalBucket, byUserBucket, historyBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		meta := tx.Bucket(metaBucket)
+		if v := meta.Get([]byte("version")); v != nil && string(v) != boltSchemaVersion {
+			return fmt.Errorf("unsupported schema version %s", v)
+		}
+		return meta.Put([]byte("version"), []byte(boltSchemaVersion))
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStorage{db: db}, nil
+}
+
+func userKey(userID, key string) []byte {
+	return append(append([]byte(userID), 0), key...)
+}
+
+func getLink(tx *bolt.Tx, shortURL string) (api.ShortenedData, error) {
+	v := tx.Bucket(linksBucket).Get([]byte(shortURL))
+	if v == nil {
+		return api.ShortenedData{}, notFound(shortURL)
+	}
+	var data api.ShortenedData
+	err := json.Unmarshal(v, &data)
+	return data, err
+}
+
+func putLink(tx *bolt.Tx, data api.ShortenedData) error {
+	v, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(linksBucket).Put([]byte(data.Key()), v)
+}
+
+// Store data and return error if already exists and short url if not
+func (s *BoltStorage) Store(ctx context.Context, data api.ShortenedData) (api.ShortenedData, error) {
+	var existing api.ShortenedData
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		original := []byte(originalKey(data.Domain, data.OriginalURL))
+		if key := tx.Bucket(byOriginalBucket).Get(original); key != nil {
+			var err error
+			if existing, err = getLink(tx, string(key)); err != nil {
+				return err
+			}
+			return &ErrURLExists{}
+		}
+		key := data.Key()
+		if tx.Bucket(linksBucket).Get([]byte(key)) != nil {
+			return ErrShortURLTaken
+		}
+		if err := putLink(tx, data); err != nil {
+			return err
+		}
+		if err := tx.Bucket(byOriginalBucket).Put(original, []byte(key)); err != nil {
+			return err
+		}
+		return tx.Bucket(byUserBucket).Put(userKey(data.UserID, key), nil)
+	})
+	if errors.Is(err, &ErrURLExists{}) {
+		return existing, err
+	}
+	if err != nil {
+		return api.ShortenedData{}, err
+	}
+	return data, nil
+}
+
+// Get returns full url by short url
+func (s *BoltStorage) Get(ctx context.Context, key string) (api.ShortenedData, error) {
+	var data api.ShortenedData
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		data, err = getLink(tx, key)
+		return err
+	})
+	return data, err
+}
+
+// Ping checks that database is open
+func (s *BoltStorage) Ping(ctx context.Context) error {
+	return s.db.View(func(*bolt.Tx) error { return nil })
+}
+
+// Close closes database file
+func (s *BoltStorage) Close() error {
+	return s.db.Close()
+}
+
+// userLinks returns links of user found by index
+func userLinks(tx *bolt.Tx, userID string) ([]api.ShortenedData, error) {
+	var links []api.ShortenedData
+	prefix := userKey(userID, "")
+	c := tx.Bucket(byUserBucket).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		data, err := getLink(tx, string(k[len(prefix):]))
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, data)
+	}
+	return links, nil
+}
+
+// ListByUserID returns page of user links matching query
+func (s *BoltStorage) ListByUserID(ctx context.Context, userID string, q api.URLListQuery) (api.URLListPage, error) {
+	q, c, err := prepareListQuery(q)
+	if err != nil {
+		return api.URLListPage{}, err
+	}
+	var links []api.ShortenedData
+	err = s.db.View(func(tx *bolt.Tx) error {
+		links, err = userLinks(tx, userID)
+		return err
+	})
+	if err != nil {
+		return api.URLListPage{}, err
+	}
+	return listLinks(links, q, c, time.Now()), nil
+}
+
+// Walk calls fn for every link of user or for all links when userID is empty,
+// oldest first. Order is read in one transaction and links are then read in batches
+// outside of fn, so fn may use storage. Links removed or moved to other user
+// meanwhile are skipped
+func (s *BoltStorage) Walk(ctx context.Context, userID string, fn func(api.ShortenedData) error) error {
+	var order []api.ShortenedData
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var links []api.ShortenedData
+		var err error
+		if userID != "" {
+			links, err = userLinks(tx, userID)
+		} else {
+			err = tx.Bucket(linksBucket).ForEach(func(_, v []byte) error {
+				var data api.ShortenedData
+				if err := json.Unmarshal(v, &data); err != nil {
+					return err
+				}
+				links = append(links, data)
+				return nil
+			})
+		}
+		// only fields used for ordering are kept until links are read again
+		order = make([]api.ShortenedData, len(links))
+		for i, v := range links {
+			order[i] = api.ShortenedData{Domain: v.Domain, ShortURL: v.ShortURL, CreatedAt: v.CreatedAt}
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(order, func(i, j int) bool { return compareLinks(api.SortCreated, order[i], order[j]) < 0 })
+
+	for len(order) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n := boltWalkBatch
+		if n > len(order) {
+			n = len(order)
+		}
+		batch := make([]api.ShortenedData, 0, n)
+		err := s.db.View(func(tx *bolt.Tx) error {
+			for _, v := range order[:n] {
+				data, err := getLink(tx, v.Key())
+				if errors.Is(err, ErrNotFound) || err == nil && userID != "" && data.UserID != userID {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				batch = append(batch, data)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		order = order[n:]
+		for _, data := range batch {
+			if err := fn(data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// updateLink applies change to existing link in one transaction
+func (s *BoltStorage) updateLink(shortURL string, change func(tx *bolt.Tx, v *api.ShortenedData) error) (api.ShortenedData, error) {
+	var data api.ShortenedData
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		if data, err = getLink(tx, shortURL); err != nil {
+			return err
+		}
+		if err = change(tx, &data); err != nil {
+			return err
+		}
+		return putLink(tx, data)
+	})
+	return data, err
+}
+
+// IncrementClicks counts redirect by short url
+func (s *BoltStorage) IncrementClicks(ctx context.Context, shortURL string) error {
+	_, err := s.updateLink(shortURL, func(_ *bolt.Tx, v *api.ShortenedData) error {
+		v.Clicks++
+		return nil
+	})
+	return err
+}
+
+// DeleteByUserIDAndShort marks short url of user as deleted
+func (s *BoltStorage) DeleteByUserIDAndShort(ctx context.Context, userID string, shortURL string) error {
+	_, err := s.updateLink(shortURL, func(_ *bolt.Tx, v *api.ShortenedData) error {
+		if v.UserID != userID {
+			return notFound(shortURL)
+		}
+		v.IsDeleted = true
+		return nil
+	})
+	return err
+}
+
+// UpdateByUserIDAndShort changes link owned by user and records previous destination
+func (s *BoltStorage) UpdateByUserIDAndShort(ctx context.Context, userID string, shortURL string, upd api.URLUpdate) (api.ShortenedData, error) {
+	var existing api.ShortenedData
+	updated, err := s.updateLink(shortURL, func(tx *bolt.Tx, v *api.ShortenedData) error {
+		if err := checkOwned(*v, userID); err != nil {
+			return err
+		}
+		previous := v.OriginalURL
+		if !upd.Apply(v) {
+			return nil
+		}
+		byOriginal := tx.Bucket(byOriginalBucket)
+		original := []byte(originalKey(v.Domain, v.OriginalURL))
+		if key := byOriginal.Get(original); key != nil && string(key) != shortURL {
+			var err error
+			if existing, err = getLink(tx, string(key)); err != nil {
+				return err
+			}
+			return &ErrURLExists{}
+		}
+		if err := byOriginal.Delete([]byte(originalKey(v.Domain, previous))); err != nil {
+			return err
+		}
+		if err := byOriginal.Put(original, []byte(shortURL)); err != nil {
+			return err
+		}
+		return appendHistory(tx, shortURL, api.URLHistoryEntry{
+			ShortURL:    v.ShortURL,
+			UserID:      userID,
+			OriginalURL: previous,
+			ReplacedBy:  v.OriginalURL,
+			ChangedAt:   time.Now().UTC(),
+		})
+	})
+	if errors.Is(err, &ErrURLExists{}) {
+		return existing, err
+	}
+	if err != nil {
+		return api.ShortenedData{}, err
+	}
+	return updated, nil
+}
+
+// checkOwned returns error for deleted link or link of another user
+func checkOwned(v api.ShortenedData, userID string) error {
+	if v.IsDeleted {
+		return notFound(v.Key())
+	}
+	if v.UserID != userID {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// historyRecord is stored form of history entry, user id is hidden from API
+// responses but must be kept by storage
+type historyRecord struct {
+	api.URLHistoryEntry
+	UserID string `json:"user_id"`
+}
+
+func getHistory(tx *bolt.Tx, shortURL string) ([]api.URLHistoryEntry, error) {
+	var records []historyRecord
+	if v := tx.Bucket(historyBucket).Get([]byte(shortURL)); v != nil {
+		if err := json.Unmarshal(v, &records); err != nil {
+			return nil, err
+		}
+	}
+	var history []api.URLHistoryEntry
+	for _, r := range records {
+		r.URLHistoryEntry.UserID = r.UserID
+		history = append(history, r.URLHistoryEntry)
+	}
+	return history, nil
+}
+
+func appendHistory(tx *bolt.Tx, shortURL string, entry api.URLHistoryEntry) error {
+	history, err := getHistory(tx, shortURL)
+	if err != nil {
+		return err
+	}
+	records := make([]historyRecord, 0, len(history)+1)
+	for _, e := range append(history, entry) {
+		records = append(records, historyRecord{URLHistoryEntry: e, UserID: e.UserID})
+	}
+	encoded, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(historyBucket).Put([]byte(shortURL), encoded)
+}
+
+// GetHistoryByUserIDAndShort returns previous destinations of link owned by user, oldest first
+func (s *BoltStorage) GetHistoryByUserIDAndShort(ctx context.Context, userID string, shortURL string) ([]api.URLHistoryEntry, error) {
+	var history []api.URLHistoryEntry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v, err := getLink(tx, shortURL)
+		if err != nil {
+			return err
+		}
+		if err = checkOwned(v, userID); err != nil {
+			return err
+		}
+		history, err = getHistory(tx, shortURL)
+		return err
+	})
+	return history, err
+}
+
+// AdminUpdate changes state or owner of any link
+func (s *BoltStorage) AdminUpdate(ctx context.Context, shortURL string, upd api.AdminUpdate) (api.ShortenedData, error) {
+	return s.updateLink(shortURL, func(tx *bolt.Tx, v *api.ShortenedData) error {
+		previousUser := v.UserID
+		upd.Apply(v)
+		if v.UserID == previousUser {
+			return nil
+		}
+		byUser := tx.Bucket(byUserBucket)
+		if err := byUser.Delete(userKey(previousUser, shortURL)); err != nil {
+			return err
+		}
+		return byUser.Put(userKey(v.UserID, shortURL), nil)
+	})
+}
+
+// DeleteByDomain marks deleted every link to domain or its subdomains and returns their number
+func (s *BoltStorage) DeleteByDomain(ctx context.Context, domain string) (int, error) {
+	domain = normalizeDomain(domain)
+	if domain == "" {
+		return 0, nil
+	}
+	deleted := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var matched []api.ShortenedData
+		err := tx.Bucket(linksBucket).ForEach(func(_, v []byte) error {
+			var data api.ShortenedData
+			if err := json.Unmarshal(v, &data); err != nil {
+				return err
+			}
+			if !data.IsDeleted && inDomain(linkHost(data.OriginalURL), domain) {
+				matched = append(matched, data)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// bucket is not changed while it is iterated
+		for _, data := range matched {
+			data.IsDeleted = true
+			if err := putLink(tx, data); err != nil {
+				return err
+			}
+		}
+		deleted = len(matched)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// GetStatistic returns statistic of links created in query window
+func (s *BoltStorage) GetStatistic(ctx context.Context, q api.StatisticQuery) (*api.Statistic, error) {
+	q, err := prepareStatisticQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	var links []api.ShortenedData
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(linksBucket).ForEach(func(_, v []byte) error {
+			var data api.ShortenedData
+			if err := json.Unmarshal(v, &data); err != nil {
+				return err
+			}
+			links = append(links, data)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return collectStatistic(links, q), nil
+}
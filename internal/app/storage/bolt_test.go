@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+)
+
+func TestBoltStorage(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.bolt")
+	s, err := NewBoltStorage(path)
+	require.NoError(t, err)
+
+	total := boltWalkBatch + 10
+	for i := 0; i < total; i++ {
+		user := "u1"
+		if i%2 == 1 {
+			user = "u2"
+		}
+		_, err = s.Store(ctx, api.ShortenedData{UserID: user, ShortURL: fmt.Sprintf("s%04d", i), OriginalURL: fmt.Sprintf("https://%d.example/", i)})
+		require.NoError(t, err)
+	}
+	_, err = s.Store(ctx, api.ShortenedData{UserID: "u3", ShortURL: "s0000", OriginalURL: "https://new.example/"})
+	assert.ErrorIs(t, err, ErrShortURLTaken)
+
+	owner := "u3"
+	_, err = s.AdminUpdate(ctx, "s0000", api.AdminUpdate{UserID: &owner})
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	s, err = NewBoltStorage(path)
+	require.NoError(t, err)
+	defer s.Close()
+	count := func(userID string) int {
+		n := 0
+		require.NoError(t, s.Walk(ctx, userID, func(api.ShortenedData) error {
+			n++
+			return nil
+		}))
+		return n
+	}
+	assert.Equal(t, total, count(""))
+	assert.Equal(t, total/2-1, count("u1"))
+	assert.Equal(t, 1, count("u3"))
+
+	page, err := s.ListByUserID(ctx, "u3", api.URLListQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "s0000", page.Items[0].ShortURL)
+}
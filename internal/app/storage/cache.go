@@ -0,0 +1,315 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+)
+
+// CacheEntry is cached result of Storage.Get, Missing marks unknown short url
+type CacheEntry struct {
+	Data    api.ShortenedData `json:"data"`
+	Missing bool              `json:"missing,omitempty"`
+}
+
+// Cache is external cache shared by service instances, e.g. Redis or memcached.
+// Its failures are not fatal, CachedStorage falls back to storage
+type Cache interface {
+	Get(ctx context.Context, key string) (CacheEntry, bool, error)
+	Set(ctx context.Context, key string, entry CacheEntry, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	// Purge drops every entry, used when links cannot be invalidated one by one
+	Purge(ctx context.Context) error
+}
+
+// CacheOptions configures CachedStorage
+type CacheOptions struct {
+	// Size is maximal number of links kept in memory
+	Size int
+	// TTL is lifetime of cached link
+	TTL time.Duration
+	// NegativeTTL is lifetime of cached unknown short url, 0 disables negative caching
+	NegativeTTL time.Duration
+	// External is optional second level cache
+	External Cache
+}
+
+// CacheStats counts cache lookups of CachedStorage
+type CacheStats struct {
+	Hits           uint64 `json:"hits"`
+	NegativeHits   uint64 `json:"negative_hits"`
+	Misses         uint64 `json:"misses"`
+	ExternalHits   uint64 `json:"external_hits"`
+	ExternalErrors uint64 `json:"external_errors"`
+	Evictions      uint64 `json:"evictions"`
+	Size           int    `json:"size"`
+}
+
+type cacheItem struct {
+	key     string
+	entry   CacheEntry
+	expires time.Time
+}
+
+// CachedStorage wraps Storage with read-through LRU cache of Get. Links are
+// invalidated by changes made through it, changes of other instances are seen
+// after TTL
+type CachedStorage struct {
+	next Storage
+	opts CacheOptions
+	now  func() time.Time
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	order *list.List
+
+	hits, negativeHits, misses   atomic.Uint64
+	externalHits, externalErrors atomic.Uint64
+	evictions                    atomic.Uint64
+}
+
+// NewCachedStorage return CachedStorage object
+func NewCachedStorage(next Storage, opts CacheOptions) *CachedStorage {
+	return &CachedStorage{
+		next:  next,
+		opts:  opts,
+		now:   time.Now,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// Unwrap returns wrapped storage
+func (s *CachedStorage) Unwrap() Storage {
+	return s.next
+}
+
+// Stats returns counters of cache lookups
+func (s *CachedStorage) Stats() CacheStats {
+	s.mu.Lock()
+	size := s.order.Len()
+	s.mu.Unlock()
+	return CacheStats{
+		Hits:           s.hits.Load(),
+		NegativeHits:   s.negativeHits.Load(),
+		Misses:         s.misses.Load(),
+		ExternalHits:   s.externalHits.Load(),
+		ExternalErrors: s.externalErrors.Load(),
+		Evictions:      s.evictions.Load(),
+		Size:           size,
+	}
+}
+
+func (s *CachedStorage) lookup(key string) (CacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[key]
+	if !ok {
+		return CacheEntry{}, false
+	}
+	item := el.Value.(*cacheItem)
+	if !s.now().Before(item.expires) {
+		s.order.Remove(el)
+		delete(s.items, key)
+		return CacheEntry{}, false
+	}
+	s.order.MoveToFront(el)
+	return item.entry, true
+}
+
+func (s *CachedStorage) put(key string, entry CacheEntry, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expires := s.now().Add(ttl)
+	if el, ok := s.items[key]; ok {
+		item := el.Value.(*cacheItem)
+		item.entry, item.expires = entry, expires
+		s.order.MoveToFront(el)
+		return
+	}
+	s.items[key] = s.order.PushFront(&cacheItem{key: key, entry: entry, expires: expires})
+	for s.order.Len() > s.opts.Size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.items, oldest.Value.(*cacheItem).key)
+		s.evictions.Add(1)
+	}
+}
+
+// invalidate drops cached link in memory and in external cache
+func (s *CachedStorage) invalidate(ctx context.Context, key string) {
+	s.mu.Lock()
+	if el, ok := s.items[key]; ok {
+		s.order.Remove(el)
+		delete(s.items, key)
+	}
+	s.mu.Unlock()
+	if s.opts.External != nil {
+		if err := s.opts.External.Delete(ctx, key); err != nil {
+			s.externalErrors.Add(1)
+		}
+	}
+}
+
+// purge drops every cached link
+func (s *CachedStorage) purge(ctx context.Context) {
+	s.mu.Lock()
+	s.items = make(map[string]*list.Element)
+	s.order.Init()
+	s.mu.Unlock()
+	if s.opts.External != nil {
+		if err := s.opts.External.Purge(ctx); err != nil {
+			s.externalErrors.Add(1)
+		}
+	}
+}
+
+func (s *CachedStorage) ttl(entry CacheEntry) time.Duration {
+	if entry.Missing {
+		return s.opts.NegativeTTL
+	}
+	return s.opts.TTL
+}
+
+func entryResult(key string, entry CacheEntry) (api.ShortenedData, error) {
+	if entry.Missing {
+		return api.ShortenedData{}, notFound(key)
+	}
+	return entry.Data, nil
+}
+
+// Get returns link from cache, external cache or storage
+func (s *CachedStorage) Get(ctx context.Context, key string) (api.ShortenedData, error) {
+	if entry, ok := s.lookup(key); ok {
+		if entry.Missing {
+			s.negativeHits.Add(1)
+		} else {
+			s.hits.Add(1)
+		}
+		return entryResult(key, entry)
+	}
+	if s.opts.External != nil {
+		entry, ok, err := s.opts.External.Get(ctx, key)
+		switch {
+		case err != nil:
+			s.externalErrors.Add(1)
+		case ok:
+			s.externalHits.Add(1)
+			s.put(key, entry, s.ttl(entry))
+			return entryResult(key, entry)
+		}
+	}
+	s.misses.Add(1)
+
+	data, err := s.next.Get(ctx, key)
+	var entry CacheEntry
+	switch {
+	case err == nil:
+		entry = CacheEntry{Data: data}
+	case errors.Is(err, ErrNotFound) && s.opts.NegativeTTL > 0:
+		entry = CacheEntry{Missing: true}
+	default:
+		return data, err
+	}
+	ttl := s.ttl(entry)
+	s.put(key, entry, ttl)
+	if s.opts.External != nil {
+		if err := s.opts.External.Set(ctx, key, entry, ttl); err != nil {
+			s.externalErrors.Add(1)
+		}
+	}
+	return entryResult(key, entry)
+}
+
+// Store saves link and drops negative cache entry of its short url
+func (s *CachedStorage) Store(ctx context.Context, data api.ShortenedData) (api.ShortenedData, error) {
+	stored, err := s.next.Store(ctx, data)
+	if err == nil {
+		s.invalidate(ctx, data.Key())
+	}
+	return stored, err
+}
+
+// Ping checks wrapped storage
+func (s *CachedStorage) Ping(ctx context.Context) error {
+	return s.next.Ping(ctx)
+}
+
+// Close closes wrapped storage
+func (s *CachedStorage) Close() error {
+	return s.next.Close()
+}
+
+// Walk calls fn for links of wrapped storage
+func (s *CachedStorage) Walk(ctx context.Context, userID string, fn func(api.ShortenedData) error) error {
+	return s.next.Walk(ctx, userID, fn)
+}
+
+// ListByUserID returns page of wrapped storage
+func (s *CachedStorage) ListByUserID(ctx context.Context, userID string, q api.URLListQuery) (api.URLListPage, error) {
+	return s.next.ListByUserID(ctx, userID, q)
+}
+
+// IncrementClicks counts redirect and updates counter of cached link in memory
+func (s *CachedStorage) IncrementClicks(ctx context.Context, shortURL string) error {
+	if err := s.next.IncrementClicks(ctx, shortURL); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if el, ok := s.items[shortURL]; ok {
+		el.Value.(*cacheItem).entry.Data.Clicks++
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// DeleteByUserIDAndShort deletes link and drops it from cache
+func (s *CachedStorage) DeleteByUserIDAndShort(ctx context.Context, userID string, shortURL string) error {
+	err := s.next.DeleteByUserIDAndShort(ctx, userID, shortURL)
+	if err == nil {
+		s.invalidate(ctx, shortURL)
+	}
+	return err
+}
+
+// UpdateByUserIDAndShort changes link and drops it from cache
+func (s *CachedStorage) UpdateByUserIDAndShort(ctx context.Context, userID string, shortURL string, upd api.URLUpdate) (api.ShortenedData, error) {
+	updated, err := s.next.UpdateByUserIDAndShort(ctx, userID, shortURL, upd)
+	if err == nil {
+		s.invalidate(ctx, shortURL)
+	}
+	return updated, err
+}
+
+// GetHistoryByUserIDAndShort returns history of wrapped storage
+func (s *CachedStorage) GetHistoryByUserIDAndShort(ctx context.Context, userID string, shortURL string) ([]api.URLHistoryEntry, error) {
+	return s.next.GetHistoryByUserIDAndShort(ctx, userID, shortURL)
+}
+
+// AdminUpdate changes link and drops it from cache
+func (s *CachedStorage) AdminUpdate(ctx context.Context, shortURL string, upd api.AdminUpdate) (api.ShortenedData, error) {
+	updated, err := s.next.AdminUpdate(ctx, shortURL, upd)
+	if err == nil {
+		s.invalidate(ctx, shortURL)
+	}
+	return updated, err
+}
+
+// DeleteByDomain deletes links to domain, affected short urls are unknown so whole cache is dropped
+func (s *CachedStorage) DeleteByDomain(ctx context.Context, domain string) (int, error) {
+	deleted, err := s.next.DeleteByDomain(ctx, domain)
+	if deleted > 0 {
+		s.purge(ctx)
+	}
+	return deleted, err
+}
+
+// GetStatistic returns statistic of wrapped storage
+func (s *CachedStorage) GetStatistic(ctx context.Context, q api.StatisticQuery) (*api.Statistic, error) {
+	return s.next.GetStatistic(ctx, q)
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+)
+
+// countingStorage counts Get calls reaching storage
+type countingStorage struct {
+	Storage
+	gets int
+}
+
+func (s *countingStorage) Get(ctx context.Context, key string) (api.ShortenedData, error) {
+	s.gets++
+	return s.Storage.Get(ctx, key)
+}
+
+func TestCachedStorage(t *testing.T) {
+	ctx := context.Background()
+	next := &countingStorage{Storage: NewInMemoryStorage()}
+	s := NewCachedStorage(next, CacheOptions{Size: 2, TTL: time.Minute, NegativeTTL: time.Second})
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	_, err := s.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 1, next.gets)
+
+	// storing drops negative entry
+	_, err = s.Store(ctx, api.ShortenedData{UserID: "u", ShortURL: "a", OriginalURL: "https://a.example/"})
+	require.NoError(t, err)
+	data, err := s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://a.example/", data.OriginalURL)
+	_, err = s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, 2, next.gets)
+
+	require.NoError(t, s.IncrementClicks(ctx, "a"))
+	data, _ = s.Get(ctx, "a")
+	assert.Equal(t, int64(1), data.Clicks)
+
+	require.NoError(t, s.DeleteByUserIDAndShort(ctx, "u", "a"))
+	data, err = s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, data.IsDeleted)
+	assert.Equal(t, 3, next.gets)
+
+	// least recently used entry is evicted
+	s.Get(ctx, "b")
+	s.Get(ctx, "c")
+	s.Get(ctx, "a")
+	assert.Equal(t, 6, next.gets)
+
+	now = now.Add(2 * time.Minute)
+	s.Get(ctx, "a")
+	assert.Equal(t, 7, next.gets)
+
+	stats := s.Stats()
+	assert.Equal(t, CacheStats{Hits: 2, NegativeHits: 1, Misses: 7, Evictions: 2, Size: 2}, stats)
+
+	cached, ok := Find[*CachedStorage](NewTracedStorage(s))
+	assert.True(t, ok)
+	assert.Same(t, s, cached)
+}
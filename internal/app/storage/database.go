@@ -3,9 +3,18 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	"github.com/gsk148/urlShorteningService/internal/app/api"
@@ -19,55 +28,169 @@ func (e *ErrURLExists) Error() string {
 	return "URL already exists"
 }
 
-// DBStorage structure of DBStorage
+// DBStorage structure of DBStorage. Writes go to primary DB, reads which
+// tolerate replication lag go to healthy replicas when they are configured
 type DBStorage struct {
-	DB     *sql.DB
-	logger zap.SugaredLogger
+	DB      *sql.DB
+	stmts   *statements
+	dialect dialect
+	logger  zap.SugaredLogger
+
+	replicas    []*replica
+	nextReplica atomic.Uint64
+	stop        chan struct{}
+	checks      sync.WaitGroup
 }
 
-// NewDBStorage return DBStorage object
-func NewDBStorage(dsn string, logger zap.SugaredLogger) (*DBStorage, error) {
-	db, err := sql.Open("postgres", dsn)
+// NewDBStorage return DBStorage object, unavailable database is retried for opts.ConnectTimeout
+func NewDBStorage(dsn string, opts DBOptions, logger zap.SugaredLogger) (*DBStorage, error) {
+	db, err := connect(dsn, opts, logger)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newDBStorage(db, postgresDialect, logger)
 	if err != nil {
 		return nil, err
 	}
+	if err = s.openReplicas(opts.Replicas, opts); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
 
-	_, err = db.Exec(`
-        CREATE TABLE IF NOT EXISTS shortener (
-            id SERIAL PRIMARY KEY,
-            user_id TEXT NOT NULL,
-            uuid TEXT NOT NULL,
-            short_url TEXT NOT NULL UNIQUE,
-            original_url TEXT NOT NULL,
-            is_deleted BOOLEAN
-        );
-		CREATE UNIQUE INDEX  IF NOT EXISTS shortener_original_url_uindex
-		    on public.shortener (original_url);
-    `)
+// newDBStorage migrates schema of opened database and prepares hot queries
+func newDBStorage(db *sql.DB, d dialect, logger zap.SugaredLogger) (*DBStorage, error) {
+	ctx := context.Background()
+	if err := migrate(ctx, db, d); err != nil {
+		db.Close()
+		return nil, err
+	}
+	stmts, err := prepareStatements(ctx, db)
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return &DBStorage{
-		DB:     db,
-		logger: logger,
+		DB:      db,
+		stmts:   stmts,
+		dialect: d,
+		logger:  logger,
 	}, nil
 }
 
+// PoolStats returns statistics of connection pool
+func (s *DBStorage) PoolStats() sql.DBStats {
+	return s.DB.Stats()
+}
+
+// startQuery opens a child span describing single SQL statement
+func (s *DBStorage) startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "sql",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(s.dialect.system, semconv.DBStatement(query)))
+}
+
 // Ping ping db
-func (s *DBStorage) Ping() error {
-	if err := s.DB.PingContext(context.Background()); err != nil {
+func (s *DBStorage) Ping(ctx context.Context) error {
+	if err := s.DB.PingContext(ctx); err != nil {
 		return err
 	}
 	return nil
 }
 
+// linkColumns are selected by scanLink
+const linkColumns = "uuid, user_id, domain, short_url, original_url, is_deleted, title, description, tags, disabled, created_at, expires_at, clicks, redirect_type, pass_query, utm"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanLink(row rowScanner) (api.ShortenedData, error) {
+	var (
+		data      api.ShortenedData
+		isDeleted sql.NullBool
+		tags      string
+		expiresAt sql.NullTime
+		utm       sql.NullString
+	)
+	err := row.Scan(&data.UUID, &data.UserID, &data.Domain, &data.ShortURL, &data.OriginalURL, &isDeleted,
+		&data.Title, &data.Description, &tags, &data.Disabled, &data.CreatedAt, &expiresAt, &data.Clicks,
+		&data.RedirectType, &data.PassQuery, &utm)
+	if err != nil {
+		return api.ShortenedData{}, err
+	}
+	data.IsDeleted = isDeleted.Bool
+	data.CreatedAt = data.CreatedAt.UTC()
+	if expiresAt.Valid {
+		t := expiresAt.Time.UTC()
+		data.ExpiresAt = &t
+	}
+	if err = json.Unmarshal([]byte(tags), &data.Tags); err != nil {
+		return api.ShortenedData{}, err
+	}
+	if utm.Valid {
+		if err = json.Unmarshal([]byte(utm.String), &data.UTM); err != nil {
+			return api.ShortenedData{}, err
+		}
+	}
+	return data, nil
+}
+
+func encodeTags(tags []string) string {
+	if tags == nil {
+		tags = []string{}
+	}
+	b, _ := json.Marshal(tags)
+	return string(b)
+}
+
+// encodeUTM converts UTM parameters to query argument, nil is stored as NULL
+func encodeUTM(utm *api.UTM) sql.NullString {
+	if utm == nil {
+		return sql.NullString{}
+	}
+	b, _ := json.Marshal(utm)
+	return sql.NullString{String: string(b), Valid: true}
+}
+
+// nullTime converts optional time to query argument, nil and zero time are stored as NULL
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil || t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// Unique constraints of shortener table, short urls and destinations are unique per domain
+const (
+	shortURLConstraint    = "shortener_domain_short_url_key"
+	originalURLConstraint = "shortener_domain_original_url_uindex"
+)
+
+// uniqueViolation returns name of violated unique constraint or empty string
+func uniqueViolation(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return pqErr.Constraint
+	}
+	return sqliteUniqueViolation(err)
+}
+
 // Store saves data to DB and return error if already exists and short url if not
-func (s *DBStorage) Store(data api.ShortenedData) (api.ShortenedData, error) {
-	result, err := s.DB.ExecContext(context.Background(),
-		"INSERT INTO shortener (uuid, user_id, short_url, original_url, is_deleted) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (original_url) DO NOTHING",
-		data.UUID, data.UserID, data.ShortURL, data.OriginalURL, data.IsDeleted)
+func (s *DBStorage) Store(ctx context.Context, data api.ShortenedData) (api.ShortenedData, error) {
+	qctx, span := s.startQuery(ctx, storeQuery)
+	result, err := s.stmts.store.ExecContext(qctx,
+		data.UUID, data.UserID, data.Domain, data.ShortURL, data.OriginalURL, data.IsDeleted,
+		data.Title, data.Description, encodeTags(data.Tags), data.Disabled,
+		nullTime(&data.CreatedAt), nullTime(data.ExpiresAt), data.Clicks,
+		data.RedirectType, data.PassQuery, encodeUTM(data.UTM))
+	span.End()
 	if err != nil {
+		if uniqueViolation(err) == shortURLConstraint {
+			return api.ShortenedData{}, ErrShortURLTaken
+		}
 		return api.ShortenedData{}, err
 	}
 
@@ -77,104 +200,415 @@ func (s *DBStorage) Store(data api.ShortenedData) (api.ShortenedData, error) {
 	}
 
 	if affectedRows == 0 {
-		row := s.DB.QueryRowContext(context.Background(),
-			"SELECT uuid, user_id, short_url, original_url FROM shortener WHERE original_url = $1", data.OriginalURL)
-		var existingData api.ShortenedData
-		err := row.Scan(&existingData.UUID, &existingData.UserID, &existingData.ShortURL, &existingData.OriginalURL)
-		if err != nil {
-			return api.ShortenedData{}, err
-		}
-		return existingData, &ErrURLExists{}
+		return s.existing(ctx, data.Domain, data.OriginalURL)
 	}
 
 	return data, nil
 }
 
-// Get returns full url by short url
-func (s *DBStorage) Get(key string) (api.ShortenedData, error) {
-	var (
-		uuid        string
-		userID      string
-		shortURL    string
-		originalURL string
-		isDeleted   bool
-	)
-
-	row := s.DB.QueryRowContext(context.Background(),
-		"SELECT uuid, user_id, short_url, original_url, is_deleted FROM shortener WHERE short_url = $1", key)
-	err := row.Scan(&uuid, &userID, &shortURL, &originalURL, &isDeleted)
+// Get returns full url by link key. Link missing on replica is looked up on primary,
+// it may be created just now and not replicated yet
+func (s *DBStorage) Get(ctx context.Context, key string) (api.ShortenedData, error) {
+	ctx, span := s.startQuery(ctx, getQuery)
+	defer span.End()
+	domain, short := api.SplitLinkKey(key)
+	var data api.ShortenedData
+	err := s.read(ctx, func(_ *sql.DB, get *sql.Stmt) (err error) {
+		data, err = scanLink(get.QueryRowContext(ctx, domain, short))
+		return err
+	})
+	if err == sql.ErrNoRows && len(s.replicas) > 0 {
+		data, err = scanLink(s.stmts.get.QueryRowContext(ctx, domain, short))
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return api.ShortenedData{}, errors.New("key not found: " + key)
-		} else {
+			return api.ShortenedData{}, notFound(key)
+		}
+		return api.ShortenedData{}, err
+	}
+	return data, nil
+}
+
+// UpdateByUserIDAndShort changes link owned by user in one transaction
+// and records previous destination in shortener_history
+func (s *DBStorage) UpdateByUserIDAndShort(ctx context.Context, userID string, shortURL string, upd api.URLUpdate) (api.ShortenedData, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return api.ShortenedData{}, err
+	}
+	defer tx.Rollback()
+
+	domain, short := api.SplitLinkKey(shortURL)
+	query := "SELECT " + linkColumns + " FROM shortener WHERE domain = $1 AND short_url = $2" + s.dialect.forUpdate
+	qctx, span := s.startQuery(ctx, query)
+	data, err := scanLink(tx.QueryRowContext(qctx, query, domain, short))
+	span.End()
+	switch {
+	case err == sql.ErrNoRows || (err == nil && data.IsDeleted):
+		return api.ShortenedData{}, notFound(shortURL)
+	case err != nil:
+		return api.ShortenedData{}, err
+	case data.UserID != userID:
+		return api.ShortenedData{}, ErrForbidden
+	}
+
+	previous := data.OriginalURL
+	retargeted := upd.Apply(&data)
+
+	query = "UPDATE shortener SET original_url=$1, title=$2, description=$3, tags=$4, disabled=$5, expires_at=$6 WHERE domain=$7 AND short_url=$8"
+	qctx, span = s.startQuery(ctx, query)
+	_, err = tx.ExecContext(qctx, query, data.OriginalURL, data.Title, data.Description, encodeTags(data.Tags), data.Disabled,
+		nullTime(data.ExpiresAt), domain, short)
+	span.End()
+	if err != nil {
+		if uniqueViolation(err) == originalURLConstraint {
+			return s.existing(ctx, domain, data.OriginalURL)
+		}
+		return api.ShortenedData{}, err
+	}
+
+	if retargeted {
+		query = "INSERT INTO shortener_history (domain, short_url, user_id, original_url, replaced_by) VALUES ($1, $2, $3, $4, $5)"
+		qctx, span = s.startQuery(ctx, query)
+		_, err = tx.ExecContext(qctx, query, domain, short, userID, previous, data.OriginalURL)
+		span.End()
+		if err != nil {
 			return api.ShortenedData{}, err
 		}
 	}
-	return api.ShortenedData{
-		UserID:      userID,
-		UUID:        uuid,
-		ShortURL:    shortURL,
-		OriginalURL: originalURL,
-		IsDeleted:   isDeleted,
-	}, nil
+	return data, tx.Commit()
+}
+
+// existing returns link of destination in domain with *ErrURLExists
+func (s *DBStorage) existing(ctx context.Context, domain string, originalURL string) (api.ShortenedData, error) {
+	ctx, span := s.startQuery(ctx, getByOriginalQuery)
+	defer span.End()
+	data, err := scanLink(s.stmts.getByOriginal.QueryRowContext(ctx, domain, originalURL))
+	if err != nil {
+		return api.ShortenedData{}, err
+	}
+	return data, &ErrURLExists{}
+}
+
+// GetHistoryByUserIDAndShort returns previous destinations of link owned by user, oldest first
+func (s *DBStorage) GetHistoryByUserIDAndShort(ctx context.Context, userID string, shortURL string) ([]api.URLHistoryEntry, error) {
+	data, err := s.Get(ctx, shortURL)
+	if err != nil {
+		return nil, err
+	}
+	if data.IsDeleted {
+		return nil, notFound(shortURL)
+	}
+	if data.UserID != userID {
+		return nil, ErrForbidden
+	}
+
+	query := "SELECT short_url, user_id, original_url, replaced_by, changed_at FROM shortener_history WHERE domain = $1 AND short_url = $2 ORDER BY id"
+	var history []api.URLHistoryEntry
+	err = s.read(ctx, func(db *sql.DB, _ *sql.Stmt) error {
+		history = nil
+		return s.queryRows(ctx, db, query, []any{data.Domain, data.ShortURL}, func(row rowScanner) error {
+			var e api.URLHistoryEntry
+			if err := row.Scan(&e.ShortURL, &e.UserID, &e.OriginalURL, &e.ReplacedBy, &e.ChangedAt); err != nil {
+				return err
+			}
+			history = append(history, e)
+			return nil
+		})
+	})
+	return history, err
 }
 
 // Close return nil if ok or error
 func (s *DBStorage) Close() error {
-	return s.DB.Close()
+	replicaErr := s.closeReplicas()
+	stmtErr := s.stmts.close()
+	if err := s.DB.Close(); err != nil {
+		return err
+	}
+	if stmtErr != nil {
+		return stmtErr
+	}
+	return replicaErr
 }
 
-// GetBatchByUserID returns batches of short urls by provided userID
-func (s *DBStorage) GetBatchByUserID(userID string) ([]api.ShortenedData, error) {
-	var (
-		entity api.ShortenedData
-		result []api.ShortenedData
-	)
-	query := "select short_url, original_url from shortener where user_id=$1"
-	rows, err := s.DB.Query(query, userID)
+// ListByUserID returns page of user links matching query using keyset pagination
+func (s *DBStorage) ListByUserID(ctx context.Context, userID string, q api.URLListQuery) (api.URLListPage, error) {
+	q, c, err := prepareListQuery(q)
 	if err != nil {
-		return nil, err
+		return api.URLListPage{}, err
 	}
-	if rows.Err() != nil {
-		return nil, rows.Err()
+
+	args := []any{userID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	where := []string{"user_id = $1"}
+	if q.Deleted != nil {
+		where = append(where, "COALESCE(is_deleted, false) = "+arg(*q.Deleted))
+	}
+	if q.Expired != nil {
+		if *q.Expired {
+			where = append(where, "expires_at <= now()")
+		} else {
+			where = append(where, "(expires_at IS NULL OR expires_at > now())")
+		}
+	}
+	if q.Tag != "" {
+		where = append(where, s.dialect.hasTag(arg(q.Tag)))
+	}
+	if q.Domain != "" {
+		where = append(where, fmt.Sprintf(`(%s = %s OR %s LIKE %s ESCAPE '\')`, s.dialect.hostExpr, arg(q.Domain), s.dialect.hostExpr, arg("%."+escapeLike(q.Domain))))
+	}
+	if q.Search != "" {
+		like := fmt.Sprintf(`%s %s ESCAPE '\'`, s.dialect.ilike, arg("%"+escapeLike(q.Search)+"%"))
+		where = append(where, fmt.Sprintf("(short_url %[1]s OR original_url %[1]s OR title %[1]s OR description %[1]s)", like))
 	}
 
-	for rows.Next() {
-		err = rows.Scan(&entity.ShortURL, &entity.OriginalURL)
+	countQuery := "SELECT count(*) FROM shortener WHERE " + strings.Join(where, " AND ")
+	countArgs := len(args)
+
+	column, dir, cmp := "created_at", "DESC", "<"
+	if q.Sort == api.SortClicks {
+		column = "clicks"
+	}
+	if q.Asc {
+		dir, cmp = "ASC", ">"
+	}
+	if c != nil {
+		var key any = c.CreatedAt.UTC()
+		if q.Sort == api.SortClicks {
+			key = c.Clicks
+		}
+		where = append(where, fmt.Sprintf("(%s, domain, short_url) %s (%s, %s, %s)", column, cmp, arg(key), arg(c.Domain), arg(c.ShortURL)))
+	}
+	query := fmt.Sprintf("SELECT %s FROM shortener WHERE %s ORDER BY %s %[4]s, domain %[4]s, short_url %[4]s LIMIT %d",
+		linkColumns, strings.Join(where, " AND "), column, dir, q.Limit+1)
+
+	var page api.URLListPage
+	err = s.read(ctx, func(db *sql.DB, _ *sql.Stmt) error {
+		page = api.URLListPage{}
+		cctx, span := s.startQuery(ctx, countQuery)
+		err := db.QueryRowContext(cctx, countQuery, args[:countArgs]...).Scan(&page.Total)
+		span.End()
 		if err != nil {
-			break
+			return err
 		}
-		result = append(result, entity)
+		return s.queryRows(ctx, db, query, args, func(row rowScanner) error {
+			data, err := scanLink(row)
+			if err != nil {
+				return err
+			}
+			page.Items = append(page.Items, data)
+			return nil
+		})
+	})
+	if err != nil {
+		return api.URLListPage{}, err
 	}
-	if len(result) == 0 {
-		return nil, errors.New("no batches by provided userID")
+	if len(page.Items) > q.Limit {
+		page.Items = page.Items[:q.Limit]
+		page.NextCursor = newCursor(q, page.Items[q.Limit-1])
 	}
-	return result, nil
+	return page, nil
+}
+
+// escapeLike escapes LIKE wildcards of user input
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// Walk calls fn for every link of user or for all links when userID is empty,
+// oldest first. Rows are streamed, so one connection is held until walk ends
+// and walk is not moved to primary when replica fails in the middle
+func (s *DBStorage) Walk(ctx context.Context, userID string, fn func(api.ShortenedData) error) error {
+	query := "SELECT " + linkColumns + " FROM shortener WHERE $1 = '' OR user_id = $1 ORDER BY created_at, domain, short_url"
+	return s.queryRows(ctx, s.reader(), query, []any{userID}, func(row rowScanner) error {
+		data, err := scanLink(row)
+		if err != nil {
+			return err
+		}
+		return fn(data)
+	})
+}
+
+// IncrementClicks counts redirect by short url
+func (s *DBStorage) IncrementClicks(ctx context.Context, shortURL string) error {
+	ctx, span := s.startQuery(ctx, incrementClicksQuery)
+	defer span.End()
+	domain, short := api.SplitLinkKey(shortURL)
+	res, err := s.stmts.incrementClicks.ExecContext(ctx, domain, short)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return notFound(shortURL)
+	}
+	return nil
 }
 
 // DeleteByUserIDAndShort delete full url from db by userID and short url
-func (s *DBStorage) DeleteByUserIDAndShort(userID string, short string) error {
-	query := "UPDATE shortener SET is_deleted=true WHERE user_id=$1 AND short_url=$2"
-	rows, err := s.DB.Exec(query, userID, short)
+func (s *DBStorage) DeleteByUserIDAndShort(ctx context.Context, userID string, short string) error {
+	ctx, span := s.startQuery(ctx, deleteQuery)
+	defer span.End()
+	domain, shortURL := api.SplitLinkKey(short)
+	rows, err := s.stmts.delete.ExecContext(ctx, userID, domain, shortURL)
 	if err != nil {
 		return err
 	}
-	if r, err := rows.RowsAffected(); err != nil || r == 0 {
-		s.logger.Info("0 rows affected in delete")
+	r, err := rows.RowsAffected()
+	if err != nil {
 		return err
 	}
+	if r == 0 {
+		return notFound(short)
+	}
 	s.logger.Infof("Marked as deleted link %s", short)
 	return nil
 }
 
-// GetStatistic - return num of saved urls and users
-func (s *DBStorage) GetStatistic() *api.Statistic {
-	var st api.Statistic
-	query := "SELECT count(DISTINCT user_id), count(*) FROM shortener"
-	res := s.DB.QueryRow(query)
-	err := res.Scan(&st.Users, &st.URLs)
+// AdminUpdate changes state or owner of any link
+func (s *DBStorage) AdminUpdate(ctx context.Context, shortURL string, upd api.AdminUpdate) (api.ShortenedData, error) {
+	query := "UPDATE shortener SET disabled = COALESCE($1, disabled), user_id = COALESCE($2, user_id) WHERE domain = $3 AND short_url = $4 RETURNING " + linkColumns
+	var disabled sql.NullBool
+	if upd.Active != nil {
+		disabled = sql.NullBool{Bool: !*upd.Active, Valid: true}
+	}
+	var userID sql.NullString
+	if upd.UserID != nil {
+		userID = sql.NullString{String: *upd.UserID, Valid: true}
+	}
+	ctx, span := s.startQuery(ctx, query)
+	defer span.End()
+	domain, short := api.SplitLinkKey(shortURL)
+	data, err := scanLink(s.DB.QueryRowContext(ctx, query, disabled, userID, domain, short))
+	if err == sql.ErrNoRows {
+		return api.ShortenedData{}, notFound(shortURL)
+	}
+	return data, err
+}
+
+// DeleteByDomain marks deleted every link to domain or its subdomains and returns their number
+func (s *DBStorage) DeleteByDomain(ctx context.Context, domain string) (int, error) {
+	domain = normalizeDomain(domain)
+	if domain == "" {
+		return 0, nil
+	}
+	query := fmt.Sprintf(`UPDATE shortener SET is_deleted = true WHERE NOT COALESCE(is_deleted, false) AND (%s = $1 OR %s LIKE $2 ESCAPE '\')`, s.dialect.hostExpr, s.dialect.hostExpr)
+	ctx, span := s.startQuery(ctx, query)
+	defer span.End()
+	res, err := s.DB.ExecContext(ctx, query, domain, "%."+escapeLike(domain))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// GetStatistic returns statistic of links created in query window
+func (s *DBStorage) GetStatistic(ctx context.Context, q api.StatisticQuery) (*api.Statistic, error) {
+	q, err := prepareStatisticQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		args  []any
+		where = []string{"true"}
+	)
+	if !q.From.IsZero() {
+		args = append(args, q.From.UTC())
+		where = append(where, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !q.To.IsZero() {
+		args = append(args, q.To.UTC())
+		where = append(where, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	window := strings.Join(where, " AND ")
+	top := fmt.Sprintf(" LIMIT %d", q.Top)
+
+	var st *api.Statistic
+	err = s.read(ctx, func(db *sql.DB, _ *sql.Stmt) (err error) {
+		st, err = s.statistic(ctx, db, window, top, args)
+		return err
+	})
+	return st, err
+}
+
+// statistic runs statistic queries on db for links in window
+func (s *DBStorage) statistic(ctx context.Context, db *sql.DB, window string, top string, args []any) (*api.Statistic, error) {
+	st := api.Statistic{
+		CreatedPerDay: []api.DayCount{},
+		TopDomains:    []api.DomainCount{},
+		TopLinks:      []api.LinkClicks{},
+	}
+	query := "SELECT count(*), count(DISTINCT user_id), count(*) FILTER (WHERE COALESCE(is_deleted, false)), COALESCE(sum(clicks), 0) FROM shortener WHERE " + window
+	qctx, span := s.startQuery(ctx, query)
+	err := db.QueryRowContext(qctx, query, args...).Scan(&st.URLs, &st.Users, &st.Deleted, &st.Redirects)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+	st.Active = st.URLs - st.Deleted
+
+	err = s.queryRows(ctx, db, "SELECT "+s.dialect.dayExpr+" AS day, count(*) FROM shortener WHERE "+window+" GROUP BY day ORDER BY day",
+		args, func(row rowScanner) error {
+			var c api.DayCount
+			if err := row.Scan(&c.Day, &c.Count); err != nil {
+				return err
+			}
+			st.CreatedPerDay = append(st.CreatedPerDay, c)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.queryRows(ctx, db, "SELECT "+s.dialect.hostExpr+" AS host, count(*) AS n FROM shortener WHERE "+window+" AND "+s.dialect.hostExpr+" IS NOT NULL GROUP BY host ORDER BY n DESC, host"+top,
+		args, func(row rowScanner) error {
+			var c api.DomainCount
+			if err := row.Scan(&c.Domain, &c.Count); err != nil {
+				return err
+			}
+			st.TopDomains = append(st.TopDomains, c)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.queryRows(ctx, db, "SELECT domain, short_url, original_url, clicks FROM shortener WHERE "+window+" AND clicks > 0 ORDER BY clicks DESC, domain, short_url"+top,
+		args, func(row rowScanner) error {
+			var (
+				c      api.LinkClicks
+				domain string
+			)
+			if err := row.Scan(&domain, &c.ShortURL, &c.OriginalURL, &c.Clicks); err != nil {
+				return err
+			}
+			c.ShortURL = api.LinkKey(domain, c.ShortURL)
+			st.TopLinks = append(st.TopLinks, c)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// queryRows runs query on db and calls scan for every row
+func (s *DBStorage) queryRows(ctx context.Context, db *sql.DB, query string, args []any, scan func(row rowScanner) error) error {
+	ctx, span := s.startQuery(ctx, query)
+	defer span.End()
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			return err
+		}
 	}
-	return &st
+	return rows.Err()
 }
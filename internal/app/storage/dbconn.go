@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// DBOptions configures connection pool of DBStorage
+type DBOptions struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// ConnectTimeout limits retries of unavailable database at startup, 0 tries once
+	ConnectTimeout time.Duration
+	// Replicas are DSNs of read replicas, they share pool options of primary
+	Replicas []string
+	// ReplicaCheckInterval is period of replica health checks
+	ReplicaCheckInterval time.Duration
+}
+
+// Backoff of connection retries at startup
+const (
+	connectBackoffMin = 200 * time.Millisecond
+	connectBackoffMax = 5 * time.Second
+)
+
+// Hot queries prepared once by NewDBStorage
+const (
+	getQuery             = "SELECT " + linkColumns + " FROM shortener WHERE domain = $1 AND short_url = $2"
+	getByOriginalQuery   = "SELECT " + linkColumns + " FROM shortener WHERE domain = $1 AND original_url = $2"
+	storeQuery           = "INSERT INTO shortener (uuid, user_id, domain, short_url, original_url, is_deleted, title, description, tags, disabled, created_at, expires_at, clicks, redirect_type, pass_query, utm) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, COALESCE($11, now()), $12, $13, $14, $15, $16) ON CONFLICT (domain, original_url) DO NOTHING"
+	deleteQuery          = "UPDATE shortener SET is_deleted=true WHERE user_id=$1 AND domain=$2 AND short_url=$3"
+	incrementClicksQuery = "UPDATE shortener SET clicks = clicks + 1 WHERE domain = $1 AND short_url = $2"
+)
+
+// statements are prepared hot queries, database/sql prepares them again on new connections
+type statements struct {
+	get             *sql.Stmt
+	getByOriginal   *sql.Stmt
+	store           *sql.Stmt
+	delete          *sql.Stmt
+	incrementClicks *sql.Stmt
+}
+
+func prepareStatements(ctx context.Context, db *sql.DB) (*statements, error) {
+	stmts := &statements{}
+	for _, p := range []struct {
+		dst   **sql.Stmt
+		query string
+	}{
+		{&stmts.get, getQuery},
+		{&stmts.getByOriginal, getByOriginalQuery},
+		{&stmts.store, storeQuery},
+		{&stmts.delete, deleteQuery},
+		{&stmts.incrementClicks, incrementClicksQuery},
+	} {
+		stmt, err := db.PrepareContext(ctx, p.query)
+		if err != nil {
+			stmts.close()
+			return nil, err
+		}
+		*p.dst = stmt
+	}
+	return stmts, nil
+}
+
+func (s *statements) close() error {
+	var firstErr error
+	for _, stmt := range []*sql.Stmt{s.get, s.getByOriginal, s.store, s.delete, s.incrementClicks} {
+		if stmt == nil {
+			continue
+		}
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// connect applies pool options and waits until database accepts connections,
+// transient errors are retried with exponential backoff until ConnectTimeout
+func connect(dsn string, opts DBOptions, logger zap.SugaredLogger) (*sql.DB, error) {
+	db, err := openPool(dsn, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(opts.ConnectTimeout)
+	backoff := connectBackoffMin
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline.Add(connectBackoffMax))
+		err = db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return db, nil
+		}
+		if !isTransient(err) || time.Now().Add(backoff).After(deadline) {
+			db.Close()
+			return nil, err
+		}
+		logger.Warnw("database is unavailable, retrying", "attempt", attempt, "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > connectBackoffMax {
+			backoff = connectBackoffMax
+		}
+	}
+}
+
+// openPool returns Postgres pool with options applied, connections are opened on first use
+func openPool(dsn string, opts DBOptions) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+	return db, nil
+}
+
+// isTransient reports whether connection error may go away on retry, e.g. database is starting
+func isTransient(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// connection exceptions, cannot_connect_now and too_many_connections
+		return pqErr.Code.Class() == "08" || pqErr.Code == "57P03" || pqErr.Code == "53300"
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, driver.ErrBadConn)
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, isTransient(&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}))
+	assert.True(t, isTransient(&pq.Error{Code: "57P03"}))
+	assert.True(t, isTransient(&pq.Error{Code: "08006"}))
+	assert.True(t, isTransient(fmt.Errorf("ping: %w", io.EOF)))
+	assert.False(t, isTransient(&pq.Error{Code: "28P01"}))
+	assert.False(t, isTransient(errors.New("pq: unknown option")))
+}
+
+func TestConnectRetries(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	dsn := "postgres://user:pass@" + addr + "/db?sslmode=disable"
+
+	start := time.Now()
+	_, err = connect(dsn, DBOptions{}, *zap.NewNop().Sugar())
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), connectBackoffMin)
+
+	start = time.Now()
+	_, err = connect(dsn, DBOptions{ConnectTimeout: 500 * time.Millisecond}, *zap.NewNop().Sugar())
+	require.Error(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), connectBackoffMin)
+}
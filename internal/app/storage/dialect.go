@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// dialect holds SQL of DBStorage which differs between database engines
+type dialect struct {
+	name string
+	// system is reported in spans of queries
+	system attribute.KeyValue
+	// migrationLock is executed in migration transaction before schema is read, empty skips it
+	migrationLock string
+	// forUpdate locks selected row until transaction ends
+	forUpdate string
+	// ilike is case insensitive LIKE operator
+	ilike string
+	// hostExpr extracts lower case destination host of link
+	hostExpr string
+	// dayExpr formats created_at as UTC day YYYY-MM-DD
+	dayExpr string
+	// hasTag returns condition matching links tagged with parameter p
+	hasTag func(p string) string
+}
+
+var postgresDialect = dialect{
+	name:          "postgres",
+	system:        semconv.DBSystemPostgreSQL,
+	migrationLock: "SELECT pg_advisory_xact_lock($1)",
+	forUpdate:     " FOR UPDATE",
+	ilike:         "ILIKE",
+	hostExpr:      `lower(substring(original_url from '^[^:]+://(?:[^@/]*@)?([^:/?#]+)'))`,
+	dayExpr:       "to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD')",
+	hasTag: func(p string) string {
+		return "tags::jsonb ? " + p
+	},
+}
+
+// sqliteDialect relies on url_host and now functions registered in sqlite.go.
+// Times are written in UTC in one text format, so they compare as strings
+var sqliteDialect = dialect{
+	name:     "sqlite",
+	system:   semconv.DBSystemSqlite,
+	ilike:    "LIKE",
+	hostExpr: "url_host(original_url)",
+	dayExpr:  "substr(created_at, 1, 10)",
+	hasTag: func(p string) string {
+		return "EXISTS (SELECT 1 FROM json_each(tags) WHERE value = " + p + ")"
+	},
+}
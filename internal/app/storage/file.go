@@ -2,9 +2,10 @@ package storage
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
-	"errors"
 	"os"
+	"sync"
 
 	"github.com/gsk148/urlShorteningService/internal/app/api"
 )
@@ -13,13 +14,21 @@ import (
 type FileStorage struct {
 	inMemoryData *InMemoryStorage
 	filePath     string
+
+	// saveMu serializes file rewrites
+	saveMu sync.Mutex
+}
+
+// fileRecord is line of storage file, history is stored next to link fields
+type fileRecord struct {
+	api.ShortenedData
+	History []api.URLHistoryEntry `json:"history,omitempty"`
 }
 
 // NewFileStorage return NewFileStorage object
 func NewFileStorage(filename string) (*FileStorage, error) {
-	inMemoryData := NewInMemoryStorage()
-	fs := FileStorage{
-		inMemoryData: inMemoryData,
+	fs := &FileStorage{
+		inMemoryData: NewInMemoryStorage(),
 		filePath:     filename,
 	}
 
@@ -28,10 +37,10 @@ func NewFileStorage(filename string) (*FileStorage, error) {
 		return nil, err
 	}
 
-	return &fs, nil
+	return fs, nil
 }
 
-func readFromFile(fs FileStorage) error {
+func readFromFile(fs *FileStorage) error {
 	file, err := os.OpenFile(fs.filePath, os.O_RDONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return err
@@ -39,52 +48,51 @@ func readFromFile(fs FileStorage) error {
 	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
 
 	for scanner.Scan() {
 		line := scanner.Bytes()
-
-		var sd api.ShortenedData
-		if err := json.Unmarshal(line, &sd); err != nil {
-			return err
+		if len(line) == 0 {
+			continue
 		}
 
-		_, err := fs.inMemoryData.Store(sd)
-		if err != nil {
+		var rec fileRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
 			return err
 		}
+		fs.inMemoryData.restore(rec.ShortenedData, rec.History)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return err
-	}
-	return nil
+	return scanner.Err()
 }
 
 // Store data and return error if already exists and short url if not
-func (s *FileStorage) Store(data api.ShortenedData) (api.ShortenedData, error) {
-	s.inMemoryData.data[data.ShortURL] = data
-	err := s.Save()
-	return api.ShortenedData{}, err
+func (s *FileStorage) Store(ctx context.Context, data api.ShortenedData) (api.ShortenedData, error) {
+	stored, err := s.inMemoryData.Store(ctx, data)
+	if err != nil {
+		return stored, err
+	}
+	return stored, s.Save()
 }
 
 // Get returns full url by short url
-func (s *FileStorage) Get(key string) (api.ShortenedData, error) {
-	data, exists := s.inMemoryData.data[key]
-	if !exists {
-		return api.ShortenedData{}, errors.New("key not found: " + key)
-	}
-	return data, nil
+func (s *FileStorage) Get(ctx context.Context, key string) (api.ShortenedData, error) {
+	return s.inMemoryData.Get(ctx, key)
 }
 
 // Save data to file storage
 func (s *FileStorage) Save() error {
-	file, err := os.OpenFile(s.filePath, os.O_WRONLY|os.O_CREATE, 0644)
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	tmpPath := s.filePath + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 	writer := bufio.NewWriter(file)
-	for _, v := range s.inMemoryData.data {
+	for _, v := range s.inMemoryData.snapshot() {
 		line, err := json.Marshal(v)
 		if err != nil {
 			return err
@@ -95,16 +103,22 @@ func (s *FileStorage) Save() error {
 			return err
 		}
 	}
-	err = writer.Flush()
-	if err != nil {
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
 		return err
 	}
-	return nil
+	return os.Rename(tmpPath, s.filePath)
 }
 
-// Ping return nil
-func (s *FileStorage) Ping() error {
-	return nil
+// Ping checks that storage file can be written
+func (s *FileStorage) Ping(ctx context.Context) error {
+	file, err := os.OpenFile(s.filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	return file.Close()
 }
 
 // Close return nil if ok or error
@@ -112,20 +126,63 @@ func (s *FileStorage) Close() error {
 	return s.Save()
 }
 
-// GetBatchByUserID returns batches of short urls by provided userID
-func (s *FileStorage) GetBatchByUserID(userID string) ([]api.ShortenedData, error) {
-	var data []api.ShortenedData
-	data = append(data, api.ShortenedData{})
+// ListByUserID returns page of user links matching query
+func (s *FileStorage) ListByUserID(ctx context.Context, userID string, q api.URLListQuery) (api.URLListPage, error) {
+	return s.inMemoryData.ListByUserID(ctx, userID, q)
+}
 
-	return data, nil
+// Walk calls fn for every link of user or for all links when userID is empty
+func (s *FileStorage) Walk(ctx context.Context, userID string, fn func(api.ShortenedData) error) error {
+	return s.inMemoryData.Walk(ctx, userID, fn)
 }
 
-// DeleteByUserIDAndShort return error
-func (s *FileStorage) DeleteByUserIDAndShort(userID string, shortURL string) error {
-	return errors.New("Error")
+// IncrementClicks counts redirect by short url. File is not rewritten on every
+// redirect, counters are saved with next change or on Close
+func (s *FileStorage) IncrementClicks(ctx context.Context, shortURL string) error {
+	return s.inMemoryData.IncrementClicks(ctx, shortURL)
+}
+
+// DeleteByUserIDAndShort marks short url of user as deleted and saves file
+func (s *FileStorage) DeleteByUserIDAndShort(ctx context.Context, userID string, shortURL string) error {
+	if err := s.inMemoryData.DeleteByUserIDAndShort(ctx, userID, shortURL); err != nil {
+		return err
+	}
+	return s.Save()
+}
+
+// UpdateByUserIDAndShort changes link owned by user and saves file
+func (s *FileStorage) UpdateByUserIDAndShort(ctx context.Context, userID string, shortURL string, upd api.URLUpdate) (api.ShortenedData, error) {
+	updated, err := s.inMemoryData.UpdateByUserIDAndShort(ctx, userID, shortURL, upd)
+	if err != nil {
+		return updated, err
+	}
+	return updated, s.Save()
+}
+
+// GetHistoryByUserIDAndShort returns previous destinations of link owned by user
+func (s *FileStorage) GetHistoryByUserIDAndShort(ctx context.Context, userID string, shortURL string) ([]api.URLHistoryEntry, error) {
+	return s.inMemoryData.GetHistoryByUserIDAndShort(ctx, userID, shortURL)
+}
+
+// AdminUpdate changes state or owner of any link and saves file
+func (s *FileStorage) AdminUpdate(ctx context.Context, shortURL string, upd api.AdminUpdate) (api.ShortenedData, error) {
+	updated, err := s.inMemoryData.AdminUpdate(ctx, shortURL, upd)
+	if err != nil {
+		return updated, err
+	}
+	return updated, s.Save()
+}
+
+// DeleteByDomain marks deleted every link to domain and saves file
+func (s *FileStorage) DeleteByDomain(ctx context.Context, domain string) (int, error) {
+	deleted, err := s.inMemoryData.DeleteByDomain(ctx, domain)
+	if err != nil || deleted == 0 {
+		return deleted, err
+	}
+	return deleted, s.Save()
 }
 
-// GetStatistic - returns num of saved urls and users
-func (s *FileStorage) GetStatistic() *api.Statistic {
-	return nil
+// GetStatistic returns statistic of links created in query window
+func (s *FileStorage) GetStatistic(ctx context.Context, q api.StatisticQuery) (*api.Statistic, error) {
+	return s.inMemoryData.GetStatistic(ctx, q)
 }
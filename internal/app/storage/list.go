@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+)
+
+const (
+	// DefaultListLimit is page size used when query has no limit
+	DefaultListLimit = 100
+	// MaxListLimit is the biggest allowed page size
+	MaxListLimit = 1000
+)
+
+// ErrInvalidQuery returned by ListByUserID and GetStatistic for unknown sort, bad limits,
+// empty window or malformed cursor
+var ErrInvalidQuery = errors.New("invalid query")
+
+// listCursor points to last link of previous page, sort key is stored so cursor
+// cannot be reused with another order
+type listCursor struct {
+	Sort      string    `json:"o"`
+	Asc       bool      `json:"a,omitempty"`
+	CreatedAt time.Time `json:"t,omitempty"`
+	Clicks    int64     `json:"c,omitempty"`
+	Domain    string    `json:"d,omitempty"`
+	ShortURL  string    `json:"s"`
+}
+
+func newCursor(q api.URLListQuery, last api.ShortenedData) string {
+	c := listCursor{Sort: q.Sort, Asc: q.Asc, Domain: last.Domain, ShortURL: last.ShortURL}
+	if q.Sort == api.SortClicks {
+		c.Clicks = last.Clicks
+	} else {
+		c.CreatedAt = last.CreatedAt
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// prepareListQuery fills defaults and decodes cursor, nil cursor means first page
+func prepareListQuery(q api.URLListQuery) (api.URLListQuery, *listCursor, error) {
+	switch {
+	case q.Sort == "":
+		q.Sort = api.SortCreated
+	case q.Sort != api.SortCreated && q.Sort != api.SortClicks:
+		return q, nil, fmt.Errorf("%w: unknown sort %q", ErrInvalidQuery, q.Sort)
+	}
+	switch {
+	case q.Limit == 0:
+		q.Limit = DefaultListLimit
+	case q.Limit < 0 || q.Limit > MaxListLimit:
+		return q, nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidQuery, MaxListLimit)
+	}
+	q.Domain = normalizeDomain(q.Domain)
+	if q.Cursor == "" {
+		return q, nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return q, nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	var c listCursor
+	if err = json.Unmarshal(b, &c); err != nil || c.ShortURL == "" {
+		return q, nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if c.Sort != q.Sort || c.Asc != q.Asc {
+		return q, nil, fmt.Errorf("%w: cursor belongs to another sort order", ErrInvalidQuery)
+	}
+	return q, &c, nil
+}
+
+// matchesQuery reports whether link passes filters of q
+func matchesQuery(d api.ShortenedData, q api.URLListQuery, now time.Time) bool {
+	if q.Deleted != nil && d.IsDeleted != *q.Deleted {
+		return false
+	}
+	if q.Expired != nil && d.Expired(now) != *q.Expired {
+		return false
+	}
+	if q.Tag != "" && !hasTag(d.Tags, q.Tag) {
+		return false
+	}
+	if q.Domain != "" {
+		if !inDomain(linkHost(d.OriginalURL), q.Domain) {
+			return false
+		}
+	}
+	if q.Search != "" {
+		search := strings.ToLower(q.Search)
+		found := false
+		for _, field := range []string{d.ShortURL, d.OriginalURL, d.Title, d.Description} {
+			if strings.Contains(strings.ToLower(field), search) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// linkHost returns lower case destination host or empty string
+func linkHost(originalURL string) string {
+	u, err := url.Parse(originalURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// inDomain reports whether host is domain or its subdomain
+func inDomain(host, domain string) bool {
+	return host != "" && (host == domain || strings.HasSuffix(host, "."+domain))
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// compareLinks orders links by sort key with domain and short url as tie breakers
+func compareLinks(sortBy string, a, b api.ShortenedData) int {
+	if sortBy == api.SortClicks {
+		if a.Clicks != b.Clicks {
+			if a.Clicks < b.Clicks {
+				return -1
+			}
+			return 1
+		}
+	} else if !a.CreatedAt.Equal(b.CreatedAt) {
+		if a.CreatedAt.Before(b.CreatedAt) {
+			return -1
+		}
+		return 1
+	}
+	if a.Domain != b.Domain {
+		return strings.Compare(a.Domain, b.Domain)
+	}
+	return strings.Compare(a.ShortURL, b.ShortURL)
+}
+
+// listLinks filters, sorts and pages links kept in memory
+func listLinks(links []api.ShortenedData, q api.URLListQuery, c *listCursor, now time.Time) api.URLListPage {
+	var matched []api.ShortenedData
+	for _, d := range links {
+		if matchesQuery(d, q, now) {
+			matched = append(matched, d)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		cmp := compareLinks(q.Sort, matched[i], matched[j])
+		if q.Asc {
+			return cmp < 0
+		}
+		return cmp > 0
+	})
+
+	page := api.URLListPage{Total: len(matched)}
+	start := 0
+	if c != nil {
+		last := api.ShortenedData{CreatedAt: c.CreatedAt, Clicks: c.Clicks, Domain: c.Domain, ShortURL: c.ShortURL}
+		start = sort.Search(len(matched), func(i int) bool {
+			cmp := compareLinks(q.Sort, last, matched[i])
+			if q.Asc {
+				return cmp < 0
+			}
+			return cmp > 0
+		})
+	}
+	end := start + q.Limit
+	if end < len(matched) {
+		page.NextCursor = newCursor(q, matched[end-1])
+	} else {
+		end = len(matched)
+	}
+	page.Items = append([]api.ShortenedData(nil), matched[start:end]...)
+	return page
+}
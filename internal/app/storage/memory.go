@@ -1,40 +1,60 @@
 package storage
 
 import (
-	"errors"
+	"context"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/gsk148/urlShorteningService/internal/app/api"
 )
 
 // InMemoryStorage structure of InMemoryStorage
 type InMemoryStorage struct {
-	data map[string]api.ShortenedData
+	mu         sync.RWMutex
+	data       map[string]api.ShortenedData
+	byOriginal map[string]string
+	history    map[string][]api.URLHistoryEntry
 }
 
 // NewInMemoryStorage return NewInMemoryStorage object
 func NewInMemoryStorage() *InMemoryStorage {
 	return &InMemoryStorage{
-		data: make(map[string]api.ShortenedData),
+		data:       make(map[string]api.ShortenedData),
+		byOriginal: make(map[string]string),
+		history:    make(map[string][]api.URLHistoryEntry),
 	}
 }
 
 // Store data and return error if already exists and short url if not
-func (s *InMemoryStorage) Store(data api.ShortenedData) (api.ShortenedData, error) {
-	s.data[data.ShortURL] = data
-	return api.ShortenedData{}, nil
+func (s *InMemoryStorage) Store(ctx context.Context, data api.ShortenedData) (api.ShortenedData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.byOriginal[originalKey(data.Domain, data.OriginalURL)]; ok {
+		return s.data[key], &ErrURLExists{}
+	}
+	key := data.Key()
+	if _, ok := s.data[key]; ok {
+		return api.ShortenedData{}, ErrShortURLTaken
+	}
+	s.data[key] = data
+	s.byOriginal[originalKey(data.Domain, data.OriginalURL)] = key
+	return data, nil
 }
 
 // Get returns full url by short url
-func (s *InMemoryStorage) Get(key string) (api.ShortenedData, error) {
+func (s *InMemoryStorage) Get(ctx context.Context, key string) (api.ShortenedData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	value, exists := s.data[key]
 	if !exists {
-		return api.ShortenedData{}, errors.New("key not found: " + key)
+		return api.ShortenedData{}, notFound(key)
 	}
 	return value, nil
 }
 
 // Ping return nil
-func (s *InMemoryStorage) Ping() error {
+func (s *InMemoryStorage) Ping(ctx context.Context) error {
 	return nil
 }
 
@@ -43,20 +63,190 @@ func (s *InMemoryStorage) Close() error {
 	return nil
 }
 
-// GetBatchByUserID returns batches of short urls by provided userID
-func (s *InMemoryStorage) GetBatchByUserID(userID string) ([]api.ShortenedData, error) {
+// ListByUserID returns page of user links matching query
+func (s *InMemoryStorage) ListByUserID(ctx context.Context, userID string, q api.URLListQuery) (api.URLListPage, error) {
+	q, c, err := prepareListQuery(q)
+	if err != nil {
+		return api.URLListPage{}, err
+	}
+	s.mu.RLock()
 	var data []api.ShortenedData
-	data = append(data, api.ShortenedData{})
+	for _, v := range s.data {
+		if v.UserID == userID {
+			data = append(data, v)
+		}
+	}
+	s.mu.RUnlock()
+	return listLinks(data, q, c, time.Now()), nil
+}
 
-	return data, nil
+// Walk calls fn for every link of user or for all links when userID is empty,
+// oldest first. Links are copied before walking, so fn may use storage
+func (s *InMemoryStorage) Walk(ctx context.Context, userID string, fn func(api.ShortenedData) error) error {
+	s.mu.RLock()
+	links := make([]api.ShortenedData, 0, len(s.data))
+	for _, v := range s.data {
+		if userID == "" || v.UserID == userID {
+			links = append(links, v)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(links, func(i, j int) bool { return compareLinks(api.SortCreated, links[i], links[j]) < 0 })
+	for _, v := range links {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// DeleteByUserIDAndShort return error
-func (s *InMemoryStorage) DeleteByUserIDAndShort(userID string, shortURL string) error {
-	return errors.New("Error")
+// IncrementClicks counts redirect by short url
+func (s *InMemoryStorage) IncrementClicks(ctx context.Context, shortURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[shortURL]
+	if !ok {
+		return notFound(shortURL)
+	}
+	v.Clicks++
+	s.data[shortURL] = v
+	return nil
 }
 
-// GetStatistic - return num of saved urls and users
-func (s *InMemoryStorage) GetStatistic() *api.Statistic {
+// DeleteByUserIDAndShort marks short url of user as deleted
+func (s *InMemoryStorage) DeleteByUserIDAndShort(ctx context.Context, userID string, shortURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[shortURL]
+	if !ok || v.UserID != userID {
+		return notFound(shortURL)
+	}
+	v.IsDeleted = true
+	s.data[shortURL] = v
 	return nil
 }
+
+// UpdateByUserIDAndShort changes link owned by user and records previous destination
+func (s *InMemoryStorage) UpdateByUserIDAndShort(ctx context.Context, userID string, shortURL string, upd api.URLUpdate) (api.ShortenedData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.owned(userID, shortURL)
+	if err != nil {
+		return api.ShortenedData{}, err
+	}
+
+	previous := v.OriginalURL
+	if !upd.Apply(&v) {
+		s.data[shortURL] = v
+		return v, nil
+	}
+	if key, ok := s.byOriginal[originalKey(v.Domain, v.OriginalURL)]; ok && key != shortURL {
+		return s.data[key], &ErrURLExists{}
+	}
+	delete(s.byOriginal, originalKey(v.Domain, previous))
+	s.byOriginal[originalKey(v.Domain, v.OriginalURL)] = shortURL
+	s.data[shortURL] = v
+	s.history[shortURL] = append(s.history[shortURL], api.URLHistoryEntry{
+		ShortURL:    v.ShortURL,
+		UserID:      userID,
+		OriginalURL: previous,
+		ReplacedBy:  v.OriginalURL,
+		ChangedAt:   time.Now().UTC(),
+	})
+	return v, nil
+}
+
+// GetHistoryByUserIDAndShort returns previous destinations of link owned by user, oldest first
+func (s *InMemoryStorage) GetHistoryByUserIDAndShort(ctx context.Context, userID string, shortURL string) ([]api.URLHistoryEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, err := s.owned(userID, shortURL); err != nil {
+		return nil, err
+	}
+	return append([]api.URLHistoryEntry(nil), s.history[shortURL]...), nil
+}
+
+// owned returns live link checking it belongs to user, callers hold the lock
+func (s *InMemoryStorage) owned(userID string, shortURL string) (api.ShortenedData, error) {
+	v, ok := s.data[shortURL]
+	if !ok || v.IsDeleted {
+		return api.ShortenedData{}, notFound(shortURL)
+	}
+	if v.UserID != userID {
+		return api.ShortenedData{}, ErrForbidden
+	}
+	return v, nil
+}
+
+// AdminUpdate changes state or owner of any link
+func (s *InMemoryStorage) AdminUpdate(ctx context.Context, shortURL string, upd api.AdminUpdate) (api.ShortenedData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[shortURL]
+	if !ok {
+		return api.ShortenedData{}, notFound(shortURL)
+	}
+	upd.Apply(&v)
+	s.data[shortURL] = v
+	return v, nil
+}
+
+// DeleteByDomain marks deleted every link to domain or its subdomains and returns their number
+func (s *InMemoryStorage) DeleteByDomain(ctx context.Context, domain string) (int, error) {
+	domain = normalizeDomain(domain)
+	if domain == "" {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted := 0
+	for short, v := range s.data {
+		if !v.IsDeleted && inDomain(linkHost(v.OriginalURL), domain) {
+			v.IsDeleted = true
+			s.data[short] = v
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// GetStatistic returns statistic of links created in query window
+func (s *InMemoryStorage) GetStatistic(ctx context.Context, q api.StatisticQuery) (*api.Statistic, error) {
+	q, err := prepareStatisticQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	links := make([]api.ShortenedData, 0, len(s.data))
+	for _, v := range s.data {
+		links = append(links, v)
+	}
+	s.mu.RUnlock()
+	return collectStatistic(links, q), nil
+}
+
+// restore puts records read from persistent storage back, keeping their history
+func (s *InMemoryStorage) restore(data api.ShortenedData, history []api.URLHistoryEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := data.Key()
+	s.data[key] = data
+	s.byOriginal[originalKey(data.Domain, data.OriginalURL)] = key
+	if len(history) > 0 {
+		s.history[key] = history
+	}
+}
+
+// snapshot returns copy of records with their history
+func (s *InMemoryStorage) snapshot() []fileRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := make([]fileRecord, 0, len(s.data))
+	for key, v := range s.data {
+		records = append(records, fileRecord{ShortenedData: v, History: s.history[key]})
+	}
+	return records
+}
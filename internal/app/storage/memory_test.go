@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+)
+
+// forEachBackend runs test against fresh storage of every embedded backend
+func forEachBackend(t *testing.T, test func(t *testing.T, s Storage)) {
+	backends := map[string]func(dir string) (Storage, error){
+		"memory": func(string) (Storage, error) { return NewInMemoryStorage(), nil },
+		"file":   func(dir string) (Storage, error) { return NewFileStorage(filepath.Join(dir, "links.json")) },
+		"bolt":   func(dir string) (Storage, error) { return NewBoltStorage(filepath.Join(dir, "links.bolt")) },
+		"sqlite": func(dir string) (Storage, error) {
+			return NewSQLiteStorage(filepath.Join(dir, "links.db"), *zap.NewNop().Sugar())
+		},
+	}
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			s, err := open(t.TempDir())
+			require.NoError(t, err)
+			defer s.Close()
+			test(t, s)
+		})
+	}
+}
+
+func TestListByUserID(t *testing.T) {
+	ctx := context.Background()
+	forEachBackend(t, func(t *testing.T, s Storage) {
+		created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		past := created.Add(-time.Hour)
+		links := []api.ShortenedData{
+			{ShortURL: "a", OriginalURL: "https://news.example.com/x", Tags: []string{"news"}, Clicks: 5},
+			{ShortURL: "b", OriginalURL: "https://example.com/y", Title: "Quarterly Report", Clicks: 1},
+			{ShortURL: "c", OriginalURL: "https://other.org/", ExpiresAt: &past, Clicks: 3},
+			{ShortURL: "d", OriginalURL: "https://other.org/deleted", IsDeleted: true},
+		}
+		for i, l := range links {
+			l.UserID = "owner"
+			l.CreatedAt = created.Add(time.Duration(i) * time.Minute)
+			_, err := s.Store(ctx, l)
+			require.NoError(t, err)
+		}
+		_, err := s.Store(ctx, api.ShortenedData{UserID: "stranger", ShortURL: "e", OriginalURL: "https://example.com/z"})
+		require.NoError(t, err)
+
+		shorts := func(page api.URLListPage) []string {
+			var res []string
+			for _, v := range page.Items {
+				res = append(res, v.ShortURL)
+			}
+			return res
+		}
+		yes, no := true, false
+
+		page, err := s.ListByUserID(ctx, "owner", api.URLListQuery{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"d", "c", "b", "a"}, shorts(page))
+		assert.Equal(t, 4, page.Total)
+
+		page, err = s.ListByUserID(ctx, "owner", api.URLListQuery{Sort: api.SortClicks, Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "c"}, shorts(page))
+		require.NotEmpty(t, page.NextCursor)
+		page, err = s.ListByUserID(ctx, "owner", api.URLListQuery{Sort: api.SortClicks, Limit: 2, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b", "d"}, shorts(page))
+		assert.Empty(t, page.NextCursor)
+
+		page, err = s.ListByUserID(ctx, "owner", api.URLListQuery{Domain: "Example.com", Asc: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, shorts(page))
+
+		page, err = s.ListByUserID(ctx, "owner", api.URLListQuery{Tag: "news"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a"}, shorts(page))
+
+		page, err = s.ListByUserID(ctx, "owner", api.URLListQuery{Search: "report"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b"}, shorts(page))
+
+		page, err = s.ListByUserID(ctx, "owner", api.URLListQuery{Expired: &yes})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c"}, shorts(page))
+
+		page, err = s.ListByUserID(ctx, "owner", api.URLListQuery{Deleted: &no, Expired: &no})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b", "a"}, shorts(page))
+
+		_, err = s.ListByUserID(ctx, "owner", api.URLListQuery{Sort: "title"})
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+		_, err = s.ListByUserID(ctx, "owner", api.URLListQuery{Limit: MaxListLimit + 1})
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+		_, err = s.ListByUserID(ctx, "owner", api.URLListQuery{Cursor: "garbage!"})
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+	})
+}
+
+func TestGetStatistic(t *testing.T) {
+	ctx := context.Background()
+	forEachBackend(t, func(t *testing.T, s Storage) {
+		day := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		links := []api.ShortenedData{
+			{UserID: "u1", ShortURL: "a", OriginalURL: "https://example.com/1", Clicks: 7, CreatedAt: day},
+			{UserID: "u1", ShortURL: "b", OriginalURL: "https://example.com/2", Clicks: 2, CreatedAt: day.Add(time.Hour)},
+			{UserID: "u2", ShortURL: "c", OriginalURL: "https://other.org/", IsDeleted: true, CreatedAt: day.AddDate(0, 0, 1)},
+			{UserID: "u3", ShortURL: "d", OriginalURL: "https://late.net/", Clicks: 100, CreatedAt: day.AddDate(0, 0, 5)},
+		}
+		for _, l := range links {
+			_, err := s.Store(ctx, l)
+			require.NoError(t, err)
+		}
+
+		st, err := s.GetStatistic(ctx, api.StatisticQuery{From: day.Add(-time.Hour), To: day.AddDate(0, 0, 2), Top: 1})
+		require.NoError(t, err)
+		assert.Equal(t, 3, st.URLs)
+		assert.Equal(t, 2, st.Users)
+		assert.Equal(t, 2, st.Active)
+		assert.Equal(t, 1, st.Deleted)
+		assert.Equal(t, int64(9), st.Redirects)
+		assert.Equal(t, []api.DayCount{{Day: "2024-01-01", Count: 2}, {Day: "2024-01-02", Count: 1}}, st.CreatedPerDay)
+		assert.Equal(t, []api.DomainCount{{Domain: "example.com", Count: 2}}, st.TopDomains)
+		assert.Equal(t, []api.LinkClicks{{ShortURL: "a", OriginalURL: "https://example.com/1", Clicks: 7}}, st.TopLinks)
+
+		st, err = s.GetStatistic(ctx, api.StatisticQuery{})
+		require.NoError(t, err)
+		assert.Equal(t, 4, st.URLs)
+		assert.Equal(t, "d", st.TopLinks[0].ShortURL)
+
+		_, err = s.GetStatistic(ctx, api.StatisticQuery{From: day, To: day})
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+	})
+}
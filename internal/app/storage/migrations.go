@@ -0,0 +1,291 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migration is single numbered schema change
+type migration struct {
+	version int
+	name    string
+	up      string
+	// sqlite is up for SQLite, which has no SERIAL, no ADD COLUMN IF NOT EXISTS
+	// and no expression defaults of added columns
+	sqlite string
+}
+
+// upSQL returns statements of migration in dialect d
+func (m migration) upSQL(d dialect) string {
+	if d.name == sqliteDialect.name {
+		return m.sqlite
+	}
+	return m.up
+}
+
+// migrations are applied in order, never edit released ones, add a new version instead
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create shortener",
+		up: `
+        CREATE TABLE IF NOT EXISTS shortener (
+            id SERIAL PRIMARY KEY,
+            user_id TEXT NOT NULL,
+            uuid TEXT NOT NULL,
+            short_url TEXT NOT NULL UNIQUE,
+            original_url TEXT NOT NULL,
+            is_deleted BOOLEAN
+        );
+        CREATE UNIQUE INDEX IF NOT EXISTS shortener_original_url_uindex
+            ON shortener (original_url);`,
+		sqlite: `
+        CREATE TABLE IF NOT EXISTS shortener (
+            id INTEGER PRIMARY KEY,
+            user_id TEXT NOT NULL,
+            uuid TEXT NOT NULL,
+            short_url TEXT NOT NULL UNIQUE,
+            original_url TEXT NOT NULL,
+            is_deleted BOOLEAN
+        );
+        CREATE UNIQUE INDEX IF NOT EXISTS shortener_original_url_uindex
+            ON shortener (original_url);`,
+	},
+	{
+		version: 2,
+		name:    "link metadata and history",
+		up: `
+        ALTER TABLE shortener
+            ADD COLUMN IF NOT EXISTS title TEXT NOT NULL DEFAULT '',
+            ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+            ADD COLUMN IF NOT EXISTS tags TEXT NOT NULL DEFAULT '[]',
+            ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT false;
+        CREATE TABLE IF NOT EXISTS shortener_history (
+            id SERIAL PRIMARY KEY,
+            short_url TEXT NOT NULL,
+            user_id TEXT NOT NULL,
+            original_url TEXT NOT NULL,
+            replaced_by TEXT NOT NULL,
+            changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+        );
+        CREATE INDEX IF NOT EXISTS shortener_history_short_url_index
+            ON shortener_history (short_url);`,
+		sqlite: `
+        ALTER TABLE shortener ADD COLUMN title TEXT NOT NULL DEFAULT '';
+        ALTER TABLE shortener ADD COLUMN description TEXT NOT NULL DEFAULT '';
+        ALTER TABLE shortener ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+        ALTER TABLE shortener ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT false;
+        CREATE TABLE IF NOT EXISTS shortener_history (
+            id INTEGER PRIMARY KEY,
+            short_url TEXT NOT NULL,
+            user_id TEXT NOT NULL,
+            original_url TEXT NOT NULL,
+            replaced_by TEXT NOT NULL,
+            changed_at TIMESTAMP NOT NULL DEFAULT (now())
+        );
+        CREATE INDEX IF NOT EXISTS shortener_history_short_url_index
+            ON shortener_history (short_url);`,
+	},
+	{
+		version: 3,
+		name:    "link timestamps and clicks",
+		up: `
+        ALTER TABLE shortener
+            ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+            ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ,
+            ADD COLUMN IF NOT EXISTS clicks BIGINT NOT NULL DEFAULT 0;
+        CREATE INDEX IF NOT EXISTS shortener_user_created_index
+            ON shortener (user_id, created_at, short_url);
+        CREATE INDEX IF NOT EXISTS shortener_user_clicks_index
+            ON shortener (user_id, clicks, short_url);`,
+		// created_at of existing rows is epoch, new rows always get it from Store
+		sqlite: `
+        ALTER TABLE shortener ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00+00:00';
+        ALTER TABLE shortener ADD COLUMN expires_at TIMESTAMP;
+        ALTER TABLE shortener ADD COLUMN clicks BIGINT NOT NULL DEFAULT 0;
+        CREATE INDEX IF NOT EXISTS shortener_user_created_index
+            ON shortener (user_id, created_at, short_url);
+        CREATE INDEX IF NOT EXISTS shortener_user_clicks_index
+            ON shortener (user_id, clicks, short_url);`,
+	},
+	{
+		version: 4,
+		name:    "short domains",
+		// existing links belong to primary domain ''
+		up: `
+        ALTER TABLE shortener ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+        ALTER TABLE shortener DROP CONSTRAINT IF EXISTS shortener_short_url_key;
+        DROP INDEX IF EXISTS shortener_original_url_uindex;
+        CREATE UNIQUE INDEX IF NOT EXISTS shortener_domain_short_url_key
+            ON shortener (domain, short_url);
+        CREATE UNIQUE INDEX IF NOT EXISTS shortener_domain_original_url_uindex
+            ON shortener (domain, original_url);
+        ALTER TABLE shortener_history ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+        DROP INDEX IF EXISTS shortener_history_short_url_index;
+        CREATE INDEX IF NOT EXISTS shortener_history_domain_short_url_index
+            ON shortener_history (domain, short_url);`,
+		// column constraint UNIQUE of short_url cannot be dropped, so table is rebuilt
+		sqlite: `
+        CREATE TABLE shortener_new (
+            id INTEGER PRIMARY KEY,
+            user_id TEXT NOT NULL,
+            uuid TEXT NOT NULL,
+            domain TEXT NOT NULL DEFAULT '',
+            short_url TEXT NOT NULL,
+            original_url TEXT NOT NULL,
+            is_deleted BOOLEAN,
+            title TEXT NOT NULL DEFAULT '',
+            description TEXT NOT NULL DEFAULT '',
+            tags TEXT NOT NULL DEFAULT '[]',
+            disabled BOOLEAN NOT NULL DEFAULT false,
+            created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00+00:00',
+            expires_at TIMESTAMP,
+            clicks BIGINT NOT NULL DEFAULT 0
+        );
+        INSERT INTO shortener_new (id, user_id, uuid, short_url, original_url, is_deleted,
+                title, description, tags, disabled, created_at, expires_at, clicks)
+            SELECT id, user_id, uuid, short_url, original_url, is_deleted,
+                title, description, tags, disabled, created_at, expires_at, clicks
+            FROM shortener;
+        DROP TABLE shortener;
+        ALTER TABLE shortener_new RENAME TO shortener;
+        CREATE UNIQUE INDEX shortener_domain_short_url_key
+            ON shortener (domain, short_url);
+        CREATE UNIQUE INDEX shortener_domain_original_url_uindex
+            ON shortener (domain, original_url);
+        CREATE INDEX shortener_user_created_index
+            ON shortener (user_id, created_at, short_url);
+        CREATE INDEX shortener_user_clicks_index
+            ON shortener (user_id, clicks, short_url);
+        ALTER TABLE shortener_history ADD COLUMN domain TEXT NOT NULL DEFAULT '';
+        DROP INDEX IF EXISTS shortener_history_short_url_index;
+        CREATE INDEX shortener_history_domain_short_url_index
+            ON shortener_history (domain, short_url);`,
+	},
+	{
+		version: 5,
+		name:    "redirect options",
+		up: `
+        ALTER TABLE shortener
+            ADD COLUMN IF NOT EXISTS redirect_type TEXT NOT NULL DEFAULT '',
+            ADD COLUMN IF NOT EXISTS pass_query BOOLEAN NOT NULL DEFAULT false,
+            ADD COLUMN IF NOT EXISTS utm TEXT;`,
+		sqlite: `
+        ALTER TABLE shortener ADD COLUMN redirect_type TEXT NOT NULL DEFAULT '';
+        ALTER TABLE shortener ADD COLUMN pass_query BOOLEAN NOT NULL DEFAULT false;
+        ALTER TABLE shortener ADD COLUMN utm TEXT;`,
+	},
+	{
+		version: 6,
+		name:    "list indexes with domain",
+		// same short url on two domains ties on short_url, list order breaks ties by domain first
+		up: `
+        DROP INDEX IF EXISTS shortener_user_created_index;
+        DROP INDEX IF EXISTS shortener_user_clicks_index;
+        CREATE INDEX shortener_user_created_index
+            ON shortener (user_id, created_at, domain, short_url);
+        CREATE INDEX shortener_user_clicks_index
+            ON shortener (user_id, clicks, domain, short_url);`,
+		sqlite: `
+        DROP INDEX IF EXISTS shortener_user_created_index;
+        DROP INDEX IF EXISTS shortener_user_clicks_index;
+        CREATE INDEX shortener_user_created_index
+            ON shortener (user_id, created_at, domain, short_url);
+        CREATE INDEX shortener_user_clicks_index
+            ON shortener (user_id, clicks, domain, short_url);`,
+	},
+}
+
+// migrationLockID is key of advisory lock serializing migrations of several instances
+const migrationLockID = 7245019
+
+// MigrationStatus describes schema version of database storage
+type MigrationStatus struct {
+	Current int `json:"current"`
+	Latest  int `json:"latest"`
+}
+
+// Pending reports whether some migrations are not applied yet
+func (m MigrationStatus) Pending() bool {
+	return m.Current < m.Latest
+}
+
+// MigrationReporter implemented by storages with versioned schema
+type MigrationReporter interface {
+	MigrationStatus(ctx context.Context) (MigrationStatus, error)
+}
+
+// Migrations returns migration status of s, ok is false when s has no versioned schema
+func Migrations(ctx context.Context, s Storage) (status MigrationStatus, ok bool, err error) {
+	r, ok := Find[MigrationReporter](s)
+	if !ok {
+		return MigrationStatus{}, false, nil
+	}
+	status, err = r.MigrationStatus(ctx)
+	return status, true, err
+}
+
+func latestMigration() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrate applies pending migrations in one transaction holding migration lock of dialect
+func migrate(ctx context.Context, db *sql.DB, d dialect) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if d.migrationLock != "" {
+		if _, err = tx.ExecContext(ctx, d.migrationLock, migrationLockID); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INTEGER PRIMARY KEY,
+            name TEXT NOT NULL,
+            applied_at TIMESTAMPTZ NOT NULL DEFAULT (now())
+        )`)
+	if err != nil {
+		return err
+	}
+	current, err := schemaVersion(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if _, err = tx.ExecContext(ctx, m.upSQL(d)); err != nil {
+			return fmt.Errorf("migration %d %q: %w", m.version, m.name, err)
+		}
+		if _, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.version, m.name); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func schemaVersion(ctx context.Context, q queryer) (int, error) {
+	var version int
+	err := q.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// MigrationStatus returns applied and latest known schema versions
+func (s *DBStorage) MigrationStatus(ctx context.Context) (MigrationStatus, error) {
+	current, err := schemaVersion(ctx, s.DB)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	return MigrationStatus{Current: current, Latest: latestMigration()}, nil
+}
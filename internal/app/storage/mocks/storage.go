@@ -5,6 +5,7 @@
 package mock_storage
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -34,6 +35,21 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// AdminUpdate mocks base method.
+func (m *MockStorage) AdminUpdate(ctx context.Context, shortURL string, upd api.AdminUpdate) (api.ShortenedData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminUpdate", ctx, shortURL, upd)
+	ret0, _ := ret[0].(api.ShortenedData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminUpdate indicates an expected call of AdminUpdate.
+func (mr *MockStorageMockRecorder) AdminUpdate(ctx, shortURL, upd interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminUpdate", reflect.TypeOf((*MockStorage)(nil).AdminUpdate), ctx, shortURL, upd)
+}
+
 // Close mocks base method.
 func (m *MockStorage) Close() error {
 	m.ctrl.T.Helper()
@@ -48,89 +64,163 @@ func (mr *MockStorageMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockStorage)(nil).Close))
 }
 
+// DeleteByDomain mocks base method.
+func (m *MockStorage) DeleteByDomain(ctx context.Context, domain string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteByDomain", ctx, domain)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteByDomain indicates an expected call of DeleteByDomain.
+func (mr *MockStorageMockRecorder) DeleteByDomain(ctx, domain interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByDomain", reflect.TypeOf((*MockStorage)(nil).DeleteByDomain), ctx, domain)
+}
+
 // DeleteByUserIDAndShort mocks base method.
-func (m *MockStorage) DeleteByUserIDAndShort(userID, shortURL string) error {
+func (m *MockStorage) DeleteByUserIDAndShort(ctx context.Context, userID, shortURL string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteByUserIDAndShort", userID, shortURL)
+	ret := m.ctrl.Call(m, "DeleteByUserIDAndShort", ctx, userID, shortURL)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteByUserIDAndShort indicates an expected call of DeleteByUserIDAndShort.
-func (mr *MockStorageMockRecorder) DeleteByUserIDAndShort(userID, shortURL interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) DeleteByUserIDAndShort(ctx, userID, shortURL interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByUserIDAndShort", reflect.TypeOf((*MockStorage)(nil).DeleteByUserIDAndShort), userID, shortURL)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByUserIDAndShort", reflect.TypeOf((*MockStorage)(nil).DeleteByUserIDAndShort), ctx, userID, shortURL)
 }
 
 // Get mocks base method.
-func (m *MockStorage) Get(key string) (api.ShortenedData, error) {
+func (m *MockStorage) Get(ctx context.Context, key string) (api.ShortenedData, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Get", key)
+	ret := m.ctrl.Call(m, "Get", ctx, key)
 	ret0, _ := ret[0].(api.ShortenedData)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Get indicates an expected call of Get.
-func (mr *MockStorageMockRecorder) Get(key interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) Get(ctx, key interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockStorage)(nil).Get), key)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockStorage)(nil).Get), ctx, key)
 }
 
-// GetBatchByUserID mocks base method.
-func (m *MockStorage) GetBatchByUserID(userID string) ([]api.ShortenedData, error) {
+// GetHistoryByUserIDAndShort mocks base method.
+func (m *MockStorage) GetHistoryByUserIDAndShort(ctx context.Context, userID, shortURL string) ([]api.URLHistoryEntry, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBatchByUserID", userID)
-	ret0, _ := ret[0].([]api.ShortenedData)
+	ret := m.ctrl.Call(m, "GetHistoryByUserIDAndShort", ctx, userID, shortURL)
+	ret0, _ := ret[0].([]api.URLHistoryEntry)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetBatchByUserID indicates an expected call of GetBatchByUserID.
-func (mr *MockStorageMockRecorder) GetBatchByUserID(userID interface{}) *gomock.Call {
+// GetHistoryByUserIDAndShort indicates an expected call of GetHistoryByUserIDAndShort.
+func (mr *MockStorageMockRecorder) GetHistoryByUserIDAndShort(ctx, userID, shortURL interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBatchByUserID", reflect.TypeOf((*MockStorage)(nil).GetBatchByUserID), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHistoryByUserIDAndShort", reflect.TypeOf((*MockStorage)(nil).GetHistoryByUserIDAndShort), ctx, userID, shortURL)
 }
 
 // GetStatistic mocks base method.
-func (m *MockStorage) GetStatistic() *api.Statistic {
+func (m *MockStorage) GetStatistic(ctx context.Context, q api.StatisticQuery) (*api.Statistic, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetStatistic")
+	ret := m.ctrl.Call(m, "GetStatistic", ctx, q)
 	ret0, _ := ret[0].(*api.Statistic)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetStatistic indicates an expected call of GetStatistic.
-func (mr *MockStorageMockRecorder) GetStatistic() *gomock.Call {
+func (mr *MockStorageMockRecorder) GetStatistic(ctx, q interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatistic", reflect.TypeOf((*MockStorage)(nil).GetStatistic))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatistic", reflect.TypeOf((*MockStorage)(nil).GetStatistic), ctx, q)
+}
+
+// IncrementClicks mocks base method.
+func (m *MockStorage) IncrementClicks(ctx context.Context, shortURL string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementClicks", ctx, shortURL)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrementClicks indicates an expected call of IncrementClicks.
+func (mr *MockStorageMockRecorder) IncrementClicks(ctx, shortURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementClicks", reflect.TypeOf((*MockStorage)(nil).IncrementClicks), ctx, shortURL)
+}
+
+// ListByUserID mocks base method.
+func (m *MockStorage) ListByUserID(ctx context.Context, userID string, q api.URLListQuery) (api.URLListPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListByUserID", ctx, userID, q)
+	ret0, _ := ret[0].(api.URLListPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListByUserID indicates an expected call of ListByUserID.
+func (mr *MockStorageMockRecorder) ListByUserID(ctx, userID, q interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByUserID", reflect.TypeOf((*MockStorage)(nil).ListByUserID), ctx, userID, q)
 }
 
 // Ping mocks base method.
-func (m *MockStorage) Ping() error {
+func (m *MockStorage) Ping(ctx context.Context) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Ping")
+	ret := m.ctrl.Call(m, "Ping", ctx)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Ping indicates an expected call of Ping.
-func (mr *MockStorageMockRecorder) Ping() *gomock.Call {
+func (mr *MockStorageMockRecorder) Ping(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStorage)(nil).Ping))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStorage)(nil).Ping), ctx)
 }
 
 // Store mocks base method.
-func (m *MockStorage) Store(data api.ShortenedData) (api.ShortenedData, error) {
+func (m *MockStorage) Store(ctx context.Context, data api.ShortenedData) (api.ShortenedData, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Store", data)
+	ret := m.ctrl.Call(m, "Store", ctx, data)
 	ret0, _ := ret[0].(api.ShortenedData)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Store indicates an expected call of Store.
-func (mr *MockStorageMockRecorder) Store(data interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) Store(ctx, data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Store", reflect.TypeOf((*MockStorage)(nil).Store), ctx, data)
+}
+
+// UpdateByUserIDAndShort mocks base method.
+func (m *MockStorage) UpdateByUserIDAndShort(ctx context.Context, userID, shortURL string, upd api.URLUpdate) (api.ShortenedData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateByUserIDAndShort", ctx, userID, shortURL, upd)
+	ret0, _ := ret[0].(api.ShortenedData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateByUserIDAndShort indicates an expected call of UpdateByUserIDAndShort.
+func (mr *MockStorageMockRecorder) UpdateByUserIDAndShort(ctx, userID, shortURL, upd interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateByUserIDAndShort", reflect.TypeOf((*MockStorage)(nil).UpdateByUserIDAndShort), ctx, userID, shortURL, upd)
+}
+
+// Walk mocks base method.
+func (m *MockStorage) Walk(ctx context.Context, userID string, fn func(api.ShortenedData) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Walk", ctx, userID, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Walk indicates an expected call of Walk.
+func (mr *MockStorageMockRecorder) Walk(ctx, userID, fn interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Store", reflect.TypeOf((*MockStorage)(nil).Store), data)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Walk", reflect.TypeOf((*MockStorage)(nil).Walk), ctx, userID, fn)
 }
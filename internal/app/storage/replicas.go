@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// replicaCheckTimeout limits single health check of replica
+const replicaCheckTimeout = 2 * time.Second
+
+// replica is read-only copy of primary database
+type replica struct {
+	name string
+	db   *sql.DB
+	// get is prepared by health check when replica is reachable first time
+	get     *sql.Stmt
+	healthy atomic.Bool
+}
+
+// ReplicaStatus describes state of read replica
+type ReplicaStatus struct {
+	Name    string      `json:"name"`
+	Healthy bool        `json:"healthy"`
+	Pool    sql.DBStats `json:"pool"`
+}
+
+// replicaName returns host of URL style dsn, DSN itself may contain password
+func replicaName(i int, dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return fmt.Sprintf("replica%d", i)
+}
+
+// openReplicas opens pools of replica DSNs, checks them once and starts health checks.
+// Unavailable replicas do not fail startup, reads go to primary until they recover
+func (s *DBStorage) openReplicas(dsns []string, opts DBOptions) error {
+	for i, dsn := range dsns {
+		db, err := openPool(dsn, opts)
+		if err != nil {
+			return err
+		}
+		s.addReplica(replicaName(i, dsn), db)
+	}
+	if len(s.replicas) == 0 {
+		return nil
+	}
+	s.checkReplicas(context.Background())
+	s.stop = make(chan struct{})
+	s.checks.Add(1)
+	go s.watchReplicas(opts.ReplicaCheckInterval)
+	return nil
+}
+
+func (s *DBStorage) addReplica(name string, db *sql.DB) {
+	s.replicas = append(s.replicas, &replica{name: name, db: db})
+}
+
+// watchReplicas checks replicas every interval until storage is closed
+func (s *DBStorage) watchReplicas(interval time.Duration) {
+	defer s.checks.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.checkReplicas(context.Background())
+		}
+	}
+}
+
+// checkReplicas pings every replica and marks it healthy or not, logging changes
+func (s *DBStorage) checkReplicas(ctx context.Context) {
+	for _, r := range s.replicas {
+		err := s.checkReplica(ctx, r)
+		healthy := err == nil
+		if r.healthy.Swap(healthy) == healthy {
+			continue
+		}
+		if healthy {
+			s.logger.Infow("replica is available", "replica", r.name)
+		} else {
+			s.logger.Warnw("replica is unavailable, reading from primary", "replica", r.name, "error", err)
+		}
+	}
+}
+
+func (s *DBStorage) checkReplica(ctx context.Context, r *replica) error {
+	ctx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+	defer cancel()
+	if err := r.db.PingContext(ctx); err != nil {
+		return err
+	}
+	if r.get == nil {
+		stmt, err := r.db.PrepareContext(ctx, getQuery)
+		if err != nil {
+			return err
+		}
+		r.get = stmt
+	}
+	return nil
+}
+
+// replica returns next healthy replica in round robin order or nil
+func (s *DBStorage) replica() *replica {
+	n := uint64(len(s.replicas))
+	if n == 0 {
+		return nil
+	}
+	start := s.nextReplica.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if r := s.replicas[(start+i)%n]; r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// read runs query fn on healthy replica. It is repeated on primary when there is
+// no such replica or replica fails with connection error, the replica is then
+// skipped until next successful health check
+func (s *DBStorage) read(ctx context.Context, fn func(db *sql.DB, get *sql.Stmt) error) error {
+	if r := s.replica(); r != nil {
+		err := fn(r.db, r.get)
+		if err == nil || !isTransient(err) || ctx.Err() != nil {
+			return err
+		}
+		if r.healthy.Swap(false) {
+			s.logger.Warnw("replica failed, reading from primary", "replica", r.name, "error", err)
+		}
+	}
+	return fn(s.DB, s.stmts.get)
+}
+
+// reader returns pool of healthy replica or of primary for reads which cannot be repeated
+func (s *DBStorage) reader() *sql.DB {
+	if r := s.replica(); r != nil {
+		return r.db
+	}
+	return s.DB
+}
+
+// ReplicaStats returns state of read replicas
+func (s *DBStorage) ReplicaStats() []ReplicaStatus {
+	stats := make([]ReplicaStatus, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		stats = append(stats, ReplicaStatus{Name: r.name, Healthy: r.healthy.Load(), Pool: r.db.Stats()})
+	}
+	return stats
+}
+
+// closeReplicas stops health checks and closes replica pools
+func (s *DBStorage) closeReplicas() error {
+	if s.stop != nil {
+		close(s.stop)
+		s.checks.Wait()
+	}
+	var firstErr error
+	for _, r := range s.replicas {
+		if r.get != nil {
+			r.get.Close()
+		}
+		if err := r.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+)
+
+func TestReplicas(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	// replica file gets only links written to it directly, as if replication lags
+	replicaPath := filepath.Join(dir, "replica.db")
+	r, err := NewSQLiteStorage(replicaPath, *zap.NewNop().Sugar())
+	require.NoError(t, err)
+	_, err = r.Store(ctx, api.ShortenedData{UserID: "u1", ShortURL: "old", OriginalURL: "https://old.example/"})
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	s, err := NewSQLiteStorage(filepath.Join(dir, "primary.db"), *zap.NewNop().Sugar())
+	require.NoError(t, err)
+	defer s.Close()
+	replicaDB, err := sql.Open("sqlite", replicaPath+sqliteParams)
+	require.NoError(t, err)
+	s.addReplica("replica", replicaDB)
+	s.checkReplicas(ctx)
+	assert.True(t, s.ReplicaStats()[0].Healthy)
+
+	_, err = s.Store(ctx, api.ShortenedData{UserID: "u1", ShortURL: "new", OriginalURL: "https://new.example/"})
+	require.NoError(t, err)
+	_, err = s.Get(ctx, "old")
+	assert.NoError(t, err, "read from replica")
+	_, err = s.Get(ctx, "new")
+	assert.NoError(t, err, "link missing on replica is read from primary")
+	page, err := s.ListByUserID(ctx, "u1", api.URLListQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "old", page.Items[0].ShortURL)
+
+	// connection error moves read to primary
+	err = s.read(ctx, func(db *sql.DB, _ *sql.Stmt) error {
+		if db == replicaDB {
+			return driver.ErrBadConn
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.False(t, s.ReplicaStats()[0].Healthy)
+	_, err = s.Get(ctx, "old")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	s.checkReplicas(ctx)
+	assert.True(t, s.ReplicaStats()[0].Healthy)
+	require.NoError(t, replicaDB.Close())
+	s.checkReplicas(ctx)
+	assert.False(t, s.ReplicaStats()[0].Healthy)
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteParams are applied to every connection: writers wait for lock instead of
+// failing, readers do not block writer, transactions take write lock at start
+// like SELECT FOR UPDATE of Postgres, times are written in sqliteTimeFormat
+const sqliteParams = "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate&_time_format=sqlite"
+
+// sqliteTimeFormat is format of times written by driver with _time_format=sqlite
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// sqliteConstraints maps columns of SQLite unique errors to Postgres constraint names
+var sqliteConstraints = map[string]string{
+	"shortener.domain, shortener.short_url":    shortURLConstraint,
+	"shortener.domain, shortener.original_url": originalURLConstraint,
+}
+
+var urlHost = regexp.MustCompile(`^[^:]+://(?:[^@/]*@)?([^:/?#]+)`)
+
+// functions used by queries shared with Postgres
+func init() {
+	sqlite.MustRegisterScalarFunction("now", 0, func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+		return time.Now().UTC().Format(sqliteTimeFormat), nil
+	})
+	sqlite.MustRegisterDeterministicScalarFunction("url_host", 1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		s, _ := args[0].(string)
+		m := urlHost.FindStringSubmatch(s)
+		if m == nil {
+			return nil, nil
+		}
+		return strings.ToLower(m[1]), nil
+	})
+}
+
+// NewSQLiteStorage return DBStorage object keeping links in SQLite database file
+func NewSQLiteStorage(path string, logger zap.SugaredLogger) (*DBStorage, error) {
+	db, err := sql.Open("sqlite", path+sqliteParams)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.PingContext(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return newDBStorage(db, sqliteDialect, logger)
+}
+
+// sqliteUniqueViolation returns Postgres name of unique constraint violated in SQLite
+func sqliteUniqueViolation(err error) string {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code() != sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return ""
+	}
+	for column, constraint := range sqliteConstraints {
+		if strings.Contains(sqliteErr.Error(), "UNIQUE constraint failed: "+column) {
+			return constraint
+		}
+	}
+	return ""
+}
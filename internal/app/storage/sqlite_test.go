@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+)
+
+func TestSQLiteStorage(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.db")
+	s, err := NewSQLiteStorage(path, *zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	created := time.Date(2024, 3, 1, 10, 0, 0, 500, time.FixedZone("X", 3600))
+	link, err := s.Store(ctx, api.ShortenedData{UUID: "1", UserID: "u1", ShortURL: "a", OriginalURL: "https://Sub.Example.com/x", CreatedAt: created})
+	require.NoError(t, err)
+	_, err = s.Store(ctx, api.ShortenedData{UUID: "2", UserID: "u2", ShortURL: "a", OriginalURL: "https://other.example/"})
+	assert.ErrorIs(t, err, ErrShortURLTaken)
+	existing, err := s.Store(ctx, api.ShortenedData{UUID: "3", UserID: "u2", ShortURL: "b", OriginalURL: link.OriginalURL})
+	var exists *ErrURLExists
+	assert.ErrorAs(t, err, &exists)
+	assert.Equal(t, "a", existing.ShortURL)
+
+	status, ok, err := Migrations(ctx, s)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, status.Pending())
+	require.NoError(t, s.Close())
+
+	// reopening does not apply migrations again
+	s, err = NewSQLiteStorage(path, *zap.NewNop().Sugar())
+	require.NoError(t, err)
+	defer s.Close()
+	got, err := s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, created.Equal(got.CreatedAt))
+
+	n, err := s.DeleteByDomain(ctx, "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestSQLiteDomainsMigration(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.db")
+	db, err := sql.Open("sqlite", path+sqliteParams)
+	require.NoError(t, err)
+	latest := migrations
+	migrations = migrations[:3]
+	err = migrate(ctx, db, sqliteDialect)
+	migrations = latest
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO shortener (uuid, user_id, short_url, original_url, clicks) VALUES ('1', 'u1', 'a', 'https://example.com/', 3)")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	s, err := NewSQLiteStorage(path, *zap.NewNop().Sugar())
+	require.NoError(t, err)
+	defer s.Close()
+	got, err := s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "", got.Domain)
+	assert.Equal(t, int64(3), got.Clicks)
+	_, err = s.Store(ctx, api.ShortenedData{UUID: "2", UserID: "u2", Domain: "go.example", ShortURL: "a", OriginalURL: "https://example.com/"})
+	require.NoError(t, err)
+	_, err = s.Store(ctx, api.ShortenedData{UUID: "3", UserID: "u2", ShortURL: "a", OriginalURL: "https://other.example/"})
+	assert.ErrorIs(t, err, ErrShortURLTaken)
+}
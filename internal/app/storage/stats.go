@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+)
+
+const (
+	// DefaultStatisticTop is size of top lists used when query has no limit
+	DefaultStatisticTop = 10
+	// MaxStatisticTop is the biggest allowed size of top lists
+	MaxStatisticTop = 100
+)
+
+// dayLayout formats UTC day of links created per day
+const dayLayout = "2006-01-02"
+
+// prepareStatisticQuery fills defaults and checks window
+func prepareStatisticQuery(q api.StatisticQuery) (api.StatisticQuery, error) {
+	switch {
+	case q.Top == 0:
+		q.Top = DefaultStatisticTop
+	case q.Top < 0 || q.Top > MaxStatisticTop:
+		return q, fmt.Errorf("%w: top must be between 1 and %d", ErrInvalidQuery, MaxStatisticTop)
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return q, fmt.Errorf("%w: from must be before to", ErrInvalidQuery)
+	}
+	return q, nil
+}
+
+// inWindow reports whether link was created in statistic window
+func inWindow(d api.ShortenedData, q api.StatisticQuery) bool {
+	if !q.From.IsZero() && d.CreatedAt.Before(q.From) {
+		return false
+	}
+	return q.To.IsZero() || d.CreatedAt.Before(q.To)
+}
+
+// collectStatistic counts statistic of links kept in memory
+func collectStatistic(links []api.ShortenedData, q api.StatisticQuery) *api.Statistic {
+	st := &api.Statistic{
+		CreatedPerDay: []api.DayCount{},
+		TopDomains:    []api.DomainCount{},
+		TopLinks:      []api.LinkClicks{},
+	}
+	users := map[string]bool{}
+	days := map[string]int{}
+	domains := map[string]int{}
+	for _, d := range links {
+		if !inWindow(d, q) {
+			continue
+		}
+		st.URLs++
+		users[d.UserID] = true
+		if d.IsDeleted {
+			st.Deleted++
+		}
+		st.Redirects += d.Clicks
+		days[d.CreatedAt.UTC().Format(dayLayout)]++
+		if host := linkHost(d.OriginalURL); host != "" {
+			domains[host]++
+		}
+		if d.Clicks > 0 {
+			st.TopLinks = append(st.TopLinks, api.LinkClicks{ShortURL: d.Key(), OriginalURL: d.OriginalURL, Clicks: d.Clicks})
+		}
+	}
+	st.Users = len(users)
+	st.Active = st.URLs - st.Deleted
+
+	for day, n := range days {
+		st.CreatedPerDay = append(st.CreatedPerDay, api.DayCount{Day: day, Count: n})
+	}
+	sort.Slice(st.CreatedPerDay, func(i, j int) bool { return st.CreatedPerDay[i].Day < st.CreatedPerDay[j].Day })
+
+	for domain, n := range domains {
+		st.TopDomains = append(st.TopDomains, api.DomainCount{Domain: domain, Count: n})
+	}
+	sort.Slice(st.TopDomains, func(i, j int) bool {
+		a, b := st.TopDomains[i], st.TopDomains[j]
+		return a.Count > b.Count || (a.Count == b.Count && a.Domain < b.Domain)
+	})
+	if len(st.TopDomains) > q.Top {
+		st.TopDomains = st.TopDomains[:q.Top]
+	}
+
+	sort.Slice(st.TopLinks, func(i, j int) bool {
+		a, b := st.TopLinks[i], st.TopLinks[j]
+		return a.Clicks > b.Clicks || (a.Clicks == b.Clicks && a.ShortURL < b.ShortURL)
+	})
+	if len(st.TopLinks) > q.Top {
+		st.TopLinks = st.TopLinks[:q.Top]
+	}
+	return st
+}
@@ -1,33 +1,153 @@
 package storage
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"go.uber.org/zap"
 
 	"github.com/gsk148/urlShorteningService/internal/app/api"
 	"github.com/gsk148/urlShorteningService/internal/app/config"
+	"github.com/gsk148/urlShorteningService/internal/app/hashutil"
+)
+
+var (
+	// ErrNotFound returned when short url does not exist or was deleted
+	ErrNotFound = errors.New("key not found")
+	// ErrForbidden returned when user changes link owned by someone else
+	ErrForbidden = errors.New("link belongs to another user")
+	// ErrShortURLTaken returned by Store when short url is used for another destination
+	ErrShortURLTaken = errors.New("short url is taken")
 )
 
-// Storage interface with included needed methods
+// maxShortenAttempts limits short url regeneration on collisions
+const maxShortenAttempts = 5
+
+func notFound(key string) error {
+	return fmt.Errorf("%w: %s", ErrNotFound, key)
+}
+
+// originalKey identifies destination within short domain, destinations of primary domain are kept as is
+func originalKey(domain string, originalURL string) string {
+	if domain == "" {
+		return originalURL
+	}
+	return domain + "\x00" + originalURL
+}
+
+// Storage interface with included needed methods. Links are identified by key
+// made by api.LinkKey, it is short url itself on primary domain. Short urls and
+// destinations are unique within domain. Walk visits links oldest first, links
+// created at the same time are ordered by domain and short url
 type Storage interface {
-	Store(data api.ShortenedData) (api.ShortenedData, error)
-	Get(key string) (api.ShortenedData, error)
-	Ping() error
+	Store(ctx context.Context, data api.ShortenedData) (api.ShortenedData, error)
+	Get(ctx context.Context, key string) (api.ShortenedData, error)
+	Ping(ctx context.Context) error
 	Close() error
-	GetBatchByUserID(userID string) ([]api.ShortenedData, error)
-	DeleteByUserIDAndShort(userID string, shortURL string) error
-	GetStatistic() *api.Statistic
+	Walk(ctx context.Context, userID string, fn func(api.ShortenedData) error) error
+	ListByUserID(ctx context.Context, userID string, q api.URLListQuery) (api.URLListPage, error)
+	IncrementClicks(ctx context.Context, shortURL string) error
+	DeleteByUserIDAndShort(ctx context.Context, userID string, shortURL string) error
+	UpdateByUserIDAndShort(ctx context.Context, userID string, shortURL string, upd api.URLUpdate) (api.ShortenedData, error)
+	GetHistoryByUserIDAndShort(ctx context.Context, userID string, shortURL string) ([]api.URLHistoryEntry, error)
+	AdminUpdate(ctx context.Context, shortURL string, upd api.AdminUpdate) (api.ShortenedData, error)
+	DeleteByDomain(ctx context.Context, domain string) (int, error)
+	GetStatistic(ctx context.Context, q api.StatisticQuery) (*api.Statistic, error)
+}
+
+// Find returns the first storage of decorator chain starting at s that is T
+func Find[T any](s Storage) (T, bool) {
+	for {
+		if t, ok := any(s).(T); ok {
+			return t, true
+		}
+		u, ok := s.(interface{ Unwrap() Storage })
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		s = u.Unwrap()
+	}
+}
+
+// Shorten generates short url for data.OriginalURL and stores it. Short url is derived
+// from destination, so when it is taken by retargeted link another one is tried.
+// Existing destination is returned with *ErrURLExists.
+func Shorten(ctx context.Context, s Storage, data api.ShortenedData) (api.ShortenedData, error) {
+	if data.CreatedAt.IsZero() {
+		data.CreatedAt = time.Now().UTC()
+	}
+	for attempt := 0; attempt < maxShortenAttempts; attempt++ {
+		data.ShortURL = hashutil.EncodeAttempt([]byte(data.OriginalURL), attempt)
+		stored, err := s.Store(ctx, data)
+		if errors.Is(err, ErrShortURLTaken) {
+			continue
+		}
+		return stored, err
+	}
+	return api.ShortenedData{}, ErrShortURLTaken
 }
 
-// NewStorage return NewStorage object
+// NewStorage return NewStorage object wrapped with tracing
 func NewStorage(cfg config.Config, logger zap.SugaredLogger) (Storage, error) {
+	var (
+		s   Storage
+		err error
+	)
 	switch cfg.StorageType {
 	case "memory":
-		return NewInMemoryStorage(), nil
+		s = NewInMemoryStorage()
 	case "file":
-		return NewFileStorage(cfg.FileStoragePath)
+		s, err = NewFileStorage(cfg.FileStoragePath)
+	case "bolt":
+		s, err = NewBoltStorage(cfg.BoltPath)
+	case "sqlite":
+		s, err = NewSQLiteStorage(cfg.SQLitePath, logger)
 	case "db":
-		return NewDBStorage(cfg.DatabaseDSN, logger)
+		s, err = NewDBStorage(cfg.DatabaseDSN, DBOptions{
+			MaxOpenConns:         cfg.DBMaxOpenConns,
+			MaxIdleConns:         cfg.DBMaxIdleConns,
+			ConnMaxLifetime:      cfg.DBConnMaxLifetime,
+			ConnMaxIdleTime:      cfg.DBConnMaxIdleTime,
+			ConnectTimeout:       cfg.DBConnectTimeout,
+			Replicas:             cfg.DBReplicas,
+			ReplicaCheckInterval: cfg.DBReplicaInterval,
+		}, logger)
 	default:
-		return NewInMemoryStorage(), nil
+		s = NewInMemoryStorage()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.CacheSize > 0 {
+		s = NewCachedStorage(s, CacheOptions{Size: cfg.CacheSize, TTL: cfg.CacheTTL, NegativeTTL: cfg.CacheNegativeTTL})
+	}
+	return NewTracedStorage(s), nil
+}
+
+// Open returns storage described by spec: "memory", "file:<path>", "bolt:<path>",
+// "sqlite:<path>" or "db:<dsn>"
+func Open(spec string, logger zap.SugaredLogger) (Storage, error) {
+	kind, location, _ := strings.Cut(spec, ":")
+	cfg := config.Config{StorageType: kind}
+	switch kind {
+	case "memory":
+	case "file":
+		cfg.FileStoragePath = location
+	case "bolt":
+		cfg.BoltPath = location
+	case "sqlite":
+		cfg.SQLitePath = location
+	case "db":
+		cfg.DatabaseDSN = location
+	default:
+		return nil, fmt.Errorf("unknown storage %q: must be memory, file:<path>, bolt:<path>, sqlite:<path> or db:<dsn>", spec)
+	}
+	if kind != "memory" && location == "" {
+		return nil, fmt.Errorf("storage %q: location is required", spec)
 	}
+	return NewStorage(cfg, logger)
 }
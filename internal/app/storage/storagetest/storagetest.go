@@ -0,0 +1,484 @@
+// Package storagetest is conformance test suite every storage.Storage implementation must pass
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+)
+
+// Opener returns new empty storage for single test. Persistent backends also
+// return reopen, which opens the same data again after storage is closed,
+// it is nil for backends losing data on Close
+type Opener func(t *testing.T) (s storage.Storage, reopen func() (storage.Storage, error))
+
+// concurrency is number of goroutines used by concurrent tests
+const concurrency = 20
+
+// created is creation time of fixture links, stored without sub-second part
+// because backends keep different time precision
+var created = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+// Run runs conformance suite against storages returned by open
+func Run(t *testing.T, open Opener) {
+	for _, tc := range []struct {
+		name string
+		test func(t *testing.T, s storage.Storage, reopen func() (storage.Storage, error))
+	}{
+		{"StoreGet", testStoreGet},
+		{"Conflicts", testConflicts},
+		{"ListByUser", testListByUser},
+		{"Delete", testDelete},
+		{"Update", testUpdate},
+		{"AdminUpdate", testAdminUpdate},
+		{"Domains", testDomains},
+		{"Statistic", testStatistic},
+		{"Concurrency", testConcurrency},
+		{"Close", testClose},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, reopen := open(t)
+			tc.test(t, s, reopen)
+		})
+	}
+}
+
+func link(user, short string, n int) api.ShortenedData {
+	return api.ShortenedData{
+		UUID:        fmt.Sprintf("uuid-%s", short),
+		UserID:      user,
+		ShortURL:    short,
+		OriginalURL: fmt.Sprintf("https://%s.example/%d", user, n),
+		Tags:        []string{"t"},
+		CreatedAt:   created.Add(time.Duration(n) * time.Minute),
+	}
+}
+
+func store(t *testing.T, s storage.Storage, links ...api.ShortenedData) {
+	t.Helper()
+	for _, l := range links {
+		_, err := s.Store(context.Background(), l)
+		require.NoError(t, err, l.ShortURL)
+	}
+}
+
+func testStoreGet(t *testing.T, s storage.Storage, _ func() (storage.Storage, error)) {
+	ctx := context.Background()
+	defer s.Close()
+	require.NoError(t, s.Ping(ctx))
+
+	want := link("u1", "a", 1)
+	want.Title, want.Description = "Title", "Description"
+	want.RedirectType, want.PassQuery = api.RedirectPermanent, true
+	want.UTM = &api.UTM{Source: "news", Campaign: "spring"}
+	expires := created.Add(24 * time.Hour)
+	want.ExpiresAt = &expires
+	stored, err := s.Store(ctx, want)
+	require.NoError(t, err)
+	assert.Equal(t, want, stored)
+
+	got, err := s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	_, err = s.Get(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.ErrorIs(t, s.IncrementClicks(ctx, "missing"), storage.ErrNotFound)
+}
+
+func testConflicts(t *testing.T, s storage.Storage, _ func() (storage.Storage, error)) {
+	ctx := context.Background()
+	defer s.Close()
+	first := link("u1", "a", 1)
+	store(t, s, first)
+
+	taken := link("u2", "a", 2)
+	_, err := s.Store(ctx, taken)
+	assert.ErrorIs(t, err, storage.ErrShortURLTaken)
+
+	duplicate := link("u2", "b", 1)
+	duplicate.OriginalURL = first.OriginalURL
+	existing, err := s.Store(ctx, duplicate)
+	var exists *storage.ErrURLExists
+	require.ErrorAs(t, err, &exists)
+	assert.Equal(t, first, existing)
+	_, err = s.Get(ctx, "b")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	shortened, err := storage.Shorten(ctx, s, api.ShortenedData{UserID: "u2", OriginalURL: "https://new.example/"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, shortened.ShortURL)
+	got, err := s.Get(ctx, shortened.ShortURL)
+	require.NoError(t, err)
+	assert.Equal(t, "https://new.example/", got.OriginalURL)
+}
+
+func testListByUser(t *testing.T, s storage.Storage, _ func() (storage.Storage, error)) {
+	ctx := context.Background()
+	defer s.Close()
+	// stored out of creation order, so Walk order can not come from insertion or keys
+	for _, i := range []int{4, 2, 0, 3, 1} {
+		store(t, s, link("u1", fmt.Sprintf("a%d", i), i))
+	}
+	store(t, s, link("u2", "b0", 10))
+	tied := link("u3", "c0", 21)
+	tied.CreatedAt = created.Add(20 * time.Minute)
+	store(t, s, link("u3", "0c", 30), link("u3", "c1", 20), tied)
+
+	var shorts []string
+	cursor := ""
+	for {
+		page, err := s.ListByUserID(ctx, "u1", api.URLListQuery{Limit: 2, Cursor: cursor})
+		require.NoError(t, err)
+		assert.Equal(t, 5, page.Total)
+		for _, item := range page.Items {
+			assert.Equal(t, "u1", item.UserID)
+			shorts = append(shorts, item.ShortURL)
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"a4", "a3", "a2", "a1", "a0"}, shorts)
+
+	page, err := s.ListByUserID(ctx, "nobody", api.URLListQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+	assert.Zero(t, page.Total)
+
+	walked := map[string][]string{}
+	for _, user := range []string{"", "u1", "u2", "u3"} {
+		require.NoError(t, s.Walk(ctx, user, func(data api.ShortenedData) error {
+			walked[user] = append(walked[user], data.ShortURL)
+			return nil
+		}))
+	}
+	assert.Equal(t, []string{"a0", "a1", "a2", "a3", "a4", "b0", "c0", "c1", "0c"}, walked[""])
+	assert.Equal(t, []string{"a0", "a1", "a2", "a3", "a4"}, walked["u1"])
+	assert.Equal(t, []string{"b0"}, walked["u2"])
+	assert.Equal(t, []string{"c0", "c1", "0c"}, walked["u3"])
+
+	stop := errors.New("stop")
+	calls := 0
+	err = s.Walk(ctx, "", func(api.ShortenedData) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
+
+func testDelete(t *testing.T, s storage.Storage, _ func() (storage.Storage, error)) {
+	ctx := context.Background()
+	defer s.Close()
+	store(t, s, link("u1", "a", 1), link("u1", "b", 2), link("u2", "c", 3))
+
+	assert.ErrorIs(t, s.DeleteByUserIDAndShort(ctx, "u2", "a"), storage.ErrNotFound)
+	assert.ErrorIs(t, s.DeleteByUserIDAndShort(ctx, "u1", "missing"), storage.ErrNotFound)
+	got, err := s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, got.IsDeleted)
+
+	require.NoError(t, s.DeleteByUserIDAndShort(ctx, "u1", "a"))
+	got, err = s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, got.IsDeleted)
+
+	deleted := true
+	page, err := s.ListByUserID(ctx, "u1", api.URLListQuery{Deleted: &deleted})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "a", page.Items[0].ShortURL)
+
+	// u2 links are in other domain
+	n, err := s.DeleteByDomain(ctx, "U1.example")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	got, err = s.Get(ctx, "b")
+	require.NoError(t, err)
+	assert.True(t, got.IsDeleted)
+	got, err = s.Get(ctx, "c")
+	require.NoError(t, err)
+	assert.False(t, got.IsDeleted)
+}
+
+func testUpdate(t *testing.T, s storage.Storage, _ func() (storage.Storage, error)) {
+	ctx := context.Background()
+	defer s.Close()
+	first, second := link("u1", "a", 1), link("u1", "b", 2)
+	store(t, s, first, second)
+
+	title := "Title"
+	_, err := s.UpdateByUserIDAndShort(ctx, "u2", "a", api.URLUpdate{Title: &title})
+	assert.ErrorIs(t, err, storage.ErrForbidden)
+	_, err = s.GetHistoryByUserIDAndShort(ctx, "u2", "a")
+	assert.ErrorIs(t, err, storage.ErrForbidden)
+	_, err = s.UpdateByUserIDAndShort(ctx, "u1", "missing", api.URLUpdate{Title: &title})
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	target, active := "https://new.example/", false
+	updated, err := s.UpdateByUserIDAndShort(ctx, "u1", "a", api.URLUpdate{OriginalURL: &target, Title: &title, Active: &active})
+	require.NoError(t, err)
+	assert.Equal(t, target, updated.OriginalURL)
+	assert.Equal(t, title, updated.Title)
+	assert.True(t, updated.Disabled)
+	got, err := s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, updated, got)
+
+	history, err := s.GetHistoryByUserIDAndShort(ctx, "u1", "a")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, first.OriginalURL, history[0].OriginalURL)
+	assert.Equal(t, target, history[0].ReplacedBy)
+	assert.Equal(t, "u1", history[0].UserID)
+
+	// destination of b is taken by a, b is left unchanged
+	existing, err := s.UpdateByUserIDAndShort(ctx, "u1", "b", api.URLUpdate{OriginalURL: &target})
+	var exists *storage.ErrURLExists
+	require.ErrorAs(t, err, &exists)
+	assert.Equal(t, "a", existing.ShortURL)
+	got, err = s.Get(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, second, got)
+	history, err = s.GetHistoryByUserIDAndShort(ctx, "u1", "b")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	// previous destination of a is free again, new destination is not
+	again, err := storage.Shorten(ctx, s, api.ShortenedData{UserID: "u2", OriginalURL: first.OriginalURL})
+	require.NoError(t, err)
+	assert.NotEqual(t, "a", again.ShortURL)
+	_, err = s.Store(ctx, api.ShortenedData{UserID: "u2", ShortURL: "c", OriginalURL: target})
+	require.ErrorAs(t, err, &exists)
+
+	require.NoError(t, s.DeleteByUserIDAndShort(ctx, "u1", "b"))
+	_, err = s.UpdateByUserIDAndShort(ctx, "u1", "b", api.URLUpdate{Title: &title})
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testAdminUpdate(t *testing.T, s storage.Storage, _ func() (storage.Storage, error)) {
+	ctx := context.Background()
+	defer s.Close()
+	store(t, s, link("u1", "a", 1))
+
+	_, err := s.AdminUpdate(ctx, "missing", api.AdminUpdate{})
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	active, owner := false, "u2"
+	updated, err := s.AdminUpdate(ctx, "a", api.AdminUpdate{Active: &active, UserID: &owner})
+	require.NoError(t, err)
+	assert.True(t, updated.Disabled)
+	assert.Equal(t, "u2", updated.UserID)
+	got, err := s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, updated, got)
+
+	// link moves to new owner
+	page, err := s.ListByUserID(ctx, "u1", api.URLListQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+	page, err = s.ListByUserID(ctx, "u2", api.URLListQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "a", page.Items[0].ShortURL)
+	title := "Title"
+	_, err = s.UpdateByUserIDAndShort(ctx, "u1", "a", api.URLUpdate{Title: &title})
+	assert.ErrorIs(t, err, storage.ErrForbidden)
+	_, err = s.UpdateByUserIDAndShort(ctx, "u2", "a", api.URLUpdate{Title: &title})
+	assert.NoError(t, err)
+
+	// fields not given are kept
+	active = true
+	updated, err = s.AdminUpdate(ctx, "a", api.AdminUpdate{Active: &active})
+	require.NoError(t, err)
+	assert.False(t, updated.Disabled)
+	assert.Equal(t, "u2", updated.UserID)
+	assert.Equal(t, title, updated.Title)
+}
+
+func testDomains(t *testing.T, s storage.Storage, _ func() (storage.Storage, error)) {
+	ctx := context.Background()
+	defer s.Close()
+	primary := link("u1", "a", 1)
+	other := link("u1", "a", 2)
+	other.UUID, other.Domain = "uuid-go-a", "go.example"
+	store(t, s, primary, other)
+
+	// destination is shortened once per domain
+	duplicate := link("u2", "b", 2)
+	duplicate.OriginalURL, duplicate.Domain = other.OriginalURL, other.Domain
+	existing, err := s.Store(ctx, duplicate)
+	var exists *storage.ErrURLExists
+	require.ErrorAs(t, err, &exists)
+	assert.Equal(t, other, existing)
+	taken := link("u2", "a", 3)
+	taken.Domain = other.Domain
+	_, err = s.Store(ctx, taken)
+	assert.ErrorIs(t, err, storage.ErrShortURLTaken)
+	again := link("u2", "c", 1)
+	again.OriginalURL, again.Domain = other.OriginalURL, "short.example"
+	store(t, s, again)
+
+	got, err := s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, primary, got)
+	got, err = s.Get(ctx, "go.example/a")
+	require.NoError(t, err)
+	assert.Equal(t, other, got)
+	_, err = s.Get(ctx, "go.example/c")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	require.NoError(t, s.IncrementClicks(ctx, other.Key()))
+	title := "Other"
+	updated, err := s.UpdateByUserIDAndShort(ctx, "u1", other.Key(), api.URLUpdate{Title: &title, OriginalURL: &primary.OriginalURL})
+	require.NoError(t, err)
+	assert.Equal(t, other.Domain, updated.Domain)
+	history, err := s.GetHistoryByUserIDAndShort(ctx, "u1", other.Key())
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "a", history[0].ShortURL)
+	history, err = s.GetHistoryByUserIDAndShort(ctx, "u1", "a")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	require.NoError(t, s.DeleteByUserIDAndShort(ctx, "u1", other.Key()))
+	got, err = s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, got.IsDeleted)
+	assert.Zero(t, got.Clicks)
+
+	page, err := s.ListByUserID(ctx, "u1", api.URLListQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	keys := []string{page.Items[0].Key(), page.Items[1].Key()}
+	assert.ElementsMatch(t, []string{"a", "go.example/a"}, keys)
+
+	// links of same time and short url on several domains are paged one by one
+	for i, domain := range []string{"", "go.example", "short.example"} {
+		l := link("u3", "p", 0)
+		l.UUID, l.Domain, l.OriginalURL = fmt.Sprintf("uuid-p%d", i), domain, fmt.Sprintf("https://p.example/%d", i)
+		store(t, s, l)
+	}
+	for _, q := range []api.URLListQuery{{}, {Asc: true}, {Sort: api.SortClicks}} {
+		var keys []string
+		q.Limit = 1
+		for {
+			page, err := s.ListByUserID(ctx, "u3", q)
+			require.NoError(t, err)
+			for _, item := range page.Items {
+				keys = append(keys, item.Key())
+			}
+			if q.Cursor = page.NextCursor; q.Cursor == "" {
+				break
+			}
+		}
+		assert.ElementsMatch(t, []string{"p", "go.example/p", "short.example/p"}, keys, q)
+	}
+}
+
+func testStatistic(t *testing.T, s storage.Storage, _ func() (storage.Storage, error)) {
+	ctx := context.Background()
+	defer s.Close()
+	store(t, s, link("u1", "a", 1), link("u1", "b", 2), link("u2", "c", 24*60))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.IncrementClicks(ctx, "b"))
+	}
+	require.NoError(t, s.IncrementClicks(ctx, "c"))
+	require.NoError(t, s.DeleteByUserIDAndShort(ctx, "u1", "a"))
+
+	st, err := s.GetStatistic(ctx, api.StatisticQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, st.URLs)
+	assert.Equal(t, 2, st.Users)
+	assert.Equal(t, 2, st.Active)
+	assert.Equal(t, 1, st.Deleted)
+	assert.Equal(t, int64(4), st.Redirects)
+	assert.Equal(t, []api.DayCount{{Day: "2024-03-01", Count: 2}, {Day: "2024-03-02", Count: 1}}, st.CreatedPerDay)
+	assert.Equal(t, []api.DomainCount{{Domain: "u1.example", Count: 2}, {Domain: "u2.example", Count: 1}}, st.TopDomains)
+	assert.Equal(t, []api.LinkClicks{
+		{ShortURL: "b", OriginalURL: "https://u1.example/2", Clicks: 3},
+		{ShortURL: "c", OriginalURL: "https://u2.example/1440", Clicks: 1},
+	}, st.TopLinks)
+
+	st, err = s.GetStatistic(ctx, api.StatisticQuery{From: created.Add(24 * time.Hour), Top: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, st.URLs)
+	assert.Equal(t, []api.LinkClicks{{ShortURL: "c", OriginalURL: "https://u2.example/1440", Clicks: 1}}, st.TopLinks)
+}
+
+func testConcurrency(t *testing.T, s storage.Storage, _ func() (storage.Storage, error)) {
+	ctx := context.Background()
+	defer s.Close()
+	store(t, s, link("u1", "hot", 0))
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		won    int
+		exists int
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, s.IncrementClicks(ctx, "hot"))
+			_, err := storage.Shorten(ctx, s, api.ShortenedData{UserID: "u2", OriginalURL: fmt.Sprintf("https://distinct.example/%d", i)})
+			assert.NoError(t, err)
+
+			// every goroutine races for the same destination, only one wins
+			_, err = s.Store(ctx, link("u3", fmt.Sprintf("same%d", i), 1))
+			var existsErr *storage.ErrURLExists
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				won++
+			case errors.As(err, &existsErr):
+				exists++
+			default:
+				t.Errorf("store same destination: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	got, err := s.Get(ctx, "hot")
+	require.NoError(t, err)
+	assert.Equal(t, int64(concurrency), got.Clicks)
+	page, err := s.ListByUserID(ctx, "u2", api.URLListQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, concurrency, page.Total)
+	assert.Equal(t, 1, won)
+	assert.Equal(t, concurrency-1, exists)
+}
+
+func testClose(t *testing.T, s storage.Storage, reopen func() (storage.Storage, error)) {
+	ctx := context.Background()
+	store(t, s, link("u1", "a", 1), link("u1", "b", 2))
+	require.NoError(t, s.IncrementClicks(ctx, "a"))
+	require.NoError(t, s.DeleteByUserIDAndShort(ctx, "u1", "b"))
+	require.NoError(t, s.Close())
+	if reopen == nil {
+		return
+	}
+
+	s, err := reopen()
+	require.NoError(t, err)
+	defer s.Close()
+	got, err := s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), got.Clicks)
+	got, err = s.Get(ctx, "b")
+	require.NoError(t, err)
+	assert.True(t, got.IsDeleted)
+}
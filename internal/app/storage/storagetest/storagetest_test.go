@@ -0,0 +1,78 @@
+package storagetest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+)
+
+// dsnEnv names Postgres DSN the suite runs against, Postgres is skipped when it is empty.
+// Tables of the database are truncated before every test
+const dsnEnv = "TEST_DATABASE_DSN"
+
+// persistent returns Opener of backend storing data at path in test directory
+func persistent(name string, open func(path string) (storage.Storage, error)) Opener {
+	return func(t *testing.T) (storage.Storage, func() (storage.Storage, error)) {
+		path := filepath.Join(t.TempDir(), name)
+		s, err := open(path)
+		require.NoError(t, err)
+		return s, func() (storage.Storage, error) { return open(path) }
+	}
+}
+
+func TestMemory(t *testing.T) {
+	Run(t, func(*testing.T) (storage.Storage, func() (storage.Storage, error)) {
+		return storage.NewInMemoryStorage(), nil
+	})
+}
+
+func TestCached(t *testing.T) {
+	Run(t, func(*testing.T) (storage.Storage, func() (storage.Storage, error)) {
+		return storage.NewCachedStorage(storage.NewInMemoryStorage(), storage.CacheOptions{
+			Size: 100, TTL: time.Minute, NegativeTTL: time.Minute,
+		}), nil
+	})
+}
+
+func TestFile(t *testing.T) {
+	Run(t, persistent("links.json", func(path string) (storage.Storage, error) {
+		return storage.NewFileStorage(path)
+	}))
+}
+
+func TestBolt(t *testing.T) {
+	Run(t, persistent("links.bolt", func(path string) (storage.Storage, error) {
+		return storage.NewBoltStorage(path)
+	}))
+}
+
+func TestSQLite(t *testing.T) {
+	Run(t, persistent("links.db", func(path string) (storage.Storage, error) {
+		return storage.NewSQLiteStorage(path, *zap.NewNop().Sugar())
+	}))
+}
+
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", dsnEnv)
+	}
+	opts := storage.DBOptions{MaxOpenConns: 10, MaxIdleConns: 10}
+	reopen := func() (storage.Storage, error) {
+		return storage.NewDBStorage(dsn, opts, *zap.NewNop().Sugar())
+	}
+	Run(t, func(t *testing.T) (storage.Storage, func() (storage.Storage, error)) {
+		s, err := storage.NewDBStorage(dsn, opts, *zap.NewNop().Sugar())
+		require.NoError(t, err)
+		_, err = s.DB.ExecContext(context.Background(), "TRUNCATE shortener, shortener_history RESTART IDENTITY")
+		require.NoError(t, err)
+		return s, reopen
+	})
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+)
+
+const tracerName = "github.com/gsk148/urlShorteningService/internal/app/storage"
+
+// TracedStorage wraps Storage and records a child span for every call
+type TracedStorage struct {
+	next   Storage
+	tracer trace.Tracer
+}
+
+// NewTracedStorage return TracedStorage object
+func NewTracedStorage(next Storage) *TracedStorage {
+	return &TracedStorage{
+		next:   next,
+		tracer: otel.Tracer(tracerName),
+	}
+}
+
+// Unwrap returns wrapped storage
+func (s *TracedStorage) Unwrap() Storage {
+	return s.next
+}
+
+func (s *TracedStorage) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "storage."+method,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...))
+}
+
+func finish(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, &ErrURLExists{}) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Store records span for Storage.Store
+func (s *TracedStorage) Store(ctx context.Context, data api.ShortenedData) (api.ShortenedData, error) {
+	ctx, span := s.start(ctx, "Store", attribute.String("shortener.short_url", data.Key()))
+	res, err := s.next.Store(ctx, data)
+	finish(span, err)
+	return res, err
+}
+
+// Get records span for Storage.Get
+func (s *TracedStorage) Get(ctx context.Context, key string) (api.ShortenedData, error) {
+	ctx, span := s.start(ctx, "Get", attribute.String("shortener.short_url", key))
+	res, err := s.next.Get(ctx, key)
+	finish(span, err)
+	return res, err
+}
+
+// Ping records span for Storage.Ping
+func (s *TracedStorage) Ping(ctx context.Context) error {
+	ctx, span := s.start(ctx, "Ping")
+	err := s.next.Ping(ctx)
+	finish(span, err)
+	return err
+}
+
+// Close closes wrapped storage
+func (s *TracedStorage) Close() error {
+	return s.next.Close()
+}
+
+// ListByUserID records span for Storage.ListByUserID
+func (s *TracedStorage) ListByUserID(ctx context.Context, userID string, q api.URLListQuery) (api.URLListPage, error) {
+	ctx, span := s.start(ctx, "ListByUserID",
+		attribute.String("shortener.user_id", userID),
+		attribute.String("shortener.sort", q.Sort),
+		attribute.Int("shortener.limit", q.Limit))
+	res, err := s.next.ListByUserID(ctx, userID, q)
+	finish(span, err)
+	return res, err
+}
+
+// Walk records span for Storage.Walk
+func (s *TracedStorage) Walk(ctx context.Context, userID string, fn func(api.ShortenedData) error) error {
+	ctx, span := s.start(ctx, "Walk", attribute.String("shortener.user_id", userID))
+	err := s.next.Walk(ctx, userID, fn)
+	finish(span, err)
+	return err
+}
+
+// IncrementClicks records span for Storage.IncrementClicks
+func (s *TracedStorage) IncrementClicks(ctx context.Context, shortURL string) error {
+	ctx, span := s.start(ctx, "IncrementClicks", attribute.String("shortener.short_url", shortURL))
+	err := s.next.IncrementClicks(ctx, shortURL)
+	finish(span, err)
+	return err
+}
+
+// DeleteByUserIDAndShort records span for Storage.DeleteByUserIDAndShort
+func (s *TracedStorage) DeleteByUserIDAndShort(ctx context.Context, userID string, shortURL string) error {
+	ctx, span := s.start(ctx, "DeleteByUserIDAndShort",
+		attribute.String("shortener.user_id", userID),
+		attribute.String("shortener.short_url", shortURL))
+	err := s.next.DeleteByUserIDAndShort(ctx, userID, shortURL)
+	finish(span, err)
+	return err
+}
+
+// UpdateByUserIDAndShort records span for Storage.UpdateByUserIDAndShort
+func (s *TracedStorage) UpdateByUserIDAndShort(ctx context.Context, userID string, shortURL string, upd api.URLUpdate) (api.ShortenedData, error) {
+	ctx, span := s.start(ctx, "UpdateByUserIDAndShort",
+		attribute.String("shortener.user_id", userID),
+		attribute.String("shortener.short_url", shortURL),
+		attribute.Bool("shortener.retarget", upd.OriginalURL != nil))
+	res, err := s.next.UpdateByUserIDAndShort(ctx, userID, shortURL, upd)
+	finish(span, err)
+	return res, err
+}
+
+// GetHistoryByUserIDAndShort records span for Storage.GetHistoryByUserIDAndShort
+func (s *TracedStorage) GetHistoryByUserIDAndShort(ctx context.Context, userID string, shortURL string) ([]api.URLHistoryEntry, error) {
+	ctx, span := s.start(ctx, "GetHistoryByUserIDAndShort",
+		attribute.String("shortener.user_id", userID),
+		attribute.String("shortener.short_url", shortURL))
+	res, err := s.next.GetHistoryByUserIDAndShort(ctx, userID, shortURL)
+	finish(span, err)
+	return res, err
+}
+
+// AdminUpdate records span for Storage.AdminUpdate
+func (s *TracedStorage) AdminUpdate(ctx context.Context, shortURL string, upd api.AdminUpdate) (api.ShortenedData, error) {
+	ctx, span := s.start(ctx, "AdminUpdate", attribute.String("shortener.short_url", shortURL))
+	res, err := s.next.AdminUpdate(ctx, shortURL, upd)
+	finish(span, err)
+	return res, err
+}
+
+// DeleteByDomain records span for Storage.DeleteByDomain
+func (s *TracedStorage) DeleteByDomain(ctx context.Context, domain string) (int, error) {
+	ctx, span := s.start(ctx, "DeleteByDomain", attribute.String("shortener.domain", domain))
+	res, err := s.next.DeleteByDomain(ctx, domain)
+	span.SetAttributes(attribute.Int("shortener.deleted", res))
+	finish(span, err)
+	return res, err
+}
+
+// GetStatistic records span for Storage.GetStatistic
+func (s *TracedStorage) GetStatistic(ctx context.Context, q api.StatisticQuery) (*api.Statistic, error) {
+	ctx, span := s.start(ctx, "GetStatistic",
+		attribute.String("shortener.from", formatBound(q.From)),
+		attribute.String("shortener.to", formatBound(q.To)))
+	res, err := s.next.GetStatistic(ctx, q)
+	finish(span, err)
+	return res, err
+}
+
+func formatBound(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
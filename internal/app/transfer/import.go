@@ -0,0 +1,134 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+	"github.com/gsk148/urlShorteningService/internal/app/domains"
+	"github.com/gsk148/urlShorteningService/internal/app/policy"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+	"github.com/gsk148/urlShorteningService/internal/app/urlnorm"
+)
+
+// validShort matches short urls that can be kept on import
+var validShort = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ImportOptions configures Import
+type ImportOptions struct {
+	// Owner overrides user of every record, records without user fail when it is empty
+	Owner string
+	// URLOptions normalizes destinations the same way as shorten API
+	URLOptions urlnorm.Options
+	// Policy checks destinations, nil allows everything
+	Policy *policy.Policy
+	// Domains rejects records of unknown short domains, nil keeps domains as is
+	Domains *domains.Registry
+}
+
+// Import stores records of r one by one through the normal storage path and reports
+// result of every record. Original short urls are kept when they are free, otherwise
+// new ones are generated. Error is returned only when input cannot be read further
+func Import(ctx context.Context, store storage.Storage, r io.Reader, format string, opts ImportOptions,
+	report func(api.ImportResult)) (api.ImportSummary, error) {
+	var summary api.ImportSummary
+	next, err := newDecoder(r, format)
+	if err != nil {
+		return summary, err
+	}
+	for {
+		if err = ctx.Err(); err != nil {
+			return summary, err
+		}
+		rec, line, err := next()
+		if errors.Is(err, io.EOF) {
+			return summary, nil
+		}
+		var (
+			res     api.ImportResult
+			readErr *readError
+		)
+		if errors.As(err, &readErr) {
+			return summary, readErr
+		}
+		if err != nil {
+			res = api.ImportResult{Line: line, Status: api.ImportFailed, Error: err.Error()}
+		} else {
+			res = importRecord(ctx, store, rec, opts)
+			res.Line = line
+		}
+		summary.Add(res)
+		if report != nil {
+			report(res)
+		}
+	}
+}
+
+func importRecord(ctx context.Context, store storage.Storage, rec api.LinkRecord, opts ImportOptions) api.ImportResult {
+	res := api.ImportResult{ShortURL: rec.ShortURL, OriginalURL: rec.OriginalURL}
+	fail := func(err error) api.ImportResult {
+		res.Status = api.ImportFailed
+		res.Error = err.Error()
+		return res
+	}
+
+	if opts.Owner != "" {
+		rec.UserID = opts.Owner
+	}
+	if rec.UserID == "" {
+		return fail(errors.New("user_id is required"))
+	}
+	err := api.CheckRedirectType(rec.RedirectType)
+	if err != nil {
+		return fail(err)
+	}
+	if rec.Domain != "" && opts.Domains != nil {
+		if rec.Domain, err = opts.Domains.Choose(rec.Domain); err != nil {
+			return fail(err)
+		}
+	}
+	normalized, err := opts.URLOptions.Normalize(rec.OriginalURL)
+	if err != nil {
+		return fail(err)
+	}
+	if err = opts.Policy.Check(ctx, normalized); err != nil {
+		return fail(err)
+	}
+
+	data := rec.Data()
+	data.OriginalURL = normalized
+	data.UUID = uuid.New().String()
+	if data.CreatedAt.IsZero() {
+		data.CreatedAt = time.Now().UTC()
+	}
+	res.OriginalURL = normalized
+
+	res.Status = api.ImportCreated
+	var stored api.ShortenedData
+	if validShort.MatchString(data.ShortURL) {
+		stored, err = store.Store(ctx, data)
+		if errors.Is(err, storage.ErrShortURLTaken) {
+			res.Status = api.ImportRenamed
+			stored, err = storage.Shorten(ctx, store, data)
+		}
+	} else {
+		if data.ShortURL != "" {
+			res.Status = api.ImportRenamed
+		}
+		stored, err = storage.Shorten(ctx, store, data)
+	}
+	switch {
+	case errors.Is(err, &storage.ErrURLExists{}):
+		res.Status = api.ImportExists
+	case err != nil:
+		return fail(fmt.Errorf("store: %w", err))
+	}
+	res.ShortURL = stored.Key()
+	return res
+}
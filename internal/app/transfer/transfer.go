@@ -0,0 +1,250 @@
+// Package transfer exports links as JSON lines, CSV or FileStorage lines and imports
+// them back through the normal storage path
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+)
+
+// Formats of exported and imported links
+const (
+	// FormatJSONL is one api.LinkRecord per line
+	FormatJSONL = "jsonl"
+	// FormatCSV has header row with csvColumns, tags are separated by tagSeparator
+	FormatCSV = "csv"
+	// FormatFile is line format of FileStorage
+	FormatFile = "file"
+)
+
+// ErrUnknownFormat returned for formats other than FormatJSONL, FormatCSV and FormatFile
+var ErrUnknownFormat = errors.New("unknown format")
+
+var csvColumns = []string{"short_url", "original_url", "user_id", "title", "description", "tags",
+	"disabled", "is_deleted", "created_at", "expires_at", "clicks", "domain",
+	"redirect_type", "pass_query", "utm"}
+
+const tagSeparator = "|"
+
+// ContentType returns MIME type of format
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// CheckFormat returns ErrUnknownFormat for unsupported format
+func CheckFormat(format string) error {
+	switch format {
+	case FormatJSONL, FormatCSV, FormatFile:
+		return nil
+	}
+	return fmt.Errorf("%w %q: must be %s, %s or %s", ErrUnknownFormat, format, FormatJSONL, FormatCSV, FormatFile)
+}
+
+// Export streams links of user, or all links when userID is empty, to w and returns their number
+func Export(ctx context.Context, store storage.Storage, w io.Writer, format, userID string) (int, error) {
+	if err := CheckFormat(format); err != nil {
+		return 0, err
+	}
+	bw := bufio.NewWriter(w)
+	var (
+		cw    *csv.Writer
+		count int
+	)
+	if format == FormatCSV {
+		cw = csv.NewWriter(bw)
+		if err := cw.Write(csvColumns); err != nil {
+			return 0, err
+		}
+	}
+
+	err := store.Walk(ctx, userID, func(data api.ShortenedData) error {
+		count++
+		switch format {
+		case FormatCSV:
+			return cw.Write(csvRow(api.NewLinkRecord(data)))
+		case FormatFile:
+			return writeJSONLine(bw, data)
+		default:
+			return writeJSONLine(bw, api.NewLinkRecord(data))
+		}
+	})
+	if cw != nil {
+		cw.Flush()
+		if err == nil {
+			err = cw.Error()
+		}
+	}
+	if flushErr := bw.Flush(); err == nil {
+		err = flushErr
+	}
+	return count, err
+}
+
+func writeJSONLine(w io.Writer, v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+func csvRow(r api.LinkRecord) []string {
+	row := []string{r.ShortURL, r.OriginalURL, r.UserID, r.Title, r.Description,
+		strings.Join(r.Tags, tagSeparator), strconv.FormatBool(r.Disabled), strconv.FormatBool(r.IsDeleted),
+		"", "", strconv.FormatInt(r.Clicks, 10), r.Domain,
+		r.RedirectType, strconv.FormatBool(r.PassQuery), r.UTM.Values().Encode()}
+	if r.CreatedAt != nil {
+		row[8] = r.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	if r.ExpiresAt != nil {
+		row[9] = r.ExpiresAt.UTC().Format(time.RFC3339Nano)
+	}
+	return row
+}
+
+// decoder reads records of import one by one, io.EOF ends input. Line is
+// 1-based number of record in input, malformed records are returned with error,
+// failures of reading are returned as *readError and stop import
+type decoder func() (rec api.LinkRecord, line int, err error)
+
+// readError is failure of input itself rather than of single record
+type readError struct {
+	err error
+}
+
+func (e *readError) Error() string {
+	return "read input: " + e.err.Error()
+}
+
+func (e *readError) Unwrap() error {
+	return e.err
+}
+
+func newDecoder(r io.Reader, format string) (decoder, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVDecoder(r)
+	case FormatJSONL, FormatFile:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		line := 0
+		return func() (api.LinkRecord, int, error) {
+			for scanner.Scan() {
+				line++
+				text := strings.TrimSpace(scanner.Text())
+				if text == "" {
+					continue
+				}
+				if format == FormatFile {
+					var data api.ShortenedData
+					err := json.Unmarshal([]byte(text), &data)
+					return api.NewLinkRecord(data), line, err
+				}
+				var rec api.LinkRecord
+				err := json.Unmarshal([]byte(text), &rec)
+				return rec, line, err
+			}
+			if err := scanner.Err(); err != nil {
+				return api.LinkRecord{}, line, &readError{err}
+			}
+			return api.LinkRecord{}, line, io.EOF
+		}, nil
+	}
+	return nil, CheckFormat(format)
+}
+
+func newCSVDecoder(r io.Reader) (decoder, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.TrimSpace(name)] = i
+	}
+	if _, ok := index["original_url"]; !ok {
+		return nil, errors.New("csv header: original_url column is required")
+	}
+
+	line := 1
+	return func() (api.LinkRecord, int, error) {
+		row, err := cr.Read()
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				if errors.Is(err, io.EOF) {
+					return api.LinkRecord{}, line, io.EOF
+				}
+				return api.LinkRecord{}, line, &readError{err}
+			}
+			line = parseErr.StartLine
+			return api.LinkRecord{}, line, err
+		}
+		line, _ = cr.FieldPos(0)
+		get := func(name string) string {
+			if i, ok := index[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		rec := api.LinkRecord{
+			Domain:       get("domain"),
+			ShortURL:     get("short_url"),
+			OriginalURL:  get("original_url"),
+			UserID:       get("user_id"),
+			Title:        get("title"),
+			Description:  get("description"),
+			RedirectType: get("redirect_type"),
+		}
+		if tags := get("tags"); tags != "" {
+			rec.Tags = strings.Split(tags, tagSeparator)
+		}
+		if v := get("utm"); v != "" {
+			values, err := url.ParseQuery(v)
+			if err != nil {
+				return rec, line, fmt.Errorf("utm: %w", err)
+			}
+			rec.UTM = api.ParseUTM(values)
+		}
+		for name, dst := range map[string]*bool{"disabled": &rec.Disabled, "is_deleted": &rec.IsDeleted, "pass_query": &rec.PassQuery} {
+			if v := get(name); v != "" {
+				if *dst, err = strconv.ParseBool(v); err != nil {
+					return rec, line, fmt.Errorf("%s: %w", name, err)
+				}
+			}
+		}
+		for name, dst := range map[string]**time.Time{"created_at": &rec.CreatedAt, "expires_at": &rec.ExpiresAt} {
+			if v := get(name); v != "" {
+				t, err := time.Parse(time.RFC3339Nano, v)
+				if err != nil {
+					return rec, line, fmt.Errorf("%s: %w", name, err)
+				}
+				*dst = &t
+			}
+		}
+		if v := get("clicks"); v != "" {
+			if rec.Clicks, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return rec, line, fmt.Errorf("clicks: %w", err)
+			}
+		}
+		return rec, line, nil
+	}, nil
+}
@@ -0,0 +1,123 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gsk148/urlShorteningService/internal/app/api"
+	"github.com/gsk148/urlShorteningService/internal/app/storage"
+)
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	expires := created.Add(24 * time.Hour)
+	links := []api.ShortenedData{
+		{UserID: "u1", UUID: "1", ShortURL: "first", OriginalURL: "https://a.example/", Title: "A, \"quoted\"",
+			Tags: []string{"x", "y"}, CreatedAt: created, ExpiresAt: &expires, Clicks: 7,
+			RedirectType: api.RedirectPage, PassQuery: true, UTM: &api.UTM{Source: "news", Content: "a&b"}},
+		{UserID: "u2", UUID: "2", ShortURL: "second", OriginalURL: "https://b.example/", Disabled: true,
+			CreatedAt: created.Add(time.Hour)},
+	}
+
+	for _, format := range []string{FormatJSONL, FormatCSV, FormatFile} {
+		t.Run(format, func(t *testing.T) {
+			src := storage.NewInMemoryStorage()
+			for _, link := range links {
+				_, err := src.Store(ctx, link)
+				require.NoError(t, err)
+			}
+			var buf bytes.Buffer
+			count, err := Export(ctx, src, &buf, format, "")
+			require.NoError(t, err)
+			assert.Equal(t, 2, count)
+
+			dst := storage.NewInMemoryStorage()
+			var results []api.ImportResult
+			summary, err := Import(ctx, dst, &buf, format, ImportOptions{}, func(r api.ImportResult) {
+				results = append(results, r)
+			})
+			require.NoError(t, err)
+			assert.Equal(t, api.ImportSummary{Created: 2}, summary)
+			require.Len(t, results, 2)
+
+			for _, link := range links {
+				got, err := dst.Get(ctx, link.ShortURL)
+				require.NoError(t, err)
+				assert.Equal(t, link.UserID, got.UserID)
+				assert.Equal(t, link.OriginalURL, got.OriginalURL)
+				assert.Equal(t, link.Title, got.Title)
+				assert.Equal(t, link.Tags, got.Tags)
+				assert.Equal(t, link.Disabled, got.Disabled)
+				assert.Equal(t, link.Clicks, got.Clicks)
+				assert.Equal(t, link.RedirectType, got.RedirectType)
+				assert.Equal(t, link.PassQuery, got.PassQuery)
+				assert.Equal(t, link.UTM, got.UTM)
+				assert.True(t, link.CreatedAt.Equal(got.CreatedAt))
+			}
+		})
+	}
+}
+
+func TestExportUser(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewInMemoryStorage()
+	for _, user := range []string{"u1", "u2", "u1"} {
+		_, err := storage.Shorten(ctx, s, api.ShortenedData{UserID: user, OriginalURL: "https://" + user + ".example/" + time.Now().String()})
+		require.NoError(t, err)
+	}
+	var buf bytes.Buffer
+	count, err := Export(ctx, s, &buf, FormatJSONL, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 2, strings.Count(buf.String(), `"user_id":"u1"`))
+
+	_, err = Export(ctx, s, &buf, "xml", "")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestImportResults(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewInMemoryStorage()
+	_, err := s.Store(ctx, api.ShortenedData{UserID: "old", ShortURL: "taken", OriginalURL: "https://taken.example/"})
+	require.NoError(t, err)
+
+	input := strings.Join([]string{
+		`{"short_url":"taken","original_url":"https://new.example/"}`,
+		`{"short_url":"own","original_url":"https://taken.example/"}`,
+		`{"short_url":"bad short","original_url":"https://other.example/"}`,
+		`{"original_url":"ftp://files.example/"}`,
+		`not json`,
+		``,
+		`{"short_url":"own","original_url":"HTTPS://Own.Example"}`,
+	}, "\n")
+	var results []api.ImportResult
+	summary, err := Import(ctx, s, strings.NewReader(input), FormatJSONL, ImportOptions{Owner: "me"}, func(r api.ImportResult) {
+		results = append(results, r)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, api.ImportSummary{Created: 1, Renamed: 2, Exists: 1, Failed: 2}, summary)
+	require.Len(t, results, 6)
+
+	assert.Equal(t, api.ImportRenamed, results[0].Status)
+	assert.NotEqual(t, "taken", results[0].ShortURL)
+	assert.Equal(t, api.ImportResult{Line: 2, Status: api.ImportExists, ShortURL: "taken", OriginalURL: "https://taken.example/"}, results[1])
+	assert.Equal(t, api.ImportRenamed, results[2].Status)
+	assert.Equal(t, api.ImportFailed, results[3].Status)
+	assert.Equal(t, 5, results[4].Line)
+	assert.Equal(t, api.ImportFailed, results[4].Status)
+	assert.Equal(t, api.ImportResult{Line: 7, Status: api.ImportCreated, ShortURL: "own", OriginalURL: "https://own.example"}, results[5])
+
+	got, err := s.Get(ctx, "own")
+	require.NoError(t, err)
+	assert.Equal(t, "me", got.UserID)
+
+	_, err = Import(ctx, s, strings.NewReader("short_url\nx\n"), FormatCSV, ImportOptions{}, nil)
+	assert.Error(t, err)
+}
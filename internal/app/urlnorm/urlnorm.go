@@ -0,0 +1,129 @@
+// Package urlnorm validates destination urls and brings them to canonical form,
+// so equal destinations are stored and deduplicated once
+package urlnorm
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Defaults used for zero Options fields
+var (
+	DefaultSchemes   = []string{"http", "https"}
+	DefaultMaxLength = 2048
+)
+
+// ErrInvalid wraps every validation failure
+var ErrInvalid = errors.New("invalid url")
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// Options configures validation, zero value uses defaults
+type Options struct {
+	Schemes   []string
+	MaxLength int
+}
+
+// Normalize validates raw url with default options and returns its canonical form
+func Normalize(raw string) (string, error) {
+	return Options{}.Normalize(raw)
+}
+
+// Normalize trims whitespace, checks scheme and length, converts host to lowercase
+// ASCII (punycode) and strips default port. Path, query and fragment are kept as is.
+func (o Options) Normalize(raw string) (string, error) {
+	maxLength := o.MaxLength
+	if maxLength <= 0 {
+		maxLength = DefaultMaxLength
+	}
+
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("%w: empty", ErrInvalid)
+	}
+	if len(raw) > maxLength {
+		return "", fmt.Errorf("%w: longer than %d characters", ErrInvalid, maxLength)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalid, errors.Unwrap(err))
+	}
+	if u.Scheme == "" {
+		return "", fmt.Errorf("%w: scheme is missing", ErrInvalid)
+	}
+	if !o.allowed(u.Scheme) {
+		return "", fmt.Errorf("%w: scheme %q is not allowed", ErrInvalid, u.Scheme)
+	}
+	if u.Opaque != "" || u.Host == "" {
+		return "", fmt.Errorf("%w: host is missing", ErrInvalid)
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+	port := u.Port()
+	if port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("%w: port %q out of range", ErrInvalid, port)
+		}
+		if defaultPorts[u.Scheme] == port {
+			port = ""
+		}
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	u.ForceQuery = false
+
+	result := u.String()
+	if len(result) > maxLength {
+		return "", fmt.Errorf("%w: longer than %d characters", ErrInvalid, maxLength)
+	}
+	return result, nil
+}
+
+func (o Options) allowed(scheme string) bool {
+	schemes := o.Schemes
+	if len(schemes) == 0 {
+		schemes = DefaultSchemes
+	}
+	for _, s := range schemes {
+		if strings.EqualFold(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeHost(host string) (string, error) {
+	if host == "" {
+		return "", fmt.Errorf("%w: host is missing", ErrInvalid)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+	host = strings.TrimSuffix(host, ".")
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("%w: host %q: %v", ErrInvalid, host, err)
+	}
+	return strings.ToLower(ascii), nil
+}
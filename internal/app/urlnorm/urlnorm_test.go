@@ -0,0 +1,49 @@
+package urlnorm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"  https://Practicum.Yandex.RU/Path?q=1 \n", "https://practicum.yandex.ru/Path?q=1"},
+		{"HTTP://example.com:80/a", "http://example.com/a"},
+		{"https://example.com:443", "https://example.com"},
+		{"https://example.com:8443/", "https://example.com:8443/"},
+		{"https://пример.рф/путь", "https://xn--e1afmkfd.xn--p1ai/%D0%BF%D1%83%D1%82%D1%8C"},
+		{"https://example.com./?", "https://example.com/"},
+		{"http://[::1]:80/x", "http://[::1]/x"},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+}
+
+func TestNormalizeInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"   ",
+		"javascript:alert(1)",
+		"example.com/path",
+		"ftp://example.com",
+		"https://",
+		"http://example.com:99999",
+		"https://" + strings.Repeat("a", DefaultMaxLength) + ".com",
+	} {
+		_, err := Normalize(in)
+		assert.ErrorIs(t, err, ErrInvalid, in)
+	}
+
+	got, err := Options{Schemes: []string{"ftp"}}.Normalize("FTP://example.com:21/file")
+	require.NoError(t, err)
+	assert.Equal(t, "ftp://example.com/file", got)
+}